@@ -0,0 +1,322 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/spf13/cobra"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const managedClusterResource = "managedcluster"
+
+var errNotFound = errors.New("not found")
+
+// listOptions are the options of the get and watch commands.
+type listOptions struct {
+	selector   string
+	search     string
+	hubCluster string
+	output     string
+}
+
+func (options *listOptions) addFlags(command *cobra.Command) {
+	command.Flags().StringVarP(&options.selector, "selector", "l", "",
+		"the label selector of the managed clusters, e.g. environment=dev,!deprecated")
+	command.Flags().StringVar(&options.search, "search", "",
+		"free text to search in the names, the labels, the claims and the URLs of the managed clusters")
+	command.Flags().StringVarP(&options.output, "output", "o", "", "the output format: wide, json, yaml or name")
+}
+
+func (options *listOptions) parse() (labels.Selector, error) {
+	if err := validateOutput(options.output); err != nil {
+		return nil, err
+	}
+
+	selector, err := labels.Parse(options.selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+
+	return selector, nil
+}
+
+func newGetCommand(globalOptions *globalOptions) *cobra.Command {
+	options := &listOptions{}
+
+	command := &cobra.Command{
+		Use:     "get [NAME...]",
+		Aliases: []string{"list"},
+		Short:   "Display the managed clusters",
+		Example: "  hohctl get\n  hohctl get cluster1 -o yaml\n  hohctl get -l environment=dev -o wide",
+		RunE: func(command *cobra.Command, names []string) error {
+			return runGet(command.Context(), command.OutOrStdout(), command.ErrOrStderr(), globalOptions, options,
+				names)
+		},
+	}
+
+	options.addFlags(command)
+	command.Flags().StringVar(&options.hubCluster, "hub-cluster", "",
+		"the hub cluster of the named managed clusters, if clusters with the same name exist in multiple hub clusters")
+
+	return command
+}
+
+func runGet(ctx context.Context, out, errOut io.Writer, globalOptions *globalOptions, options *listOptions,
+	names []string) error {
+	selector, err := options.parse()
+	if err != nil {
+		return err
+	}
+
+	managedClustersClient, err := globalOptions.newClient()
+	if err != nil {
+		return err
+	}
+
+	if options.output == outputJSON || options.output == outputYAML {
+		return getObjects(ctx, out, managedClustersClient, options, selector, names)
+	}
+
+	table, err := managedClustersClient.ListTable(ctx, client.ListOptions{Search: options.search})
+	if err != nil {
+		return fmt.Errorf("failed to list the managed clusters: %w", err)
+	}
+
+	rows, err := filterRows(table.Rows, names, selector)
+	if err != nil {
+		return err
+	}
+
+	if len(rows) == 0 && len(names) == 0 {
+		fmt.Fprintln(errOut, "No resources found")
+		return nil
+	}
+
+	found := map[string]bool{}
+
+	for _, row := range rows {
+		objectMetadata, err := rowMetadata(row)
+		if err != nil {
+			return err
+		}
+
+		found[objectMetadata.GetName()] = true
+
+		if options.output == outputName {
+			fmt.Fprintf(out, "%s/%s\n", managedClusterResource, objectMetadata.GetName())
+		}
+	}
+
+	if options.output != outputName {
+		if err := newTablePrinter(out, options.output == outputWide).print(table, rows); err != nil {
+			return err
+		}
+	}
+
+	errs := []error{}
+
+	for _, name := range names {
+		if !found[name] {
+			errs = append(errs, fmt.Errorf("%w: %s %q", errNotFound, managedClusterResource, name))
+		}
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// getObjects prints the managed clusters as JSON or YAML, a single managed cluster if a single name is given.
+func getObjects(ctx context.Context, out io.Writer, managedClustersClient client.Interface, options *listOptions,
+	selector labels.Selector, names []string) error {
+	if len(names) == 1 && options.selector == "" && options.search == "" {
+		managedCluster, err := managedClustersClient.Get(ctx, names[0], client.GetOptions{HubCluster: options.hubCluster})
+		if err != nil {
+			return fmt.Errorf("failed to get the managed cluster: %w", err)
+		}
+
+		return printObject(out, options.output, managedCluster)
+	}
+
+	managedClusterList, err := managedClustersClient.List(ctx, client.ListOptions{Search: options.search})
+	if err != nil {
+		return fmt.Errorf("failed to list the managed clusters: %w", err)
+	}
+
+	items := []clusterv1.ManagedCluster{}
+
+	for _, managedCluster := range managedClusterList.Items {
+		if selected(managedCluster.GetName(), managedCluster.GetLabels(), names, selector) {
+			items = append(items, managedCluster)
+		}
+	}
+
+	managedClusterList.Items = items
+
+	return printObject(out, options.output, managedClusterList)
+}
+
+func newWatchCommand(globalOptions *globalOptions) *cobra.Command {
+	options := &listOptions{}
+
+	command := &cobra.Command{
+		Use:   "watch [NAME...]",
+		Short: "Watch the changes of the managed clusters",
+		Long: "Watch the changes of the managed clusters. The managed clusters are printed when they are first seen, " +
+			"when they change and when they are deleted.",
+		Example: "  hohctl watch\n  hohctl watch -l environment=dev -o yaml",
+		RunE: func(command *cobra.Command, names []string) error {
+			return runWatch(command.Context(), command.OutOrStdout(), globalOptions, options, names)
+		},
+	}
+
+	options.addFlags(command)
+
+	return command
+}
+
+func runWatch(ctx context.Context, out io.Writer, globalOptions *globalOptions, options *listOptions,
+	names []string) error {
+	selector, err := options.parse()
+	if err != nil {
+		return err
+	}
+
+	managedClustersClient, err := globalOptions.newClient()
+	if err != nil {
+		return err
+	}
+
+	asObjects := options.output == outputJSON || options.output == outputYAML
+
+	var watcher watch.Interface
+
+	if asObjects {
+		watcher, err = managedClustersClient.Watch(ctx, client.ListOptions{Search: options.search})
+	} else {
+		watcher, err = managedClustersClient.WatchTable(ctx, client.ListOptions{Search: options.search})
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to watch the managed clusters: %w", err)
+	}
+	defer watcher.Stop()
+
+	eventPrinter := &watchEventPrinter{
+		out: out, options: options, selector: selector, names: names,
+		tablePrinter: newTablePrinter(out, options.output == outputWide), printed: map[string]string{},
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, open := <-watcher.ResultChan():
+			if !open {
+				return nil
+			}
+
+			if err := eventPrinter.print(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// watchEventPrinter prints the watch events of the managed clusters. The server sends an ADDED event per managed
+// cluster on each poll of the database, so the managed clusters are printed only if they changed since they were last
+// printed.
+type watchEventPrinter struct {
+	out          io.Writer
+	options      *listOptions
+	selector     labels.Selector
+	names        []string
+	tablePrinter *tablePrinter
+	printed      map[string]string // the last printed representation of each managed cluster, by name
+}
+
+func (printer *watchEventPrinter) print(event watch.Event) error {
+	switch object := event.Object.(type) {
+	case *metav1.Status:
+		return apierrors.FromObject(object) //nolint:wrapcheck // the error of the server
+	case *clusterv1.ManagedCluster:
+		if !printer.selected(event.Type, object.GetName(), object.GetLabels()) ||
+			!printer.changed(event.Type, object.GetName(), object) {
+			return nil
+		}
+
+		if printer.options.output == outputYAML {
+			fmt.Fprintln(printer.out, "---")
+		}
+
+		return printObject(printer.out, printer.options.output, object)
+	case *metav1.Table:
+		for _, row := range object.Rows {
+			objectMetadata, err := rowMetadata(row)
+			if err != nil {
+				return err
+			}
+
+			if !printer.selected(event.Type, objectMetadata.GetName(), objectMetadata.GetLabels()) ||
+				!printer.changed(event.Type, objectMetadata.GetName(), row.Cells) {
+				continue
+			}
+
+			if printer.options.output == outputName {
+				fmt.Fprintf(printer.out, "%s/%s\n", managedClusterResource, objectMetadata.GetName())
+				continue
+			}
+
+			if err := printer.tablePrinter.print(object, []metav1.TableRow{row}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// selected returns true if the managed cluster of the event should be printed. The DELETED events have no labels,
+// they are printed if the managed cluster was printed before, see changed.
+func (printer *watchEventPrinter) selected(eventType watch.EventType, name string,
+	clusterLabels map[string]string) bool {
+	if eventType == watch.Deleted {
+		return selected(name, nil, printer.names, labels.Everything())
+	}
+
+	return selected(name, clusterLabels, printer.names, printer.selector)
+}
+
+// changed returns true if the managed cluster changed since it was last printed, and records it as printed.
+func (printer *watchEventPrinter) changed(eventType watch.EventType, name string, object interface{}) bool {
+	if eventType == watch.Deleted {
+		_, found := printer.printed[name]
+		delete(printer.printed, name)
+
+		return found
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return true
+	}
+
+	if printer.printed[name] == string(data) {
+		return false
+	}
+
+	printer.printed[name] = string(data)
+
+	return true
+}
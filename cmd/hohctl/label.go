@@ -0,0 +1,181 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/client"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+var (
+	errNoLabels          = errors.New("at least one label update is required")
+	errNoManagedClusters = errors.New("at least one managed cluster name, --selector or --all is required")
+)
+
+// labelOptions are the options of the label and unlabel commands.
+type labelOptions struct {
+	selector   string
+	all        bool
+	hubCluster string
+}
+
+func (options *labelOptions) addFlags(command *cobra.Command) {
+	command.Flags().StringVarP(&options.selector, "selector", "l", "",
+		"update the labels of the managed clusters of the label selector, e.g. environment=dev")
+	command.Flags().BoolVar(&options.all, "all", false, "update the labels of all the managed clusters")
+	command.Flags().StringVar(&options.hubCluster, "hub-cluster", "",
+		"the hub cluster of the managed clusters, if clusters with the same name exist in multiple hub clusters")
+}
+
+func newLabelCommand(globalOptions *globalOptions) *cobra.Command {
+	options := &labelOptions{}
+
+	command := &cobra.Command{
+		Use:   "label [NAME...] KEY=VALUE... [KEY-...]",
+		Short: "Add, update or remove labels of managed clusters",
+		Long: "Add, update or remove labels of managed clusters. KEY=VALUE adds or updates a label, KEY- removes it. " +
+			"The labels are applied to the managed clusters on their hub clusters asynchronously.",
+		Example: "  hohctl label cluster1 environment=dev\n  hohctl label -l environment=dev owner=team1 deprecated-",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			names, labelsToAdd, labelsToRemove := parseLabelArgs(args)
+
+			return runPatchLabels(command.Context(), command.OutOrStdout(), globalOptions, options, names,
+				labelsToAdd, labelsToRemove, "labeled")
+		},
+	}
+
+	options.addFlags(command)
+
+	return command
+}
+
+func newUnlabelCommand(globalOptions *globalOptions) *cobra.Command {
+	options := &labelOptions{}
+
+	command := &cobra.Command{
+		Use:   "unlabel (NAME | --selector SELECTOR | --all) KEY...",
+		Short: "Remove labels of managed clusters",
+		Example: "  hohctl unlabel cluster1 environment owner\n" +
+			"  hohctl unlabel -l environment=dev deprecated",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(command *cobra.Command, args []string) error {
+			names := []string{}
+			if options.selector == "" && !options.all {
+				names, args = args[:1], args[1:]
+			}
+
+			return runPatchLabels(command.Context(), command.OutOrStdout(), globalOptions, options, names, nil, args,
+				"unlabeled")
+		},
+	}
+
+	options.addFlags(command)
+
+	return command
+}
+
+// parseLabelArgs returns the names of the managed clusters, the labels to add and the labels to remove of the
+// arguments of the label command, like kubectl label.
+func parseLabelArgs(args []string) ([]string, map[string]string, []string) {
+	names := []string{}
+	labelsToAdd := map[string]string{}
+	labelsToRemove := []string{}
+
+	for _, arg := range args {
+		switch {
+		case strings.Contains(arg, "="):
+			parts := strings.SplitN(arg, "=", 2) //nolint:gomnd // key and value
+			labelsToAdd[parts[0]] = parts[1]
+		case strings.HasSuffix(arg, "-"):
+			labelsToRemove = append(labelsToRemove, strings.TrimSuffix(arg, "-"))
+		default:
+			names = append(names, arg)
+		}
+	}
+
+	return names, labelsToAdd, labelsToRemove
+}
+
+// runPatchLabels patches the labels of the named managed clusters, or of the managed clusters of the selector. The
+// errors of the managed clusters are aggregated, so that a failure does not stop the patches of the others.
+func runPatchLabels(ctx context.Context, out io.Writer, globalOptions *globalOptions, options *labelOptions,
+	names []string, labelsToAdd map[string]string, labelsToRemove []string, verb string) error {
+	if len(labelsToAdd) == 0 && len(labelsToRemove) == 0 {
+		return errNoLabels
+	}
+
+	managedClustersClient, err := globalOptions.newClient()
+	if err != nil {
+		return err
+	}
+
+	if options.selector != "" || options.all {
+		if names, err = selectNames(ctx, managedClustersClient, options.selector, names); err != nil {
+			return err
+		}
+	} else if len(names) == 0 {
+		return errNoManagedClusters
+	}
+
+	errs := []error{}
+
+	for _, name := range names {
+		if _, err := managedClustersClient.PatchLabels(ctx, name, labelsToAdd, labelsToRemove,
+			client.PatchOptions{HubCluster: options.hubCluster}); err != nil {
+			errs = append(errs, fmt.Errorf("failed to update the labels of %s: %w", name, err))
+			continue
+		}
+
+		fmt.Fprintf(out, "%s/%s %s\n", managedClusterResource, name, verb)
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// selectNames returns the names of the managed clusters of the selector, among names if not empty.
+func selectNames(ctx context.Context, managedClustersClient *client.Client, selectorString string,
+	names []string) ([]string, error) {
+	selector, err := labels.Parse(selectorString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector: %w", err)
+	}
+
+	// the Table representation has the metadata of the managed clusters only, enough to match the selector
+	table, err := managedClustersClient.ListTable(ctx, client.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the managed clusters: %w", err)
+	}
+
+	rows, err := filterRows(table.Rows, names, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	selectedNames := make([]string, 0, len(rows))
+	seen := map[string]bool{}
+
+	for _, row := range rows {
+		objectMetadata, err := rowMetadata(row)
+		if err != nil {
+			return nil, err
+		}
+
+		// managed clusters with the same name in multiple hub clusters are patched once, see --hub-cluster
+		if !seen[objectMetadata.GetName()] {
+			seen[objectMetadata.GetName()] = true
+			selectedNames = append(selectedNames, objectMetadata.GetName())
+		}
+	}
+
+	return selectedNames, nil
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// hohctl is the command-line client of the managed clusters API, for fleet operators.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/client"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	environmentVariableServer = "HOH_SERVER"
+	environmentVariableToken  = "HOH_TOKEN"
+)
+
+var errMissingServer = errors.New("the server is not set, use --server or " + environmentVariableServer)
+
+// globalOptions are the options of all the commands.
+type globalOptions struct {
+	server                string
+	token                 string
+	kubeconfig            string
+	kubeContext           string
+	certificateAuthority  string
+	clientCertificate     string
+	clientKey             string
+	insecureSkipTLSVerify bool
+	requestTimeout        time.Duration
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := newRootCommand().ExecuteContext(ctx); err != nil {
+		stop()
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	options := &globalOptions{}
+
+	rootCommand := &cobra.Command{
+		Use:   "hohctl",
+		Short: "hohctl lists, watches and labels the managed clusters of Hub-of-Hubs",
+		Long: "hohctl lists, watches and labels the managed clusters of Hub-of-Hubs through the nonk8s API.\n\n" +
+			"The token is taken from --token, " + environmentVariableToken + " or the current context of the " +
+			"kubeconfig (e.g. after oc login).",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	flags := rootCommand.PersistentFlags()
+	flags.StringVar(&options.server, "server", os.Getenv(environmentVariableServer),
+		"the URL of the API, including the base path, e.g. "+
+			"https://multicloud-console.apps.<cluster>/multicloud/hub-of-hubs-nonk8s-api")
+	flags.StringVar(&options.token, "token", os.Getenv(environmentVariableToken),
+		"the bearer token, taken from the kubeconfig if not set")
+	flags.StringVar(&options.kubeconfig, "kubeconfig", "", "the kubeconfig file to take the token from")
+	flags.StringVar(&options.kubeContext, "context", "", "the kubeconfig context to take the token from")
+	flags.StringVar(&options.certificateAuthority, "certificate-authority", "",
+		"the CA bundle file to verify the server certificate with")
+	flags.StringVar(&options.clientCertificate, "client-certificate", "", "the client certificate file for mTLS")
+	flags.StringVar(&options.clientKey, "client-key", "", "the client key file for mTLS")
+	flags.BoolVar(&options.insecureSkipTLSVerify, "insecure-skip-tls-verify", false,
+		"do not verify the server certificate")
+	flags.DurationVar(&options.requestTimeout, "request-timeout", 0, "the timeout of the requests, 30s if not set")
+
+	rootCommand.AddCommand(
+		newGetCommand(options),
+		newWatchCommand(options),
+		newLabelCommand(options),
+		newUnlabelCommand(options),
+		newStatsCommand(options),
+	)
+
+	return rootCommand
+}
+
+// newClient returns the client of the options.
+func (options *globalOptions) newClient() (*client.Client, error) {
+	if options.server == "" {
+		return nil, errMissingServer
+	}
+
+	config := &client.Config{
+		URL:         options.server,
+		BearerToken: options.token,
+		Insecure:    options.insecureSkipTLSVerify,
+		Timeout:     options.requestTimeout,
+	}
+
+	if config.BearerToken == "" {
+		if err := options.setTokenFromKubeconfig(config); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+
+	for _, file := range []struct {
+		path string
+		data *[]byte
+	}{
+		{path: options.certificateAuthority, data: &config.CAData},
+		{path: options.clientCertificate, data: &config.CertData},
+		{path: options.clientKey, data: &config.KeyData},
+	} {
+		if file.path == "" {
+			continue
+		}
+
+		if *file.data, err = ioutil.ReadFile(file.path); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file.path, err)
+		}
+	}
+
+	managedClustersClient, err := client.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the client: %w", err)
+	}
+
+	return managedClustersClient, nil
+}
+
+// setTokenFromKubeconfig sets the token (or the token file) of the user of the current context of the kubeconfig,
+// loaded like kubectl does (--kubeconfig, KUBECONFIG, ~/.kube/config).
+func (options *globalOptions) setTokenFromKubeconfig(config *client.Config) error {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = options.kubeconfig
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: options.kubeContext}).ClientConfig()
+	if err != nil {
+		if clientcmd.IsEmptyConfig(err) {
+			return nil // no kubeconfig, the server may still accept the request
+		}
+
+		return fmt.Errorf("failed to load the kubeconfig: %w", err)
+	}
+
+	config.BearerToken = restConfig.BearerToken
+	config.BearerTokenFile = restConfig.BearerTokenFile
+
+	return nil
+}
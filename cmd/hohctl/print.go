@@ -0,0 +1,198 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputTable = ""
+	outputWide  = "wide"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputName  = "name"
+
+	noneValue     = "<none>"
+	columnPadding = 3
+)
+
+var errUnknownOutput = errors.New("unknown output format, expected one of wide, json, yaml or name")
+
+// validateOutput returns an error if the output format is not one of the supported formats.
+func validateOutput(output string) error {
+	switch output {
+	case outputTable, outputWide, outputJSON, outputYAML, outputName:
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", errUnknownOutput, output)
+	}
+}
+
+// printObject prints an object as JSON or as YAML.
+func printObject(writer io.Writer, output string, object interface{}) error {
+	data, err := json.MarshalIndent(object, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	if output == outputYAML {
+		if data, err = yaml.JSONToYAML(data); err != nil {
+			return fmt.Errorf("failed to convert to YAML: %w", err)
+		}
+
+		_, err = writer.Write(data)
+	} else {
+		_, err = fmt.Fprintln(writer, string(data))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	return nil
+}
+
+// tablePrinter prints the rows of the Tables returned by the server, like kubectl get. The columns with a priority
+// above 0 are printed only in the wide output. The widths of the columns are kept between the calls of print, so that
+// the rows of the watch events are aligned.
+type tablePrinter struct {
+	writer        io.Writer
+	wide          bool
+	headerPrinted bool
+	widths        []int
+}
+
+func newTablePrinter(writer io.Writer, wide bool) *tablePrinter {
+	return &tablePrinter{writer: writer, wide: wide}
+}
+
+// print prints the rows of the table, the header is printed before the first row.
+func (printer *tablePrinter) print(table *metav1.Table, rows []metav1.TableRow) error {
+	lines := [][]string{}
+
+	if !printer.headerPrinted && len(rows) > 0 {
+		names := []string{}
+
+		for _, column := range table.ColumnDefinitions {
+			if printer.printed(column) {
+				names = append(names, strings.ToUpper(column.Name))
+			}
+		}
+
+		lines = append(lines, names)
+		printer.headerPrinted = true
+	}
+
+	for _, row := range rows {
+		cells := []string{}
+
+		for index, column := range table.ColumnDefinitions {
+			if !printer.printed(column) {
+				continue
+			}
+
+			cell := noneValue
+			if index < len(row.Cells) && row.Cells[index] != nil {
+				cell = fmt.Sprint(row.Cells[index])
+			}
+
+			cells = append(cells, cell)
+		}
+
+		lines = append(lines, cells)
+	}
+
+	for _, cells := range lines {
+		for index, cell := range cells {
+			if index == len(printer.widths) {
+				printer.widths = append(printer.widths, 0)
+			}
+
+			if len(cell) > printer.widths[index] {
+				printer.widths[index] = len(cell)
+			}
+		}
+	}
+
+	for _, cells := range lines {
+		var sb strings.Builder
+
+		for index, cell := range cells {
+			if index == len(cells)-1 {
+				sb.WriteString(cell)
+				break
+			}
+
+			sb.WriteString(cell + strings.Repeat(" ", printer.widths[index]-len(cell)+columnPadding))
+		}
+
+		if _, err := fmt.Fprintln(printer.writer, sb.String()); err != nil {
+			return fmt.Errorf("failed to write: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (printer *tablePrinter) printed(column metav1.TableColumnDefinition) bool {
+	return printer.wide || column.Priority == 0
+}
+
+// rowMetadata returns the metadata of the object of a table row, the server sets it to the PartialObjectMetadata of
+// the managed cluster.
+func rowMetadata(row metav1.TableRow) (*metav1.PartialObjectMetadata, error) {
+	objectMetadata := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(row.Object.Raw, objectMetadata); err != nil {
+		return nil, fmt.Errorf("failed to decode the object of a table row: %w", err)
+	}
+
+	return objectMetadata, nil
+}
+
+// filterRows returns the rows of the managed clusters with the names (all if empty) and with the labels of the
+// selector.
+func filterRows(rows []metav1.TableRow, names []string, selector labels.Selector) ([]metav1.TableRow, error) {
+	filtered := []metav1.TableRow{}
+
+	for _, row := range rows {
+		objectMetadata, err := rowMetadata(row)
+		if err != nil {
+			return nil, err
+		}
+
+		if selected(objectMetadata.GetName(), objectMetadata.GetLabels(), names, selector) {
+			filtered = append(filtered, row)
+		}
+	}
+
+	return filtered, nil
+}
+
+// selected returns true if a managed cluster has one of the names (or names is empty) and the labels of the selector.
+func selected(name string, clusterLabels map[string]string, names []string, selector labels.Selector) bool {
+	if !selector.Matches(labels.Set(clusterLabels)) {
+		return false
+	}
+
+	if len(names) == 0 {
+		return true
+	}
+
+	for _, aName := range names {
+		if aName == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+type statsOptions struct {
+	groupBy []string
+	output  string
+}
+
+func newStatsCommand(globalOptions *globalOptions) *cobra.Command {
+	options := &statsOptions{}
+
+	command := &cobra.Command{
+		Use:   "stats",
+		Short: "Display the number of managed clusters, grouped by hub cluster, version, vendor, label or condition",
+		Example: "  hohctl stats --group-by leafHub\n" +
+			"  hohctl stats --group-by vendor --group-by condition:ManagedClusterConditionAvailable",
+		Args: cobra.NoArgs,
+		RunE: func(command *cobra.Command, _ []string) error {
+			return runStats(command.Context(), command.OutOrStdout(), globalOptions, options)
+		},
+	}
+
+	command.Flags().StringArrayVar(&options.groupBy, "group-by", nil,
+		"a dimension to group by: leafHub, kubernetesVersion, vendor, label:<key> or condition:<type>")
+	command.Flags().StringVarP(&options.output, "output", "o", "", "the output format: json or yaml")
+
+	return command
+}
+
+func runStats(ctx context.Context, out io.Writer, globalOptions *globalOptions, options *statsOptions) error {
+	if options.output != outputTable && options.output != outputJSON && options.output != outputYAML {
+		return fmt.Errorf("%w: %s", errUnknownOutput, options.output)
+	}
+
+	managedClustersClient, err := globalOptions.newClient()
+	if err != nil {
+		return err
+	}
+
+	stats, err := managedClustersClient.Stats(ctx, options.groupBy...)
+	if err != nil {
+		return fmt.Errorf("failed to get the stats of the managed clusters: %w", err)
+	}
+
+	if options.output != outputTable {
+		return printObject(out, options.output, stats)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, columnPadding, ' ', 0)
+
+	header := make([]string, 0, len(stats.GroupBy)+1)
+	for _, groupBy := range stats.GroupBy {
+		header = append(header, strings.ToUpper(groupBy))
+	}
+
+	fmt.Fprintln(writer, strings.Join(append(header, "COUNT"), "\t"))
+
+	for _, group := range stats.Groups {
+		cells := make([]string, 0, len(stats.GroupBy)+1)
+
+		for _, groupBy := range stats.GroupBy {
+			if value := group.Values[groupBy]; value != nil {
+				cells = append(cells, *value)
+			} else {
+				cells = append(cells, noneValue)
+			}
+		}
+
+		fmt.Fprintln(writer, strings.Join(append(cells, fmt.Sprint(group.Count)), "\t"))
+	}
+
+	if len(stats.GroupBy) > 0 {
+		fmt.Fprintf(writer, "TOTAL%s%d\n", strings.Repeat("\t", len(stats.GroupBy)), stats.Total)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write: %w", err)
+	}
+
+	return nil
+}
@@ -5,191 +5,215 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"runtime"
 	"syscall"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-logr/logr"
-	"github.com/go-logr/zapr"
 	"github.com/jackc/pgx/v4/pgxpool"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/spf13/pflag"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/admission"
+	managedclustersv1 "github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/api/managedclusters/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/audit"
 	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/config"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/discovery"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/health"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
 	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/managedclusters"
-	"go.uber.org/zap"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/openapi"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/policies"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/tracing"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/util"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
+	"k8s.io/klog/v2"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 )
 
-const (
-	environmentVariableDatabaseURL               = "DATABASE_URL"
-	environmentVariableClusterAPIURL             = "CLUSTER_API_URL"
-	environmentVariableClusterAPICABundlePath    = "CLUSTER_API_CA_BUNDLE_PATH"
-	environmentVariableAuthorizationURL          = "AUTHORIZATION_URL"
-	environmentVariableAuthorizationCABundlePath = "AUTHORIZATION_CA_BUNDLE_PATH"
-	environmentVariableKeyPath                   = "KEY_PATH"
-	environmentVariableCertificatePath           = "CERTIFICATE_PATH"
-	environmentVariableBasePath                  = "BASE_PATH"
-	secondsToFinishOnShutdown                    = 5
-)
-
-var (
-	errEnvironmentVariableNotFound = errors.New("not found environment variable")
-	errFailedToLoadCertificate     = errors.New("failed to load certificate/key")
-)
-
 func printVersion(log logr.Logger) {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
 }
 
-func newLogger() logr.Logger {
-	zapLog, err := zap.NewDevelopment()
+// loadCertificates returns the certificate of the server and the CA bundles of the cluster API and of the
+// authorization server, they are reloaded by certificates.Watch.
+func loadCertificates(tlsConfig config.TLSConfig, clusterAPICABundlePath,
+	authorizationCABundlePath string) (*certificates.Certificate, *certificates.CABundle, *certificates.CABundle,
+	error) {
+	certificate, err := certificates.LoadCertificate(tlsConfig.CertificatePath, tlsConfig.KeyPath)
 	if err != nil {
-		//nolint:forbidigo
-		fmt.Printf("failed to create zap log: %v\n", err)
-	}
-
-	return zapr.NewLogger(zapLog)
-}
-
-func readEnvironmentVariables() (string, string, string, string, string, string, string, string, error) {
-	databaseURL, found := os.LookupEnv(environmentVariableDatabaseURL)
-	if !found {
-		return "", "", "", "", "", "", "", "", fmt.Errorf("%w: %s",
-			errEnvironmentVariableNotFound, environmentVariableDatabaseURL)
+		return nil, nil, nil, fmt.Errorf("failed to load the certificate of the server: %w", err)
 	}
 
-	clusterAPIURL, found := os.LookupEnv(environmentVariableClusterAPIURL)
-	if !found {
-		return "", "", "", "", "", "", "", "",
-			fmt.Errorf("%w: %s", errEnvironmentVariableNotFound, environmentVariableClusterAPIURL)
-	}
-
-	authorizationURL, found := os.LookupEnv(environmentVariableAuthorizationURL)
-	if !found {
-		return "", "", "", "", "", "", "", "",
-			fmt.Errorf("%w: %s", errEnvironmentVariableNotFound, environmentVariableAuthorizationURL)
+	clusterAPICABundle, err := certificates.LoadCABundle(clusterAPICABundlePath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load the CA bundle of the cluster API: %w", err)
 	}
 
-	clusterAPICABundlePath, found := os.LookupEnv(environmentVariableClusterAPICABundlePath)
-	if !found {
-		clusterAPICABundlePath = ""
+	authorizationCABundle, err := certificates.LoadCABundle(authorizationCABundlePath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to load the CA bundle of the authorization server: %w", err)
 	}
 
-	authorizationCABundlePath, found := os.LookupEnv(environmentVariableAuthorizationCABundlePath)
-	if !found {
-		authorizationCABundlePath = ""
-	}
+	return certificate, clusterAPICABundle, authorizationCABundle, nil
+}
 
-	keyPath, found := os.LookupEnv(environmentVariableKeyPath)
-	if !found {
-		return "", "", "", "", "", "", "", "",
-			fmt.Errorf("%w: %s", errEnvironmentVariableNotFound, environmentVariableKeyPath)
+// function to handle defers with exit, see https://stackoverflow.com/a/27629493/553720.
+func doMain() int {
+	// the errors of the configuration are logged with the default level and format
+	log, err := logging.New(logging.LevelInfo, logging.FormatJSON)
+	if err != nil {
+		//nolint:forbidigo
+		fmt.Printf("failed to create the logger: %v\n", err)
+		return 1
 	}
 
-	certificatePath, found := os.LookupEnv(environmentVariableCertificatePath)
-	if !found {
-		return "", "", "", "", "", "", "", "",
-			fmt.Errorf("%w: %s", errEnvironmentVariableNotFound, environmentVariableCertificatePath)
+	serverConfig, err := config.Load(os.Args[1:])
+	if errors.Is(err, pflag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		log.Error(err, "Failed to load the configuration")
+		return 1
 	}
 
-	basePath, found := os.LookupEnv(environmentVariableBasePath)
-	if !found {
-		basePath = ""
+	configuredLog, err := logging.New(serverConfig.Logging.Level, serverConfig.Logging.Format)
+	if err != nil {
+		log.Error(err, "Failed to create the logger")
+		return 1
 	}
 
-	return databaseURL, clusterAPIURL, clusterAPICABundlePath, authorizationURL, authorizationCABundlePath, keyPath,
-		certificatePath, basePath, nil
-}
+	log = configuredLog
 
-func readCertificates(clusterAPICABundlePath, authorizationCABundlePath, certificatePath,
-	keyPath string) ([]byte, []byte, tls.Certificate, error) {
-	var (
-		clusterAPICABundle    []byte
-		authorizationCABundle []byte
-		certificate           tls.Certificate
-	)
+	logging.SetDefault(log)
+	klog.SetLogger(log)
 
-	if clusterAPICABundlePath != "" {
-		clusterAPICABundle, err := ioutil.ReadFile(clusterAPICABundlePath)
-		if err != nil {
-			return clusterAPICABundle, authorizationCABundle, certificate,
-				fmt.Errorf("%w: %s", errFailedToLoadCertificate, clusterAPICABundlePath)
-		}
-	}
-
-	if authorizationCABundlePath != "" {
-		authorizationCABundle, err := ioutil.ReadFile(authorizationCABundlePath)
-		if err != nil {
-			return clusterAPICABundle, authorizationCABundle, certificate,
-				fmt.Errorf("%w: %s", errFailedToLoadCertificate, authorizationCABundle)
-		}
-	}
+	printVersion(log)
+	log.Info("Effective configuration", "config", serverConfig.Redacted())
 
-	certificate, err := tls.LoadX509KeyPair(certificatePath, keyPath)
+	shutdownTracing, err := tracing.Setup(context.Background(), serverConfig.Tracing.Endpoint,
+		serverConfig.Tracing.Insecure, serverConfig.Tracing.SamplingRatio)
 	if err != nil {
-		return clusterAPICABundle, authorizationCABundle, certificate,
-			fmt.Errorf("%w: %s/%s", errFailedToLoadCertificate, certificatePath, keyPath)
+		log.Error(err, "Failed to set up tracing")
+		return 1
 	}
 
-	return clusterAPICABundle, authorizationCABundle, certificate, nil
-}
+	defer func() {
+		// the spans of the last requests are flushed within the shutdown timeout
+		ctx, cancel := context.WithTimeout(context.Background(), serverConfig.Server.ShutdownTimeout.Duration)
+		defer cancel()
 
-// function to handle defers with exit, see https://stackoverflow.com/a/27629493/553720.
-func doMain() int {
-	log := newLogger()
-	printVersion(log)
+		if err := shutdownTracing(ctx); err != nil {
+			log.Error(err, "Failed to flush the traces")
+		}
+	}()
 
-	databaseURL, clusterAPIURL, clusterAPICABundlePath, authorizationURL, authorizationCABundlePath, keyPath,
-		certificatePath, basePath, err := readEnvironmentVariables()
+	dbConnectionPool, err := connectToDatabase(serverConfig.Database, serverConfig.Tracing.Endpoint != "")
 	if err != nil {
-		log.Error(err, "Failed to read environment variables")
+		log.Error(err, "Failed to connect to the database")
 		return 1
 	}
+	defer dbConnectionPool.Close()
 
-	dbConnectionPool, err := pgxpool.Connect(context.TODO(), databaseURL)
+	if serverConfig.Features.Metrics {
+		if err := metrics.RegisterDatabasePool(dbConnectionPool); err != nil {
+			log.Error(err, "Failed to register the metrics of the database connection pool")
+			return 1
+		}
+	}
+
+	auditor, err := newAuditor(serverConfig.Audit, dbConnectionPool)
 	if err != nil {
-		log.Error(err, "Failed to connect to the database")
+		log.Error(err, "Failed to set up the audit")
 		return 1
 	}
-	defer dbConnectionPool.Close()
+	// the events of the last requests are written once the servers are shut down
+	defer auditor.Close()
 
-	clusterAPICABundle, authorizationCABundle, _, err := readCertificates(clusterAPICABundlePath,
-		authorizationCABundlePath, certificatePath, keyPath)
+	certificate, clusterAPICABundle, authorizationCABundle, err := loadCertificates(serverConfig.TLS,
+		serverConfig.ClusterAPI.CABundlePath, serverConfig.Authorization.CABundlePath)
 	if err != nil {
 		log.Error(err, "Failed to read certificates")
 		return 1
 	}
 
-	srv := createServer(clusterAPIURL, clusterAPICABundle, authorizationURL,
-		authorizationCABundle, dbConnectionPool, basePath)
+	if serverConfig.TLS.ReloadInterval.Duration > 0 {
+		watchCtx, cancelWatch := context.WithCancel(context.Background())
+		defer cancelWatch()
+
+		go certificates.Watch(watchCtx, log, serverConfig.TLS.ReloadInterval.Duration, certificate,
+			clusterAPICABundle, authorizationCABundle)
+	}
+
+	if serverConfig.Cache.AuthenticationTTL.Duration > 0 {
+		sweepCtx, cancelSweep := context.WithCancel(context.Background())
+		defer cancelSweep()
+
+		go authentication.SweepCache(sweepCtx, serverConfig.Cache.AuthenticationTTL.Duration)
+	}
+
+	admissionController := newAdmissionController(serverConfig.Admission, serverConfig.Authorization.URL,
+		authorizationCABundle)
+
+	srv := createServer(serverConfig, log, auditor, admissionController, certificate, clusterAPICABundle,
+		authorizationCABundle, dbConnectionPool)
+	srv.TLSConfig = certificate.TLSConfig()
 
 	// Initializing the server in a goroutine so that it won't block the graceful shutdown handling below
 	go func() {
-		if err := srv.ListenAndServeTLS(certificatePath, keyPath); err != nil && errors.Is(err, http.ErrServerClosed) {
+		// the certificate is served by the TLS configuration, so that it is reloaded
+		if err := srv.ListenAndServeTLS("", ""); err != nil && errors.Is(err, http.ErrServerClosed) {
 			log.Error(err, "listenAndServe returned")
 		}
 	}()
 
+	var grpcServer *grpc.Server
+
+	if serverConfig.Features.GRPC {
+		grpcListener, err := net.Listen("tcp", serverConfig.Server.GRPCAddress)
+		if err != nil {
+			log.Error(err, "Failed to listen for gRPC", "address", serverConfig.Server.GRPCAddress)
+			return 1
+		}
+
+		grpcServer = createGRPCServer(serverConfig, log, auditor, admissionController, clusterAPICABundle,
+			authorizationCABundle, dbConnectionPool, certificate)
+
+		go func() {
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				log.Error(err, "gRPC serve returned")
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info("shutting down server")
 
-	// The context is used to inform the server it has 5 seconds to finish the request it is currently handling
-	ctx, cancel := context.WithTimeout(context.Background(), secondsToFinishOnShutdown*time.Second)
+	// The context is used to inform the server it has the shutdown timeout to finish the requests it is handling
+	ctx, cancel := context.WithTimeout(context.Background(), serverConfig.Server.ShutdownTimeout.Duration)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
-		log.Error(err, "erver forced to shutdown")
+		log.Error(err, "Server forced to shutdown")
+	}
+
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
 	}
 
 	log.Info("Server exiting")
@@ -197,23 +221,244 @@ func doMain() int {
 	return 0
 }
 
-func createServer(clusterAPIURL string, clusterAPICABundle []byte, authorizationURL string,
-	authorizationCABundle []byte, dbConnectionPool *pgxpool.Pool, basePath string) *http.Server {
-	router := gin.Default()
+// connectToDatabase returns a connection pool of the database URL, with the pool settings of the configuration that
+// are set. If traceQueries is true, the queries of the traced requests are recorded as spans.
+func connectToDatabase(databaseConfig config.DatabaseConfig, traceQueries bool) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseConfig.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the database URL: %w", err)
+	}
 
-	router.Use(authentication.Authentication(clusterAPIURL, clusterAPICABundle))
+	if databaseConfig.MaxConnections > 0 {
+		poolConfig.MaxConns = databaseConfig.MaxConnections
+	}
+
+	if databaseConfig.MinConnections > 0 {
+		poolConfig.MinConns = databaseConfig.MinConnections
+	}
+
+	if databaseConfig.MaxConnectionLifetime.Duration > 0 {
+		poolConfig.MaxConnLifetime = databaseConfig.MaxConnectionLifetime.Duration
+	}
+
+	if databaseConfig.MaxConnectionIdleTime.Duration > 0 {
+		poolConfig.MaxConnIdleTime = databaseConfig.MaxConnectionIdleTime.Duration
+	}
+
+	if databaseConfig.HealthCheckPeriod.Duration > 0 {
+		poolConfig.HealthCheckPeriod = databaseConfig.HealthCheckPeriod.Duration
+	}
+
+	if databaseConfig.ConnectTimeout.Duration > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = databaseConfig.ConnectTimeout.Duration
+	}
+
+	if traceQueries {
+		poolConfig.ConnConfig.Logger = tracing.NewQueryLogger()
+	}
+
+	dbConnectionPool, err := pgxpool.ConnectConfig(context.TODO(), poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	return dbConnectionPool, nil
+}
+
+// newAuditor returns the auditor of the configuration, with the file sink if the path is set and the database sink
+// if enabled.
+func newAuditor(auditConfig config.AuditConfig, dbConnectionPool *pgxpool.Pool) (*audit.Auditor, error) {
+	sinks := []audit.Sink{}
+
+	if auditConfig.Path != "" {
+		sinks = append(sinks, audit.FileSink(auditConfig.Path, auditConfig.MaxSize, auditConfig.MaxBackups,
+			auditConfig.MaxAge))
+	}
+
+	if auditConfig.Database {
+		sinks = append(sinks, audit.DatabaseSink(dbConnectionPool))
+	}
+
+	auditor, err := audit.NewAuditor(auditConfig.Level, sinks...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the auditor: %w", err)
+	}
+
+	return auditor, nil
+}
+
+// newAdmissionController returns the admission controller of the changes of the labels, that validates their syntax,
+// the protected labels and the OPA policy if it is set.
+func newAdmissionController(admissionConfig config.AdmissionConfig, authorizationURL string,
+	authorizationCABundle *certificates.CABundle) *admission.Controller {
+	validators := []admission.Validator{
+		admission.LabelSyntaxValidator(),
+		admission.ProtectedLabelsValidator(admissionConfig.ProtectedLabels, admissionConfig.PrivilegedGroups),
+	}
+
+	if admissionConfig.OPAPolicy != "" {
+		validators = append(validators, admission.OPAValidator(authorizationURL, authorizationCABundle,
+			admissionConfig.OPAPolicy))
+	}
+
+	return admission.NewController(validators...)
+}
+
+func createServer(serverConfig *config.Config, log logr.Logger, auditor *audit.Auditor,
+	admissionController *admission.Controller, certificate *certificates.Certificate,
+	clusterAPICABundle *certificates.CABundle, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) *http.Server {
+	clusterAPIURL, authorizationURL := serverConfig.ClusterAPI.URL, serverConfig.Authorization.URL
+	basePath := serverConfig.Server.BasePath
+
+	if serverConfig.Logging.Format == logging.FormatJSON {
+		// the debug messages of gin, e.g. the registered routes, are not JSON
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	// the requests are logged by the logging middleware, rather than by the logger of gin.Default
+	router := gin.New()
+	router.Use(gin.Recovery(), logging.Middleware(log))
+
+	if serverConfig.Features.Metrics {
+		router.Use(metrics.Middleware())
+	}
+
+	// the health checks and the metrics are registered before the authentication middleware, so that the probes and
+	// the scrapes are not authenticated
+	readinessCheckers := []health.Checker{
+		health.PingChecker(),
+		health.DatabaseChecker(dbConnectionPool),
+		health.HTTPChecker("authorization", authorizationURL+"/health", authorizationCABundle),
+		health.HTTPChecker("clusterAPI", clusterAPIURL+"/readyz", clusterAPICABundle),
+		health.CertificateChecker(certificate),
+	}
+
+	router.GET(health.LivezPath, health.Handler("livez", health.PingChecker()))
+	router.GET(health.ReadyzPath, health.Handler("readyz", readinessCheckers...))
+	router.GET(health.HealthzPath, health.Handler("healthz", readinessCheckers...))
+
+	if serverConfig.Features.Metrics {
+		router.GET(metrics.Path, metrics.Handler())
+	}
+
+	// the requests are traced from here, the probes and the scrapes above are not
+	router.Use(tracing.Middleware())
+
+	if auditor.Enabled() {
+		// before the authentication, so that the rejected requests are audited too
+		router.Use(audit.Middleware(auditor))
+	}
+
+	router.Use(authentication.Authentication(clusterAPIURL, clusterAPICABundle,
+		serverConfig.Cache.AuthenticationTTL.Duration))
+
+	listManagedClusters := managedclusters.List(authorizationURL, authorizationCABundle, dbConnectionPool,
+		serverConfig.ManagedClusters.WatchInterval.Duration)
+	getManagedCluster := managedclusters.Get(authorizationURL, authorizationCABundle, dbConnectionPool)
+	patchManagedCluster := managedclusters.Patch(authorizationURL, authorizationCABundle, dbConnectionPool,
+		serverConfig.ManagedClusters.PatchRetryAttempts, serverConfig.ManagedClusters.PatchFallbackToRead,
+		admissionController)
 
 	routerGroup := router.Group(basePath)
-	routerGroup.GET("/managedclusters", managedclusters.List(authorizationURL,
+	routerGroup.GET("/managedclusters", listManagedClusters)
+
+	// outside of /managedclusters/, where they would shadow the managed clusters with the same names
+	routerGroup.GET("/managedclusterstats", managedclusters.Stats(authorizationURL,
 		authorizationCABundle, dbConnectionPool))
+	routerGroup.GET("/authorization/managedclusters", managedclusters.ExplainAuthorization(authorizationURL,
+		authorizationCABundle, dbConnectionPool, serverConfig.ManagedClusters.AuthorizationAdminGroups))
 
-	routerGroup.PATCH("/managedclusters/:cluster", managedclusters.Patch(authorizationURL,
+	routerGroup.GET("/managedclusters/:cluster", getManagedCluster)
+	routerGroup.PATCH("/managedclusters/:cluster", patchManagedCluster)
+	routerGroup.GET("/managedclusters/:cluster/labelhistory", managedclusters.LabelHistory(authorizationURL,
 		authorizationCABundle, dbConnectionPool))
+	routerGroup.POST("/managedclusters/:cluster/labelhistory/:version/revert", managedclusters.RevertLabels(
+		authorizationURL, authorizationCABundle, dbConnectionPool, serverConfig.ManagedClusters.PatchRetryAttempts,
+		serverConfig.ManagedClusters.PatchFallbackToRead, admissionController))
+
+	if serverConfig.Features.KubernetesAPI {
+		// Kubernetes API discovery and paths, so that kubectl can be used with this server
+		routerGroup.GET(discovery.APIPath, discovery.APIVersions())
+		routerGroup.GET(discovery.APIsPath, discovery.APIGroupList())
+		routerGroup.GET(discovery.ClusterGroupPath, discovery.APIGroup())
+		routerGroup.GET(discovery.ClusterGroupVersionPath, discovery.APIResourceList())
+
+		clusterGroupVersion := routerGroup.Group(discovery.ClusterGroupVersionPath)
+		clusterGroupVersion.GET("/managedclusters", listManagedClusters)
+		clusterGroupVersion.GET("/managedclusters/:cluster", getManagedCluster)
+		clusterGroupVersion.PATCH("/managedclusters/:cluster", patchManagedCluster)
+	}
+
+	routerGroup.GET("/policies", policies.List(authorizationURL, authorizationCABundle, dbConnectionPool))
+	routerGroup.GET("/policies/:policy/status", policies.Status(authorizationURL, authorizationCABundle,
+		dbConnectionPool))
+
+	if serverConfig.Features.GraphQL {
+		graphQL := managedclusters.GraphQL(authorizationURL, authorizationCABundle, dbConnectionPool)
+		routerGroup.GET("/graphql", graphQL)
+		routerGroup.POST("/graphql", graphQL)
+	}
+
+	if serverConfig.Features.OpenAPI {
+		// the OpenAPI documents describe all the routes above, including themselves
+		managedClusterSchema := util.GetCustomResourceValidationSchema(managedclusters.CRDName,
+			clusterv1.GroupVersion.Version)
+		routerGroup.GET(openapi.V2Path, openapi.V2(router.Routes, basePath, managedClusterSchema))
+		routerGroup.GET(openapi.V3Path, openapi.V3(router.Routes, basePath, managedClusterSchema))
+	}
 
 	return &http.Server{
-		Addr:    ":8080",
-		Handler: router,
+		Addr:              serverConfig.Server.Address,
+		Handler:           router,
+		ReadTimeout:       serverConfig.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: serverConfig.Server.ReadHeaderTimeout.Duration,
+		WriteTimeout:      serverConfig.Server.WriteTimeout.Duration,
+		IdleTimeout:       serverConfig.Server.IdleTimeout.Duration,
+	}
+}
+
+// createGRPCServer returns the gRPC server of the managed clusters, with the same authentication and certificate as the
+// REST server.
+func createGRPCServer(serverConfig *config.Config, log logr.Logger, auditor *audit.Auditor,
+	admissionController *admission.Controller, clusterAPICABundle *certificates.CABundle,
+	authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool,
+	certificate *certificates.Certificate) *grpc.Server {
+	clusterAPIURL, authenticationCacheTTL := serverConfig.ClusterAPI.URL, serverConfig.Cache.AuthenticationTTL.Duration
+
+	unaryInterceptors := []grpc.UnaryServerInterceptor{
+		otelgrpc.UnaryServerInterceptor(),
+		logging.UnaryServerInterceptor(log),
 	}
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		otelgrpc.StreamServerInterceptor(),
+		logging.StreamServerInterceptor(log),
+	}
+
+	if auditor.Enabled() {
+		// before the authentication, so that the rejected calls are audited too, like the rejected requests
+		unaryInterceptors = append(unaryInterceptors, audit.UnaryServerInterceptor(auditor))
+		streamInterceptors = append(streamInterceptors, audit.StreamServerInterceptor(auditor))
+	}
+
+	unaryInterceptors = append(unaryInterceptors,
+		authentication.UnaryServerInterceptor(clusterAPIURL, clusterAPICABundle, authenticationCacheTTL))
+	streamInterceptors = append(streamInterceptors,
+		authentication.StreamServerInterceptor(clusterAPIURL, clusterAPICABundle, authenticationCacheTTL))
+
+	grpcServer := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(certificate.TLSConfig())),
+		grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...),
+	)
+
+	managedclustersv1.RegisterManagedClustersServer(grpcServer, managedclusters.NewGRPCServer(
+		serverConfig.Authorization.URL, authorizationCABundle, dbConnectionPool,
+		serverConfig.ManagedClusters.WatchInterval.Duration, serverConfig.ManagedClusters.PatchRetryAttempts,
+		serverConfig.ManagedClusters.PatchFallbackToRead, admissionController))
+	reflection.Register(grpcServer)
+
+	return grpcServer
 }
 
 func main() {
@@ -0,0 +1,59 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package admission contains the validating admission of the changes of the labels of the managed clusters. Like the
+// validating admission of the Kubernetes API server, a change is validated by a chain of validators (the syntax of the
+// labels, the protected labels, an OPA policy) before it is written, and is rejected by the first validator that
+// denies it.
+package admission
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalid - the error of the changes of invalid labels, e.g. of label keys that are not qualified names.
+	ErrInvalid = errors.New("invalid labels")
+	// ErrDenied - the error of the changes denied to the user, e.g. of protected labels.
+	ErrDenied = errors.New("the change of the labels is denied")
+)
+
+// Request is a change of the labels of a managed cluster by a user, the input of the OPA policy.
+type Request struct {
+	User           string            `json:"user"`
+	Groups         []string          `json:"groups"`
+	Cluster        string            `json:"cluster"`
+	HubCluster     string            `json:"hubCluster"`
+	LabelsToAdd    map[string]string `json:"labelsToAdd"`
+	LabelsToRemove []string          `json:"labelsToRemove"`
+}
+
+// Validator is a named validation of the changes of the labels. Validate returns an error that wraps ErrInvalid or
+// ErrDenied if the change is rejected, or another error if the change could not be validated.
+type Validator struct {
+	Name     string
+	Validate func(ctx context.Context, request *Request) error
+}
+
+// Controller validates the changes of the labels with its validators, in order.
+type Controller struct {
+	validators []Validator
+}
+
+// NewController returns a controller of the validators.
+func NewController(validators ...Validator) *Controller {
+	return &Controller{validators: validators}
+}
+
+// Validate returns the error of the first validator that rejects the change, nil if the change is admitted.
+func (controller *Controller) Validate(ctx context.Context, request *Request) error {
+	for _, validator := range controller.validators {
+		if err := validator.Validate(ctx, request); err != nil {
+			return fmt.Errorf("%s: %w", validator.Name, err)
+		}
+	}
+
+	return nil
+}
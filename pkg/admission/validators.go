@@ -0,0 +1,131 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package admission
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// LabelSyntaxValidator returns a Validator of the Kubernetes syntax of the labels: the keys are qualified names with
+// an optional DNS subdomain prefix (e.g. example.com/name), the values are empty or at most 63 alphanumeric
+// characters, '-', '_' or '.'.
+func LabelSyntaxValidator() Validator {
+	return Validator{
+		Name: "labelSyntax",
+		Validate: func(_ context.Context, request *Request) error {
+			messages := []string{}
+
+			for _, key := range changedKeys(request) {
+				for _, message := range validation.IsQualifiedName(key) {
+					messages = append(messages, fmt.Sprintf("key %q: %s", key, message))
+				}
+
+				value, added := request.LabelsToAdd[key]
+				if !added {
+					continue
+				}
+
+				for _, message := range validation.IsValidLabelValue(value) {
+					messages = append(messages, fmt.Sprintf("value %q of key %q: %s", value, key, message))
+				}
+			}
+
+			if len(messages) > 0 {
+				return fmt.Errorf("%w: %s", ErrInvalid, strings.Join(messages, "; "))
+			}
+
+			return nil
+		},
+	}
+}
+
+// ProtectedLabelsValidator returns a Validator that denies the changes of the protected labels to the users that are
+// not members of the privileged groups. A protected label is a key (e.g. vendor), or a key prefix ending with /
+// (e.g. cluster.open-cluster-management.io/) that protects the keys of the prefix.
+func ProtectedLabelsValidator(protectedLabels []string, privilegedGroups []string) Validator {
+	return Validator{
+		Name: "protectedLabels",
+		Validate: func(_ context.Context, request *Request) error {
+			for _, group := range request.Groups {
+				for _, privilegedGroup := range privilegedGroups {
+					if group == privilegedGroup {
+						return nil
+					}
+				}
+			}
+
+			keys := []string{}
+
+			for _, key := range changedKeys(request) {
+				if isProtected(key, protectedLabels) {
+					keys = append(keys, key)
+				}
+			}
+
+			if len(keys) > 0 {
+				return fmt.Errorf("%w: the labels %s are protected, only the groups %v may change them", ErrDenied,
+					strings.Join(keys, ", "), privilegedGroups)
+			}
+
+			return nil
+		},
+	}
+}
+
+// OPAValidator returns a Validator that evaluates the OPA document of path (e.g. admission/managedclusters/deny), with
+// the change as input. The document is the set of the reasons to deny the change, the change is admitted if it is
+// empty or undefined.
+func OPAValidator(authorizationURL string, authorizationCABundle *certificates.CABundle, path string) Validator {
+	return Validator{
+		Name: "opa",
+		Validate: func(ctx context.Context, request *Request) error {
+			reasons := []string{}
+
+			if err := authorization.Decide(ctx, authorizationURL, authorizationCABundle, path, request,
+				&reasons); err != nil {
+				return fmt.Errorf("failed to evaluate the admission policy: %w", err)
+			}
+
+			if len(reasons) > 0 {
+				sort.Strings(reasons)
+
+				return fmt.Errorf("%w: %s", ErrDenied, strings.Join(reasons, "; "))
+			}
+
+			return nil
+		},
+	}
+}
+
+// changedKeys returns the keys of the labels to add and to remove, sorted.
+func changedKeys(request *Request) []string {
+	keys := make([]string, 0, len(request.LabelsToAdd)+len(request.LabelsToRemove))
+
+	for key := range request.LabelsToAdd {
+		keys = append(keys, key)
+	}
+
+	keys = append(keys, request.LabelsToRemove...)
+	sort.Strings(keys)
+
+	return keys
+}
+
+// isProtected returns true if the key is a protected label, or has the prefix of a protected label ending with /.
+func isProtected(key string, protectedLabels []string) bool {
+	for _, protectedLabel := range protectedLabels {
+		if key == protectedLabel || (strings.HasSuffix(protectedLabel, "/") && strings.HasPrefix(key, protectedLabel)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,249 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             (unknown)
+// source: pkg/api/managedclusters/v1/managedclusters.proto
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ManagedClustersClient is the client API for ManagedClusters service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ManagedClustersClient interface {
+	// List returns the managed clusters.
+	List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	// Get returns a managed cluster.
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*ManagedCluster, error)
+	// Watch streams the changes of the managed clusters.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (ManagedClusters_WatchClient, error)
+	// PatchLabels adds and removes labels of a managed cluster, and returns the patched managed cluster.
+	PatchLabels(ctx context.Context, in *PatchLabelsRequest, opts ...grpc.CallOption) (*ManagedCluster, error)
+}
+
+type managedClustersClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewManagedClustersClient(cc grpc.ClientConnInterface) ManagedClustersClient {
+	return &managedClustersClient{cc}
+}
+
+func (c *managedClustersClient) List(ctx context.Context, in *ListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/hubofhubs.managedclusters.v1.ManagedClusters/List", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managedClustersClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*ManagedCluster, error) {
+	out := new(ManagedCluster)
+	err := c.cc.Invoke(ctx, "/hubofhubs.managedclusters.v1.ManagedClusters/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *managedClustersClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (ManagedClusters_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ManagedClusters_ServiceDesc.Streams[0], "/hubofhubs.managedclusters.v1.ManagedClusters/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &managedClustersWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ManagedClusters_WatchClient interface {
+	Recv() (*WatchEvent, error)
+	grpc.ClientStream
+}
+
+type managedClustersWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *managedClustersWatchClient) Recv() (*WatchEvent, error) {
+	m := new(WatchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *managedClustersClient) PatchLabels(ctx context.Context, in *PatchLabelsRequest, opts ...grpc.CallOption) (*ManagedCluster, error) {
+	out := new(ManagedCluster)
+	err := c.cc.Invoke(ctx, "/hubofhubs.managedclusters.v1.ManagedClusters/PatchLabels", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ManagedClustersServer is the server API for ManagedClusters service.
+// All implementations must embed UnimplementedManagedClustersServer
+// for forward compatibility
+type ManagedClustersServer interface {
+	// List returns the managed clusters.
+	List(context.Context, *ListRequest) (*ListResponse, error)
+	// Get returns a managed cluster.
+	Get(context.Context, *GetRequest) (*ManagedCluster, error)
+	// Watch streams the changes of the managed clusters.
+	Watch(*WatchRequest, ManagedClusters_WatchServer) error
+	// PatchLabels adds and removes labels of a managed cluster, and returns the patched managed cluster.
+	PatchLabels(context.Context, *PatchLabelsRequest) (*ManagedCluster, error)
+	mustEmbedUnimplementedManagedClustersServer()
+}
+
+// UnimplementedManagedClustersServer must be embedded to have forward compatible implementations.
+type UnimplementedManagedClustersServer struct {
+}
+
+func (UnimplementedManagedClustersServer) List(context.Context, *ListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method List not implemented")
+}
+func (UnimplementedManagedClustersServer) Get(context.Context, *GetRequest) (*ManagedCluster, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedManagedClustersServer) Watch(*WatchRequest, ManagedClusters_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedManagedClustersServer) PatchLabels(context.Context, *PatchLabelsRequest) (*ManagedCluster, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PatchLabels not implemented")
+}
+func (UnimplementedManagedClustersServer) mustEmbedUnimplementedManagedClustersServer() {}
+
+// UnsafeManagedClustersServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ManagedClustersServer will
+// result in compilation errors.
+type UnsafeManagedClustersServer interface {
+	mustEmbedUnimplementedManagedClustersServer()
+}
+
+func RegisterManagedClustersServer(s grpc.ServiceRegistrar, srv ManagedClustersServer) {
+	s.RegisterService(&ManagedClusters_ServiceDesc, srv)
+}
+
+func _ManagedClusters_List_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagedClustersServer).List(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hubofhubs.managedclusters.v1.ManagedClusters/List",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagedClustersServer).List(ctx, req.(*ListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ManagedClusters_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagedClustersServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hubofhubs.managedclusters.v1.ManagedClusters/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagedClustersServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ManagedClusters_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ManagedClustersServer).Watch(m, &managedClustersWatchServer{stream})
+}
+
+type ManagedClusters_WatchServer interface {
+	Send(*WatchEvent) error
+	grpc.ServerStream
+}
+
+type managedClustersWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *managedClustersWatchServer) Send(m *WatchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ManagedClusters_PatchLabels_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PatchLabelsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ManagedClustersServer).PatchLabels(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hubofhubs.managedclusters.v1.ManagedClusters/PatchLabels",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ManagedClustersServer).PatchLabels(ctx, req.(*PatchLabelsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ManagedClusters_ServiceDesc is the grpc.ServiceDesc for ManagedClusters service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ManagedClusters_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hubofhubs.managedclusters.v1.ManagedClusters",
+	HandlerType: (*ManagedClustersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "List",
+			Handler:    _ManagedClusters_List_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _ManagedClusters_Get_Handler,
+		},
+		{
+			MethodName: "PatchLabels",
+			Handler:    _ManagedClusters_PatchLabels_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _ManagedClusters_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pkg/api/managedclusters/v1/managedclusters.proto",
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package audit contains the audit log of the server. Each REST request and each gRPC call, rejected or not, is
+// recorded once completed as a Kubernetes audit.k8s.io/v1 Event, with the user, the impersonator, the source IPs, the
+// verb, the target cluster, the outcome and the request and response timestamps, and with the request and response
+// bodies according to the level. The events are written to the sinks (a rotated file, a database table) in the
+// background.
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+const (
+	// HubClusterAnnotation - the annotation of the events of the hub cluster of the target cluster.
+	HubClusterAnnotation = "hub-of-hubs.open-cluster-management.io/hub-cluster"
+
+	// the events waiting for the sinks, the requests wait when the queue is full so that no event is lost.
+	queueSize = 1000
+	// the maximum size of the recorded request and response bodies, the larger bodies are not recorded.
+	maxBodySize = 64 * 1024
+	// the maximum duration of the write of an event to a sink.
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	errUnknownLevel = errors.New("unknown audit level")
+
+	// the order of the levels, each level records the fields of the lower levels.
+	levelOrder = map[auditv1.Level]int{
+		auditv1.LevelNone:            0,
+		auditv1.LevelMetadata:        1,
+		auditv1.LevelRequest:         2,
+		auditv1.LevelRequestResponse: 3,
+	}
+)
+
+// Sink is a named destination of the audit events. Write records an event, Close (if not nil) releases the sink.
+type Sink struct {
+	Name  string
+	Write func(ctx context.Context, event *auditv1.Event) error
+	Close func() error
+}
+
+// Auditor records the events of the requests in its sinks.
+type Auditor struct {
+	level  auditv1.Level
+	sinks  []Sink
+	events chan *auditv1.Event
+	done   chan struct{}
+	closed bool
+	mutex  sync.RWMutex
+}
+
+// NewAuditor returns an auditor of the level (None, Metadata, Request or RequestResponse) that records the events in
+// the sinks, and starts writing them. The auditor must be closed.
+func NewAuditor(level string, sinks ...Sink) (*Auditor, error) {
+	if _, found := levelOrder[auditv1.Level(level)]; !found {
+		return nil, fmt.Errorf("%w: %s", errUnknownLevel, level)
+	}
+
+	auditor := &Auditor{
+		level:  auditv1.Level(level),
+		sinks:  sinks,
+		events: make(chan *auditv1.Event, queueSize),
+		done:   make(chan struct{}),
+	}
+
+	go auditor.writeEvents()
+
+	return auditor, nil
+}
+
+// Enabled returns true if the events are recorded, i.e. the level is not None and there is a sink.
+func (auditor *Auditor) Enabled() bool {
+	return auditor.level != auditv1.LevelNone && len(auditor.sinks) > 0
+}
+
+// recordsAt returns true if the level of the auditor records the fields of level, e.g. the request bodies of
+// auditv1.LevelRequest.
+func (auditor *Auditor) recordsAt(level auditv1.Level) bool {
+	return levelOrder[auditor.level] >= levelOrder[level]
+}
+
+// Close writes the pending events and closes the sinks. The events of the requests that complete later are dropped.
+func (auditor *Auditor) Close() {
+	auditor.mutex.Lock()
+	if !auditor.closed {
+		auditor.closed = true
+		close(auditor.events)
+	}
+	auditor.mutex.Unlock()
+
+	<-auditor.done
+
+	for _, sink := range auditor.sinks {
+		if sink.Close == nil {
+			continue
+		}
+
+		if err := sink.Close(); err != nil {
+			logging.Default().Error(err, "Failed to close the audit sink", "sink", sink.Name)
+		}
+	}
+}
+
+// record queues the event for the sinks.
+func (auditor *Auditor) record(event *auditv1.Event) {
+	auditor.mutex.RLock()
+	defer auditor.mutex.RUnlock()
+
+	if auditor.closed {
+		logging.Default().Error(nil, "Dropped the audit event of a request completed after the shutdown",
+			"auditID", event.AuditID)
+
+		return
+	}
+
+	auditor.events <- event
+}
+
+// writeEvents writes the queued events to the sinks, until the queue is closed.
+func (auditor *Auditor) writeEvents() {
+	defer close(auditor.done)
+
+	for event := range auditor.events {
+		for _, sink := range auditor.sinks {
+			ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+
+			if err := sink.Write(ctx, event); err != nil {
+				logging.Default().Error(err, "Failed to write the audit event", "sink", sink.Name,
+					"auditID", event.AuditID)
+			}
+
+			cancel()
+		}
+	}
+}
+
+// annotationsContextKey is the key of the annotations of the event of a request in its context.
+type annotationsContextKey struct{}
+
+// annotations are the annotations added by the handlers to the event of their request.
+type annotations struct {
+	values map[string]string
+	mutex  sync.Mutex
+}
+
+// withAnnotations returns a copy of ctx where the handlers can add annotations.
+func withAnnotations(ctx context.Context) (context.Context, *annotations) {
+	requestAnnotations := &annotations{values: map[string]string{}}
+
+	return context.WithValue(ctx, annotationsContextKey{}, requestAnnotations), requestAnnotations
+}
+
+// AddAnnotation adds an annotation to the event of the request of ctx, e.g. HubClusterAnnotation. It does nothing if
+// the request is not audited.
+func AddAnnotation(ctx context.Context, key string, value string) {
+	requestAnnotations, found := ctx.Value(annotationsContextKey{}).(*annotations)
+	if !found {
+		return
+	}
+
+	requestAnnotations.mutex.Lock()
+	defer requestAnnotations.mutex.Unlock()
+
+	requestAnnotations.values[key] = value
+}
+
+// get returns a copy of the annotations, nil if there is none.
+func (requestAnnotations *annotations) get() map[string]string {
+	requestAnnotations.mutex.Lock()
+	defer requestAnnotations.mutex.Unlock()
+
+	if len(requestAnnotations.values) == 0 {
+		return nil
+	}
+
+	values := make(map[string]string, len(requestAnnotations.values))
+	for key, value := range requestAnnotations.values {
+		values[key] = value
+	}
+
+	return values
+}
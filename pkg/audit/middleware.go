@@ -0,0 +1,467 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+const (
+	hubClusterQueryParameter = "hubCluster"
+	userAgentMetadataKey     = "user-agent"
+)
+
+// the audited resources, by the path segment (REST) or the service name (gRPC) of their collection.
+var resources = map[string]schema.GroupVersion{
+	"managedclusters": {Group: "cluster.open-cluster-management.io", Version: "v1"},
+	"policies":        {Group: "policy.open-cluster-management.io", Version: "v1"},
+}
+
+// the verbs of the gRPC methods, the other methods have their lowercase name as verb.
+var grpcVerbs = map[string]string{
+	"List":        "list",
+	"Get":         "get",
+	"Watch":       "watch",
+	"PatchLabels": "patch",
+}
+
+// Middleware records an event for each request once served. It must run before the authentication middleware, so
+// that the rejected requests are recorded too.
+func Middleware(auditor *Auditor) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		received := metav1.NowMicro()
+		_, watch := ginCtx.GetQuery("watch")
+
+		ctx, requestAnnotations := withAnnotations(ginCtx.Request.Context())
+		ginCtx.Request = ginCtx.Request.WithContext(ctx)
+
+		if hubCluster := ginCtx.Query(hubClusterQueryParameter); hubCluster != "" {
+			AddAnnotation(ctx, HubClusterAnnotation, hubCluster)
+		}
+
+		var requestBody []byte
+		if auditor.recordsAt(auditv1.LevelRequest) {
+			requestBody = readBody(ginCtx.Request)
+		}
+
+		// the responses of the watches are streamed, they are not recorded
+		var recorder *bodyRecorder
+		if auditor.recordsAt(auditv1.LevelRequestResponse) && !watch {
+			recorder = &bodyRecorder{ResponseWriter: ginCtx.Writer}
+			ginCtx.Writer = recorder
+		}
+
+		ginCtx.Next()
+
+		objectRef := objectReference(ginCtx.FullPath(), ginCtx.Param)
+		event := auditor.newEvent(ctx, received, ginCtx.Request.RequestURI, verb(ginCtx.Request.Method, objectRef,
+			watch), objectRef)
+
+		event.User, event.ImpersonatedUser = requestUser(ginCtx)
+		event.UserAgent = ginCtx.Request.UserAgent()
+		event.ResponseStatus = responseStatus(ginCtx.Writer.Status(), "")
+		event.RequestObject = jsonObject(requestBody)
+
+		for _, sourceIP := range utilnet.SourceIPs(ginCtx.Request) {
+			event.SourceIPs = append(event.SourceIPs, sourceIP.String())
+		}
+
+		if recorder != nil && !recorder.truncated {
+			event.ResponseObject = jsonObject(recorder.body.Bytes())
+		}
+
+		event.Annotations = requestAnnotations.get()
+		auditor.record(event)
+	}
+}
+
+// UnaryServerInterceptor records an event for each unary gRPC call once served. It must run before the authentication
+// interceptor, so that the unauthenticated calls are recorded too.
+func UnaryServerInterceptor(auditor *Auditor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		received := metav1.NowMicro()
+		ctx, requestAnnotations := withAnnotations(ctx)
+		ctx, recordedUser := authentication.WithUserRecorder(ctx)
+
+		response, err := handler(ctx, request)
+
+		event := auditor.newCallEvent(ctx, received, info.FullMethod, request, recordedUser, err)
+
+		if auditor.recordsAt(auditv1.LevelRequestResponse) && err == nil {
+			event.ResponseObject = protoObject(response)
+		}
+
+		event.Annotations = requestAnnotations.get()
+		auditor.record(event)
+
+		return response, err
+	}
+}
+
+// StreamServerInterceptor records an event for each streaming gRPC call once served, like UnaryServerInterceptor.
+// The streamed responses are not recorded.
+func StreamServerInterceptor(auditor *Auditor) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		received := metav1.NowMicro()
+		ctx, requestAnnotations := withAnnotations(stream.Context())
+		ctx, recordedUser := authentication.WithUserRecorder(ctx)
+		auditedStream := &auditedServerStream{ServerStream: stream, ctx: ctx}
+
+		err := handler(server, auditedStream)
+
+		event := auditor.newCallEvent(ctx, received, info.FullMethod, auditedStream.request, recordedUser, err)
+		event.Annotations = requestAnnotations.get()
+		auditor.record(event)
+
+		return err
+	}
+}
+
+// newEvent returns the event of a request, with the audit ID of the request ID of the logs.
+func (auditor *Auditor) newEvent(ctx context.Context, received metav1.MicroTime, requestURI string, verb string,
+	objectRef *auditv1.ObjectReference) *auditv1.Event {
+	auditID := logging.RequestIDFromContext(ctx)
+	if auditID == "" {
+		auditID = uuid.New().String()
+	}
+
+	return &auditv1.Event{
+		TypeMeta:                 metav1.TypeMeta{Kind: "Event", APIVersion: auditv1.SchemeGroupVersion.String()},
+		Level:                    auditor.level,
+		AuditID:                  types.UID(auditID),
+		Stage:                    auditv1.StageResponseComplete,
+		RequestURI:               requestURI,
+		Verb:                     verb,
+		ObjectRef:                objectRef,
+		RequestReceivedTimestamp: received,
+		StageTimestamp:           metav1.NowMicro(),
+	}
+}
+
+// newCallEvent returns the event of a gRPC call, the method is /package.Service/Method. The user is empty if the call
+// was not authenticated.
+func (auditor *Auditor) newCallEvent(ctx context.Context, received metav1.MicroTime, method string,
+	request interface{}, recordedUser func() (authenticationv1.UserInfo, *authenticationv1.UserInfo),
+	err error) *auditv1.Event {
+	service, methodName := "", method
+
+	if separator := strings.LastIndex(method, "/"); separator >= 0 {
+		service, methodName = strings.TrimPrefix(method[:separator], "/"), method[separator+1:]
+	}
+
+	verb, found := grpcVerbs[methodName]
+	if !found {
+		verb = strings.ToLower(methodName)
+	}
+
+	var objectRef *auditv1.ObjectReference
+
+	// the resource of the service, e.g. managedclusters.v1.ManagedClusters serves managedclusters
+	resource := strings.ToLower(service[strings.LastIndex(service, ".")+1:])
+	if groupVersion, found := resources[resource]; found {
+		objectRef = &auditv1.ObjectReference{
+			Resource:   resource,
+			APIGroup:   groupVersion.Group,
+			APIVersion: groupVersion.Version,
+		}
+
+		if namedRequest, ok := request.(interface{ GetName() string }); ok {
+			objectRef.Name = namedRequest.GetName()
+		}
+	}
+
+	if hubClusterRequest, ok := request.(interface{ GetHubCluster() string }); ok &&
+		hubClusterRequest.GetHubCluster() != "" {
+		AddAnnotation(ctx, HubClusterAnnotation, hubClusterRequest.GetHubCluster())
+	}
+
+	event := auditor.newEvent(ctx, received, method, verb, objectRef)
+	event.User, event.ImpersonatedUser = eventUser(recordedUser())
+	event.ResponseStatus = responseStatus(httpStatusFromCode(status.Code(err)), status.Convert(err).Message())
+
+	if remote, found := peer.FromContext(ctx); found && remote.Addr != nil {
+		host, _, splitErr := net.SplitHostPort(remote.Addr.String())
+		if splitErr != nil {
+			host = remote.Addr.String()
+		}
+
+		event.SourceIPs = []string{host}
+	}
+
+	if incomingMetadata, found := metadata.FromIncomingContext(ctx); found {
+		if userAgents := incomingMetadata.Get(userAgentMetadataKey); len(userAgents) > 0 {
+			event.UserAgent = userAgents[0]
+		}
+	}
+
+	if auditor.recordsAt(auditv1.LevelRequest) {
+		event.RequestObject = protoObject(request)
+	}
+
+	return event
+}
+
+// requestUser returns the user of the event of a request and, if impersonated, the impersonated user. The user is
+// empty if the request was not authenticated.
+func requestUser(ginCtx *gin.Context) (authenticationv1.UserInfo, *authenticationv1.UserInfo) {
+	user := authenticationv1.UserInfo{
+		Username: ginCtx.GetString(authentication.UserKey),
+		Groups:   ginCtx.GetStringSlice(authentication.GroupsKey),
+	}
+
+	var impersonator *authenticationv1.UserInfo
+	if value, found := ginCtx.Get(authentication.ImpersonatorKey); found {
+		if impersonatorInfo, ok := value.(authenticationv1.UserInfo); ok {
+			impersonator = &impersonatorInfo
+		}
+	}
+
+	return eventUser(user, impersonator)
+}
+
+// eventUser returns the user and the impersonated user of an event: the user of an impersonated request is the
+// impersonator, the impersonated user is nil if the request was not impersonated.
+func eventUser(user authenticationv1.UserInfo,
+	impersonator *authenticationv1.UserInfo) (authenticationv1.UserInfo, *authenticationv1.UserInfo) {
+	if impersonator != nil {
+		return *impersonator, &user
+	}
+
+	return user, nil
+}
+
+// objectReference returns the audited resource of the route template (e.g. /managedclusters/:cluster), nil if the
+// route does not serve a resource.
+func objectReference(route string, param func(string) string) *auditv1.ObjectReference {
+	segments := strings.Split(route, "/")
+
+	for index, segment := range segments {
+		groupVersion, found := resources[segment]
+		if !found {
+			continue
+		}
+
+		objectRef := &auditv1.ObjectReference{
+			Resource:   segment,
+			APIGroup:   groupVersion.Group,
+			APIVersion: groupVersion.Version,
+		}
+
+		rest := segments[index+1:]
+
+		if len(rest) > 0 && strings.HasPrefix(rest[0], ":") {
+			objectRef.Name = param(strings.TrimPrefix(rest[0], ":"))
+			rest = rest[1:]
+		}
+
+		if len(rest) > 0 {
+			objectRef.Subresource = rest[0]
+		}
+
+		return objectRef
+	}
+
+	return nil
+}
+
+// verb returns the Kubernetes verb of a request of a resource, or the lowercase method of the other requests.
+func verb(method string, objectRef *auditv1.ObjectReference, watch bool) string {
+	if objectRef == nil {
+		return strings.ToLower(method)
+	}
+
+	switch method {
+	case http.MethodGet:
+		if watch {
+			return "watch"
+		}
+
+		if objectRef.Name == "" && objectRef.Subresource == "" {
+			return "list"
+		}
+
+		return "get"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return strings.ToLower(method)
+	}
+}
+
+// responseStatus returns the status of the event of a response of the HTTP status code.
+func responseStatus(code int, message string) *metav1.Status {
+	responseStatus := &metav1.Status{Status: metav1.StatusSuccess, Code: int32(code)}
+
+	if code >= http.StatusBadRequest {
+		responseStatus.Status = metav1.StatusFailure
+		responseStatus.Message = message
+	}
+
+	return responseStatus
+}
+
+// httpStatusFromCode returns the HTTP status code of the gRPC status code, as mapped by the gRPC gateways.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // the client closed the request, as mapped by nginx
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// jsonObject returns the object of the event of a JSON body, nil if the body is empty or not JSON (e.g. YAML).
+func jsonObject(body []byte) *runtime.Unknown {
+	if len(body) == 0 || !json.Valid(body) {
+		return nil
+	}
+
+	return &runtime.Unknown{Raw: body, ContentType: runtime.ContentTypeJSON}
+}
+
+// protoObject returns the object of the event of a gRPC message, as JSON.
+func protoObject(message interface{}) *runtime.Unknown {
+	protoMessage, ok := message.(proto.Message)
+	if !ok {
+		return nil
+	}
+
+	body, err := protojson.Marshal(protoMessage)
+	if err != nil || len(body) > maxBodySize {
+		return nil
+	}
+
+	return jsonObject(body)
+}
+
+// readBody returns the body of the request, nil if it is larger than maxBodySize, and leaves the body readable by
+// the handlers.
+func readBody(request *http.Request) []byte {
+	if request.Body == nil || request.Body == http.NoBody {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(request.Body, maxBodySize+1))
+	request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), request.Body), request.Body}
+
+	if err != nil || len(body) > maxBodySize {
+		return nil
+	}
+
+	return body
+}
+
+// bodyRecorder is a gin.ResponseWriter that records the body of the response, up to maxBodySize.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (recorder *bodyRecorder) Write(data []byte) (int, error) {
+	recorder.record(data)
+
+	return recorder.ResponseWriter.Write(data) //nolint:wrapcheck // the writer is transparent
+}
+
+func (recorder *bodyRecorder) WriteString(data string) (int, error) {
+	recorder.record([]byte(data))
+
+	return recorder.ResponseWriter.WriteString(data) //nolint:wrapcheck // the writer is transparent
+}
+
+func (recorder *bodyRecorder) record(data []byte) {
+	if recorder.truncated {
+		return
+	}
+
+	if recorder.body.Len()+len(data) > maxBodySize {
+		recorder.truncated = true
+		recorder.body.Reset()
+
+		return
+	}
+
+	recorder.body.Write(data)
+}
+
+// auditedServerStream is a server stream with the context of the audited call, that keeps the first received
+// message, the request of the server-streaming calls.
+type auditedServerStream struct {
+	grpc.ServerStream
+	ctx     context.Context
+	request interface{}
+}
+
+func (stream *auditedServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func (stream *auditedServerStream) RecvMsg(message interface{}) error {
+	err := stream.ServerStream.RecvMsg(message)
+	if err == nil && stream.request == nil {
+		stream.request = message
+	}
+
+	return err //nolint:wrapcheck // the stream is transparent
+}
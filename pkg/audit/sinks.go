@@ -0,0 +1,84 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"gopkg.in/natefinch/lumberjack.v2"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+)
+
+// FileSink returns a Sink that writes the events as JSON lines to the file of path, like the log backend of the
+// Kubernetes API server. The file is rotated when it reaches maxSize megabytes, the rotated files are removed after
+// maxAge days or beyond maxBackups files (0 keeps them).
+func FileSink(path string, maxSize int, maxBackups int, maxAge int) Sink {
+	file := &lumberjack.Logger{
+		Filename:   path,
+		MaxSize:    maxSize,
+		MaxBackups: maxBackups,
+		MaxAge:     maxAge,
+	}
+
+	return Sink{
+		Name: "file",
+		Write: func(_ context.Context, event *auditv1.Event) error {
+			line, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the audit event: %w", err)
+			}
+
+			if _, err := file.Write(append(line, '\n')); err != nil {
+				return fmt.Errorf("failed to write the audit event: %w", err)
+			}
+
+			return nil
+		},
+		Close: file.Close,
+	}
+}
+
+// DatabaseSink returns a Sink that inserts the events in the audit.events table, with the searched fields of the
+// events (e.g. the user and the cluster) in columns.
+func DatabaseSink(dbConnectionPool *pgxpool.Pool) Sink {
+	return Sink{
+		Name: "database",
+		Write: func(ctx context.Context, event *auditv1.Event) error {
+			payload, err := json.Marshal(event)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the audit event: %w", err)
+			}
+
+			// the user of the request is the impersonated user, if any
+			user, impersonator := event.User.Username, ""
+			if event.ImpersonatedUser != nil {
+				user, impersonator = event.ImpersonatedUser.Username, event.User.Username
+			}
+
+			resource, cluster := "", ""
+			if event.ObjectRef != nil {
+				resource, cluster = event.ObjectRef.Resource, event.ObjectRef.Name
+			}
+
+			var code int32
+			if event.ResponseStatus != nil {
+				code = event.ResponseStatus.Code
+			}
+
+			if _, err := dbConnectionPool.Exec(ctx, `INSERT INTO audit.events (audit_id, stage_timestamp, username,
+				impersonator, verb, resource, cluster, hub_cluster, code, latency, payload)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`, string(event.AuditID),
+				event.StageTimestamp.Time, user, impersonator, event.Verb, resource, cluster,
+				event.Annotations[HubClusterAnnotation], code,
+				event.StageTimestamp.Sub(event.RequestReceivedTimestamp.Time), payload); err != nil {
+				return fmt.Errorf("failed to insert the audit event: %w", err)
+			}
+
+			return nil
+		},
+	}
+}
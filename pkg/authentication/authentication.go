@@ -5,16 +5,19 @@ package authentication
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	userv1 "github.com/openshift/api/user/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	authenticationv1 "k8s.io/api/authentication/v1"
 )
 
 const (
@@ -22,15 +25,20 @@ const (
 	UserKey = "user"
 	// GroupsKey - the key for groups slice of strings in context.
 	GroupsKey = "groups"
-)
+	// ImpersonatorKey - the key for the authenticationv1.UserInfo of the user that impersonates the user of UserKey in
+	// context, set only for the impersonated requests.
+	ImpersonatorKey = "impersonator"
 
-var errUnableToAppendCABundle = errors.New("unable to append CA Bundle")
+	impersonateUserHeader  = "Impersonate-User"
+	impersonateGroupHeader = "Impersonate-Group"
+)
 
 // Authentication middleware.
-func Authentication(clusterAPIURL string, clusterAPICABundle []byte) gin.HandlerFunc {
+func Authentication(clusterAPIURL string, clusterAPICABundle *certificates.CABundle,
+	cacheTTL time.Duration) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
 		authorizationHeader := ginCtx.GetHeader("Authorization")
-		if !setAuthenticatedUser(ginCtx, authorizationHeader, clusterAPIURL, clusterAPICABundle) {
+		if !setAuthenticatedUser(ginCtx, authorizationHeader, clusterAPIURL, clusterAPICABundle, cacheTTL) {
 			ginCtx.Header("WWW-Authenticate", "")
 			ginCtx.AbortWithStatus(http.StatusUnauthorized)
 
@@ -41,75 +49,115 @@ func Authentication(clusterAPIURL string, clusterAPICABundle []byte) gin.Handler
 	}
 }
 
-func createClient(clusterAPICABundle []byte) (*http.Client, error) {
-	tlsConfig := &tls.Config{
-		//nolint:gosec
-		InsecureSkipVerify: true,
+func setAuthenticatedUser(ginCtx *gin.Context, authorizationHeader string, clusterAPIURL string,
+	clusterAPICABundle *certificates.CABundle, cacheTTL time.Duration) bool {
+	ctx, span := tracing.Start(ginCtx.Request.Context(), "authentication.setAuthenticatedUser")
+	defer span.End()
+
+	user, impersonator, authenticated := authenticateImpersonation(ctx, authorizationHeader,
+		impersonationHeaders(ginCtx.GetHeader(impersonateUserHeader), ginCtx.Request.Header[impersonateGroupHeader]),
+		clusterAPIURL, clusterAPICABundle, cacheTTL)
+	if !authenticated {
+		return false
 	}
 
-	if clusterAPICABundle != nil {
-		rootCAs := x509.NewCertPool()
-		if ok := rootCAs.AppendCertsFromPEM(clusterAPICABundle); !ok {
-			fmt.Fprintf(gin.DefaultWriter, "unable to append cluster API CA Bundle")
-			return nil, fmt.Errorf("unable to append cluster API CA Bundle %w", errUnableToAppendCABundle)
-		}
+	span.SetAttributes(semconv.EnduserIDKey.String(user.Name))
 
-		tlsConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			RootCAs:    rootCAs,
-		}
+	if impersonator != nil {
+		logging.WithValues(ginCtx, "user", user.Name, "impersonator", impersonator.Username)
+		ginCtx.Set(ImpersonatorKey, *impersonator)
+	} else {
+		logging.WithValues(ginCtx, "user", user.Name)
 	}
 
-	tr := &http.Transport{TLSClientConfig: tlsConfig}
+	ginCtx.Set(UserKey, user.Name)
+	ginCtx.Set(GroupsKey, user.Groups)
 
-	return &http.Client{Transport: tr}, nil
+	return true
 }
 
-func setAuthenticatedUser(ginCtx *gin.Context, authorizationHeader string, clusterAPIURL string,
-	clusterAPICABundle []byte) bool {
-	client, err := createClient(clusterAPICABundle)
-	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "unable to create client: %v\n", err)
+// impersonationHeaders returns the impersonation headers to forward to the cluster API, nil if no user is
+// impersonated.
+func impersonationHeaders(impersonatedUser string, impersonatedGroups []string) http.Header {
+	if impersonatedUser == "" {
+		return nil
+	}
+
+	impersonation := http.Header{impersonateUserHeader: []string{impersonatedUser}}
+	if len(impersonatedGroups) > 0 {
+		impersonation[impersonateGroupHeader] = impersonatedGroups
+	}
+
+	return impersonation
+}
+
+// authenticateImpersonation returns the user of the authorization header or, if impersonation is not nil, the
+// impersonated user and the impersonator. The cluster API authorizes the impersonation.
+func authenticateImpersonation(ctx context.Context, authorizationHeader string, impersonation http.Header,
+	clusterAPIURL string, clusterAPICABundle *certificates.CABundle,
+	cacheTTL time.Duration) (*userv1.User, *authenticationv1.UserInfo, bool) {
+	user, authenticated := authenticateWithCache(ctx, authorizationHeader, nil, clusterAPIURL, clusterAPICABundle,
+		cacheTTL)
+	if !authenticated || impersonation == nil {
+		return user, nil, authenticated
 	}
 
-	req, err := http.NewRequestWithContext(context.TODO(), "GET", fmt.Sprintf("%s/apis/user.openshift.io/v1/users/~",
+	impersonatedUser, authenticated := authenticateWithCache(ctx, authorizationHeader, impersonation, clusterAPIURL,
+		clusterAPICABundle, cacheTTL)
+	if !authenticated {
+		return nil, nil, false
+	}
+
+	return impersonatedUser, &authenticationv1.UserInfo{Username: user.Name, Groups: user.Groups}, true
+}
+
+// authenticate returns the user of the authorization header (a bearer token), as reviewed by the cluster API, or the
+// user of the impersonation headers if not nil.
+func authenticate(ctx context.Context, authorizationHeader string, impersonation http.Header, clusterAPIURL string,
+	clusterAPICABundle *certificates.CABundle) (*userv1.User, bool) {
+	log := logging.FromContext(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/apis/user.openshift.io/v1/users/~",
 		clusterAPIURL), nil)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "unable to create request: %v\n", err)
+		log.Error(err, "Unable to create the authentication request")
+		return nil, false
 	}
 
 	req.Header.Add("Authorization", authorizationHeader)
 
-	resp, err := client.Do(req)
+	for key, values := range impersonation {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	resp, err := clusterAPICABundle.Client().Do(req)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "got authentication error: %v\n", err)
-		return false
+		log.Error(err, "Failed to authenticate by the cluster API")
+		return nil, false
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return false
+		return nil, false
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "unable to read authentication response body: %v\n", err)
-		return false
+		log.Error(err, "Unable to read the authentication response body")
+		return nil, false
 	}
 
-	user := userv1.User{}
+	user := &userv1.User{}
 
-	err = json.Unmarshal(body, &user)
+	err = json.Unmarshal(body, user)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "failed to unmarshall json: %v\n", err)
-		return false
+		log.Error(err, "Failed to unmarshal the authenticated user")
+		return nil, false
 	}
 
-	ginCtx.Set(UserKey, user.Name)
-	ginCtx.Set(GroupsKey, user.Groups)
+	log.V(1).Info("Authenticated user", "user", user.Name, "groups", user.Groups)
 
-	fmt.Fprintf(gin.DefaultWriter, "got authenticated user: %v\n", user.Name)
-	fmt.Fprintf(gin.DefaultWriter, "user groups: %v\n", user.Groups)
-
-	return true
+	return user, true
 }
@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	userv1 "github.com/openshift/api/user/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+)
+
+// cachedUser is a user authenticated by the cluster API, until its expiration.
+type cachedUser struct {
+	user       *userv1.User
+	expiration time.Time
+}
+
+var (
+	// the users authenticated so far, by the SHA-256 of the authorization header and of the impersonation headers, so
+	// that the tokens are not kept.
+	authenticatedUsers      = map[[sha256.Size]byte]cachedUser{}
+	authenticatedUsersMutex sync.Mutex
+)
+
+// authenticateWithCache returns the user of the authorization header (and of the impersonation headers, if not nil),
+// from the cache if it was authenticated less than cacheTTL ago. Only the successful authentications are cached, a
+// cacheTTL of 0 disables the cache. A cached token stays valid until it expires from the cache, even if it is revoked
+// or the groups of its user change.
+func authenticateWithCache(ctx context.Context, authorizationHeader string, impersonation http.Header,
+	clusterAPIURL string, clusterAPICABundle *certificates.CABundle, cacheTTL time.Duration) (*userv1.User, bool) {
+	if cacheTTL <= 0 {
+		return observedAuthenticate(ctx, authorizationHeader, impersonation, clusterAPIURL, clusterAPICABundle)
+	}
+
+	key := cacheKey(authorizationHeader, impersonation)
+	now := time.Now()
+
+	authenticatedUsersMutex.Lock()
+	cached, found := authenticatedUsers[key]
+	authenticatedUsersMutex.Unlock()
+
+	if found && now.Before(cached.expiration) {
+		metrics.ObserveAuthenticationCache(true)
+		return cached.user, true
+	}
+
+	metrics.ObserveAuthenticationCache(false)
+
+	user, authenticated := observedAuthenticate(ctx, authorizationHeader, impersonation, clusterAPIURL,
+		clusterAPICABundle)
+
+	if authenticated {
+		authenticatedUsersMutex.Lock()
+		authenticatedUsers[key] = cachedUser{user: user, expiration: now.Add(cacheTTL)}
+		authenticatedUsersMutex.Unlock()
+	}
+
+	return user, authenticated
+}
+
+// SweepCache removes the expired users from the cache every interval until the context is done, so that the cache does
+// not grow with the old tokens. The expired users are ignored by the lookups until they are removed.
+func SweepCache(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			authenticatedUsersMutex.Lock()
+
+			for key, cached := range authenticatedUsers {
+				if !now.Before(cached.expiration) {
+					delete(authenticatedUsers, key)
+				}
+			}
+
+			authenticatedUsersMutex.Unlock()
+		}
+	}
+}
+
+// cacheKey returns the key of the cached user of the authorization and impersonation headers. The header values cannot
+// contain newlines, so that the key is unambiguous.
+func cacheKey(authorizationHeader string, impersonation http.Header) [sha256.Size]byte {
+	values := []string{authorizationHeader}
+
+	if impersonation != nil {
+		values = append(values, impersonation.Get(impersonateUserHeader))
+		values = append(values, impersonation.Values(impersonateGroupHeader)...)
+	}
+
+	return sha256.Sum256([]byte(strings.Join(values, "\n")))
+}
+
+// observedAuthenticate authenticates by the cluster API and records the latency of the authentication.
+func observedAuthenticate(ctx context.Context, authorizationHeader string, impersonation http.Header,
+	clusterAPIURL string, clusterAPICABundle *certificates.CABundle) (*userv1.User, bool) {
+	start := time.Now()
+	user, authenticated := authenticate(ctx, authorizationHeader, impersonation, clusterAPIURL, clusterAPICABundle)
+
+	metrics.ObserveAuthentication(time.Since(start), authenticated)
+
+	return user, authenticated
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package authentication
+
+import (
+	"context"
+	"time"
+
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+// the metadata keys of the bearer token and of the impersonation of gRPC calls, gRPC metadata keys are lowercase.
+const (
+	authorizationMetadataKey    = "authorization"
+	impersonateUserMetadataKey  = "impersonate-user"
+	impersonateGroupMetadataKey = "impersonate-group"
+)
+
+type contextKey string
+
+const (
+	userContextKey         = contextKey(UserKey)
+	groupsContextKey       = contextKey(GroupsKey)
+	userRecorderContextKey = contextKey("userRecorder")
+)
+
+// userRecorder is the user of a gRPC call and, if impersonated, the impersonator, recorded by the authentication for
+// the interceptors that run before it.
+type userRecorder struct {
+	user         authenticationv1.UserInfo
+	impersonator *authenticationv1.UserInfo
+}
+
+// UnaryServerInterceptor authenticates the unary gRPC calls by the bearer token of the authorization metadata.
+func UnaryServerInterceptor(clusterAPIURL string, clusterAPICABundle *certificates.CABundle,
+	cacheTTL time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, _ *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		authenticatedCtx, err := authenticateCall(ctx, clusterAPIURL, clusterAPICABundle, cacheTTL)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(authenticatedCtx, request)
+	}
+}
+
+// StreamServerInterceptor authenticates the streaming gRPC calls by the bearer token of the authorization metadata.
+func StreamServerInterceptor(clusterAPIURL string, clusterAPICABundle *certificates.CABundle,
+	cacheTTL time.Duration) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		authenticatedCtx, err := authenticateCall(stream.Context(), clusterAPIURL, clusterAPICABundle, cacheTTL)
+		if err != nil {
+			return err
+		}
+
+		return handler(server, &authenticatedServerStream{ServerStream: stream, ctx: authenticatedCtx})
+	}
+}
+
+// UserFromContext returns the user and the groups of an authenticated gRPC call.
+func UserFromContext(ctx context.Context) (string, []string, bool) {
+	user, userFound := ctx.Value(userContextKey).(string)
+	groups, groupsFound := ctx.Value(groupsContextKey).([]string)
+
+	return user, groups, userFound && groupsFound
+}
+
+// WithUserRecorder returns a context in which the authentication interceptors record the user of the gRPC call, and a
+// function that returns the recorded user and, if impersonated, the impersonator. It lets the interceptors that run
+// before the authentication (e.g. the audit) get the user of the calls once served, and see the unauthenticated calls
+// too, with an empty user.
+func WithUserRecorder(ctx context.Context) (context.Context,
+	func() (authenticationv1.UserInfo, *authenticationv1.UserInfo)) {
+	recorder := &userRecorder{}
+
+	return context.WithValue(ctx, userRecorderContextKey, recorder),
+		func() (authenticationv1.UserInfo, *authenticationv1.UserInfo) {
+			return recorder.user, recorder.impersonator
+		}
+}
+
+func authenticateCall(ctx context.Context, clusterAPIURL string, clusterAPICABundle *certificates.CABundle,
+	cacheTTL time.Duration) (context.Context, error) {
+	incomingMetadata, _ := metadata.FromIncomingContext(ctx)
+
+	authorizationValues := incomingMetadata.Get(authorizationMetadataKey)
+	if len(authorizationValues) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+
+	spanCtx, span := tracing.Start(ctx, "authentication.authenticateCall")
+	defer span.End()
+
+	impersonatedUser := ""
+	if impersonatedUsers := incomingMetadata.Get(impersonateUserMetadataKey); len(impersonatedUsers) > 0 {
+		impersonatedUser = impersonatedUsers[0]
+	}
+
+	user, impersonator, authenticated := authenticateImpersonation(spanCtx, authorizationValues[0],
+		impersonationHeaders(impersonatedUser, incomingMetadata.Get(impersonateGroupMetadataKey)), clusterAPIURL,
+		clusterAPICABundle, cacheTTL)
+	if !authenticated {
+		return nil, status.Error(codes.Unauthenticated, "invalid bearer token")
+	}
+
+	span.SetAttributes(semconv.EnduserIDKey.String(user.Name))
+
+	groups := user.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+
+	log := logging.FromContext(ctx).WithValues("user", user.Name)
+
+	if impersonator != nil {
+		log = log.WithValues("impersonator", impersonator.Username)
+	}
+
+	if recorder, found := ctx.Value(userRecorderContextKey).(*userRecorder); found {
+		recorder.user = authenticationv1.UserInfo{Username: user.Name, Groups: groups}
+		recorder.impersonator = impersonator
+	}
+
+	ctx = logging.IntoContext(ctx, log)
+	ctx = context.WithValue(ctx, userContextKey, user.Name)
+
+	return context.WithValue(ctx, groupsContextKey, groups), nil
+}
+
+// authenticatedServerStream is a server stream with the context of the authenticated call.
+type authenticatedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *authenticatedServerStream) Context() context.Context {
+	return stream.ctx
+}
@@ -1,13 +1,11 @@
 // Copyright (c) 2021 Red Hat, Inc.
 // Copyright Contributors to the Open Cluster Management project
 
-package managedclusters
+package authorization
 
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,8 +13,15 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/go-logr/logr"
 	opatypes "github.com/open-policy-agent/opa/server/types"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -35,13 +40,23 @@ const (
 	negatedAttribute = "negated"
 	termsAttribute   = "terms"
 
-	inputVariable   = "input"
-	clusterVariable = "cluster"
-
-	opaQuery = "data.rbac.clusters.allow == true"
+	inputVariable = "input"
 
 	termsArraySize                = 3 // should contain operator, first operand, second operand
-	minReferencedVariablePathSize = 2 // must contain at least 'input.cluster'
+	minReferencedVariablePathSize = 2 // must contain at least 'input.<unknown>'
+)
+
+const (
+	// ClustersQuery - the OPA query that decides whether a managed cluster may be accessed.
+	ClustersQuery = "data.rbac.clusters.allow == true"
+	// ClusterUnknown - the input attribute of ClustersQuery that holds the managed cluster.
+	ClusterUnknown = "cluster"
+	// ClustersPatchDecision - the OPA document that decides whether the labels of a managed cluster may be changed.
+	ClustersPatchDecision = "rbac/clusters/patch"
+	// PoliciesQuery - the OPA query that decides whether a policy may be accessed.
+	PoliciesQuery = "data.rbac.policies.allow == true"
+	// PolicyUnknown - the input attribute of PoliciesQuery that holds the policy.
+	PolicyUnknown = "policy"
 )
 
 var (
@@ -54,69 +69,139 @@ var (
 	errUnexpectedValue           = errors.New("value not as expected")
 	errMissingAttribute          = errors.New("missing attribute")
 	errStringsBuilderWriteString = errors.New("strings.Builder WriteString returned error")
-	errUnableToAppendCABundle    = errors.New("unable to append CA bundle")
 )
 
-func filterByAuthorization(user string, groups []string, authorizationURL string, authorizationCABundle []byte,
-	logWriter io.Writer) string {
-	compileResponse, err := getPartialEvaluation(user, groups, authorizationURL, authorizationCABundle)
+// the attributes of the spans of the authorization.
+const (
+	opaQueryKey  = attribute.Key("opa.query")
+	sqlFilterKey = attribute.Key("sql.filter")
+)
+
+// FilterByAuthorization returns an SQL predicate on the payload column that holds for the rows the user may access.
+// The predicate is produced by partial evaluation of opaQuery, where input.<unknown> stands for the payload.
+func FilterByAuthorization(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, opaQuery string, unknown string) string {
+	log := logging.FromContext(ctx)
+
+	start := time.Now()
+	compileResponse, err := getPartialEvaluation(ctx, user, groups, authorizationURL, authorizationCABundle, opaQuery,
+		unknown)
+
+	metrics.ObserveOPACompile(opaQuery, time.Since(start), err)
+
 	if err != nil {
-		fmt.Fprintf(logWriter, "unable to get partial evaluation response %v\n", err)
+		log.Error(err, "Unable to get the partial evaluation response")
+		return denyAll
+	}
+
+	_, span := tracing.Start(ctx, "authorization.generateSQL", opaQueryKey.String(opaQuery))
+	defer span.End()
+
+	filter, _ := generateSQL(compileResponse, unknown, log)
+	span.SetAttributes(sqlFilterKey.String(filter))
+
+	return filter
+}
+
+// generateSQL returns the SQL predicate of the queries of the partial evaluation, the disjunction of the conjunctions of
+// their expressions, and the parts of the partial evaluation that could not be translated.
+func generateSQL(compileResponse *opatypes.CompileResponseV1, unknown string,
+	log logr.Logger) (string, []UntranslatedTerm) {
+	translator := &sqlTranslator{unknown: unknown, log: log}
+
+	return disjunction(translator.translate(compileResponse)), translator.untranslatedTerms
+}
+
+// disjunction returns the disjunction of the SQL predicates of the queries, FALSE if there are none.
+func disjunction(predicates []string) string {
+	if len(predicates) == 1 && predicates[0] == allowAll {
+		return allowAll
+	}
+
+	if len(predicates) == 0 {
 		return denyAll
 	}
 
+	return strings.Join(predicates, " OR ") + " OR " + sqlFalse // FALSE for the last OR
+}
+
+// sqlTranslator translates a partial evaluation to SQL. The parts that cannot be translated are replaced by FALSE (by
+// TRUE if negated), logged and kept for the explanations.
+type sqlTranslator struct {
+	unknown           string
+	log               logr.Logger
+	untranslatedTerms []UntranslatedTerm
+}
+
+// translate returns the SQL predicates of the queries of the partial evaluation, TRUE if the only query is empty (the
+// policy allows all), none if the policy allows nothing.
+func (translator *sqlTranslator) translate(compileResponse *opatypes.CompileResponseV1) []string {
+	if compileResponse.Result == nil {
+		return nil
+	}
+
 	resultMap, isTypeCorrect := (*compileResponse.Result).(map[string]interface{})
 	if !isTypeCorrect {
-		fmt.Fprintf(logWriter, "unable to convert result to map\n")
-		return denyAll
+		translator.fail(errUnexpectedType, "Unable to convert the result to a map", "result", *compileResponse.Result)
+		return nil
 	}
 
 	queries, isTypeCorrect := resultMap["queries"].([]interface{})
 	if !isTypeCorrect || len(queries) < 1 {
-		return denyAll
+		return nil
 	}
 
-	var sb strings.Builder
+	predicates := make([]string, 0, len(queries))
 
 	for _, rawQuery := range queries {
 		query, isTypeCorrect := rawQuery.([]interface{})
 		if !isTypeCorrect {
-			fmt.Fprintf(logWriter, "unable to convert query to an array: %v\n", rawQuery)
+			translator.fail(errUnexpectedType, "Unable to convert a query to an array", "query", rawQuery)
 			continue
 		}
 
 		if len(queries) == 1 && len(query) == 0 {
-			return allowAll
+			return []string{allowAll}
 		}
 
-		handleQuery(query, &sb, logWriter)
-	}
+		if len(query) < 1 {
+			continue
+		}
 
-	writeStringOrDie(&sb, sqlFalse) // for the last OR
+		var sb strings.Builder
 
-	return sb.String()
+		translator.handleQuery(query, &sb)
+		predicates = append(predicates, sb.String())
+	}
+
+	return predicates
 }
 
-func handleQuery(query []interface{}, stringWriter io.StringWriter, logWriter io.Writer) {
-	if len(query) < 1 {
-		return
-	}
+// fail logs a part of the partial evaluation that cannot be translated, and keeps it.
+func (translator *sqlTranslator) fail(err error, msg string, key string, term interface{}) {
+	translator.log.Error(err, msg, key, term)
+	translator.untranslatedTerms = append(translator.untranslatedTerms, UntranslatedTerm{
+		Term:  term,
+		Error: fmt.Sprintf("%s: %s", msg, err.Error()),
+	})
+}
 
+func (translator *sqlTranslator) handleQuery(query []interface{}, stringWriter io.StringWriter) {
 	writeStringOrDie(stringWriter, "(")
 
 	for _, rawExpression := range query {
-		handleExpression(rawExpression, stringWriter, logWriter)
+		translator.handleExpression(rawExpression, stringWriter)
 	}
 
 	writeStringOrDie(stringWriter, sqlTrue) // TRUE to handle the last AND
-	writeStringOrDie(stringWriter, ") OR ")
+	writeStringOrDie(stringWriter, ")")
 }
 
-func handleExpression(rawExpression interface{}, stringWriter io.StringWriter, logWriter io.Writer) {
+func (translator *sqlTranslator) handleExpression(rawExpression interface{}, stringWriter io.StringWriter) {
 	expression, isTypeCorrect := rawExpression.(map[string]interface{})
 	if !isTypeCorrect {
-		fmt.Fprintf(logWriter, "unable to convert expression to a map: %v\n", rawExpression)
-		writeStringOrDie(stringWriter, sqlFalse+") ")
+		translator.fail(errUnexpectedType, "Unable to convert an expression to a map", "expression", rawExpression)
+		writeStringOrDie(stringWriter, "("+sqlFalse+") AND ")
 
 		return
 	}
@@ -133,23 +218,23 @@ func handleExpression(rawExpression interface{}, stringWriter io.StringWriter, l
 
 	rawTerms, isTypeCorrect := expression[termsAttribute]
 	if !isTypeCorrect {
-		fmt.Fprintf(logWriter, "unable to get terms from expression: %v\n", expression)
-		writeStringOrDie(stringWriter, sqlFalse+") ")
+		translator.fail(errMissingAttribute, "Unable to get the terms of an expression", "expression", expression)
+		writeStringOrDie(stringWriter, "("+sqlFalse+") AND ")
 
 		return
 	}
 
 	terms, isTypeCorrect := rawTerms.([]interface{})
 	if !isTypeCorrect {
-		fmt.Fprintf(logWriter, "unable to get terms array from expression: %v\n", expression)
-		writeStringOrDie(stringWriter, sqlFalse+") ")
+		translator.fail(errUnexpectedType, "Unable to get the terms array of an expression", "expression", expression)
+		writeStringOrDie(stringWriter, "("+sqlFalse+") AND ")
 
 		return
 	}
 
 	writeStringOrDie(stringWriter, "(")
 
-	handleTermsArray(terms, negated, stringWriter, logWriter)
+	translator.handleTermsArray(terms, negated, stringWriter)
 
 	writeStringOrDie(stringWriter, ") AND ")
 }
@@ -175,7 +260,7 @@ func handleStringTerm(operandMap map[string]interface{}) (string, error) {
 	return "'" + termValueString + "'", nil
 }
 
-func handleRefTerm(operandMap map[string]interface{}) (string, error) {
+func handleRefTerm(operandMap map[string]interface{}, unknown string) (string, error) {
 	termValue, err := getTermValue(operandMap)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse operand's value: %w", err)
@@ -203,8 +288,9 @@ func handleRefTerm(operandMap map[string]interface{}) (string, error) {
 		return "", fmt.Errorf("unable to parse operand's second part: %w", err)
 	}
 
-	if firstPart != inputVariable && secondPart != clusterVariable {
-		return "", fmt.Errorf("%w: expected 'input.cluster' received '%s.%s'", errUnexpectedValue, firstPart, secondPart)
+	if firstPart != inputVariable || secondPart != unknown {
+		return "", fmt.Errorf("%w: expected '%s.%s' received '%s.%s'", errUnexpectedValue, inputVariable, unknown,
+			firstPart, secondPart)
 	}
 
 	operand, err := createPostgreSQLJSONPath(termValueArray[2:])
@@ -236,16 +322,16 @@ func createPostgreSQLJSONPath(termValueArray []interface{}) (string, error) {
 	return operand, nil
 }
 
-func handleTermsArray(terms []interface{}, negated bool, stringWriter io.StringWriter, logWriter io.Writer) {
+func (translator *sqlTranslator) handleTermsArray(terms []interface{}, negated bool, stringWriter io.StringWriter) {
 	if negated {
 		writeStringOrDie(stringWriter, "NOT (")
 	}
 
-	expression, err := getSQLExpression(terms)
+	expression, err := getSQLExpression(terms, translator.unknown)
 	if err == nil {
 		writeStringOrDie(stringWriter, expression)
 	} else {
-		fmt.Fprintf(logWriter, "unable to get SQL expression: %v\n", err)
+		translator.fail(err, "Unable to get an SQL expression", "terms", terms)
 		if negated {
 			writeStringOrDie(stringWriter, sqlTrue)
 		} else {
@@ -258,7 +344,7 @@ func handleTermsArray(terms []interface{}, negated bool, stringWriter io.StringW
 	}
 }
 
-func getSQLExpression(terms []interface{}) (string, error) {
+func getSQLExpression(terms []interface{}, unknown string) (string, error) {
 	if len(terms) != termsArraySize {
 		return "", fmt.Errorf("%w: expected %d, received %d", errUnexpectedTermsNumber, termsArraySize, len(terms))
 	}
@@ -274,12 +360,12 @@ func getSQLExpression(terms []interface{}) (string, error) {
 		return "", fmt.Errorf("%w %s", errUnknownOperator, operator)
 	}
 
-	firstOperand, err := getOperand(terms[1])
+	firstOperand, err := getOperand(terms[1], unknown)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse first operand: %w", err)
 	}
 
-	secondOperand, err := getOperand(terms[2])
+	secondOperand, err := getOperand(terms[2], unknown)
 	if err != nil {
 		return "", fmt.Errorf("unable to parse second operand: %w", err)
 	}
@@ -287,7 +373,7 @@ func getSQLExpression(terms []interface{}) (string, error) {
 	return firstOperand + " " + sqlOperator + " " + secondOperand, nil
 }
 
-func getOperand(term interface{}) (string, error) {
+func getOperand(term interface{}, unknown string) (string, error) {
 	operandMap, ok := term.(map[string]interface{})
 	if !ok {
 		return "", fmt.Errorf("%w expected map, received %T", errUnexpectedType, term)
@@ -307,7 +393,7 @@ func getOperand(term interface{}) (string, error) {
 
 		return operand, nil
 	case termTypeRef:
-		operand, err := handleRefTerm(operandMap)
+		operand, err := handleRefTerm(operandMap, unknown)
 		if err != nil {
 			return "", fmt.Errorf("unable to handle ref term: %w", err)
 		}
@@ -406,43 +492,22 @@ func getTermValue(term map[string]interface{}) (interface{}, error) {
 	return value, nil
 }
 
-func createClient(authorizationCABundle []byte) (*http.Client, error) {
-	tlsConfig := &tls.Config{
-		//nolint:gosec
-		InsecureSkipVerify: true,
-	}
-
-	if authorizationCABundle != nil {
-		rootCAs := x509.NewCertPool()
-		if ok := rootCAs.AppendCertsFromPEM(authorizationCABundle); !ok {
-			return nil, fmt.Errorf("unable to append authorization CA Bundle: %w", errUnableToAppendCABundle)
-		}
-
-		tlsConfig = &tls.Config{
-			MinVersion: tls.VersionTLS12,
-			RootCAs:    rootCAs,
-		}
-	}
-
-	tr := &http.Transport{TLSClientConfig: tlsConfig}
-
-	return &http.Client{Transport: tr}, nil
-}
-
-func getPartialEvaluation(user string, groups []string, authorizationURL string,
-	authorizationCABundle []byte) (*opatypes.CompileResponseV1, error) {
-	_ = groups // to be implemented later
+func getPartialEvaluation(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, opaQuery string, unknown string) (_ *opatypes.CompileResponseV1,
+	err error) {
+	ctx, span := tracing.Start(ctx, "authorization.getPartialEvaluation", opaQueryKey.String(opaQuery))
+	defer func() { tracing.End(span, err) }()
 
 	// the following two lines are required due to the fact that CompileRequestV1 uses
 	// pointer to interface
-	userInput := map[string]interface{}{"user": user}
+	userInput := map[string]interface{}{"user": user, "groups": groups}
 
 	var input interface{} = userInput
 
 	compileRequest := opatypes.CompileRequestV1{
 		Input:    &input,
 		Query:    opaQuery,
-		Unknowns: &[]string{fmt.Sprintf("%s.%s", inputVariable, clusterVariable)},
+		Unknowns: &[]string{fmt.Sprintf("%s.%s", inputVariable, unknown)},
 	}
 
 	jsonCompileRequest, err := json.Marshal(compileRequest)
@@ -450,12 +515,7 @@ func getPartialEvaluation(user string, groups []string, authorizationURL string,
 		return nil, fmt.Errorf("unable to marshal json: %w", err)
 	}
 
-	client, err := createClient(authorizationCABundle)
-	if err != nil {
-		return nil, fmt.Errorf("unable to create client: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(context.TODO(), "POST", fmt.Sprintf("%s/v1/compile",
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/v1/compile",
 		authorizationURL), bytes.NewBuffer(jsonCompileRequest))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create request: %w", err)
@@ -463,7 +523,7 @@ func getPartialEvaluation(user string, groups []string, authorizationURL string,
 
 	req.Header.Add("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	resp, err := authorizationCABundle.Client().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("got authentication error: %w", err)
 	}
@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package authorization
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/rego"
+	opatypes "github.com/open-policy-agent/opa/server/types"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+)
+
+// a policy that allows the members of the dev group to access the development clusters.
+const testGroupPolicy = `package rbac.clusters
+
+default allow = false
+
+allow {
+	input.groups[_] == "dev"
+	input.cluster.metadata.labels.environment == "dev"
+}`
+
+// newTestOPAServer returns an OPA compile API that partially evaluates the requests with the policy, like the OPA
+// server.
+func newTestOPAServer(t *testing.T, policy string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		compileRequest := &opatypes.CompileRequestV1{}
+		if err := json.NewDecoder(request.Body).Decode(compileRequest); err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		partialQueries, err := rego.New(rego.Query(compileRequest.Query), rego.Module("policy.rego", policy),
+			rego.Input(*compileRequest.Input), rego.Unknowns(*compileRequest.Unknowns)).Partial(request.Context())
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var result interface{} = opatypes.PartialEvaluationResultV1{
+			Queries: partialQueries.Queries,
+			Support: partialQueries.Support,
+		}
+
+		if err := json.NewEncoder(writer).Encode(&opatypes.CompileResponseV1{Result: &result}); err != nil {
+			t.Errorf("failed to write the compile response: %v", err)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestFilterByAuthorizationOfGroups(t *testing.T) {
+	server := newTestOPAServer(t, testGroupPolicy)
+
+	caBundle, err := certificates.LoadCABundle("")
+	if err != nil {
+		t.Fatalf("failed to load the CA bundle: %v", err)
+	}
+
+	for _, testCase := range []struct {
+		name    string
+		groups  []string
+		allowed bool
+	}{
+		{"member of the group", []string{"dev", "other"}, true},
+		{"not a member of the group", []string{"other"}, false},
+		{"no groups", []string{}, false},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			filter := FilterByAuthorization(context.Background(), "alice", testCase.groups, server.URL, caBundle,
+				ClustersQuery, ClusterUnknown)
+
+			labelPredicate := strings.Contains(filter, "'environment'") && strings.Contains(filter, "'dev'")
+			if testCase.allowed != labelPredicate {
+				t.Errorf("the filter of the groups %v is %s, expected the predicate of the label: %t",
+					testCase.groups, filter, testCase.allowed)
+			}
+		})
+	}
+}
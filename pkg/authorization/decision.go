@@ -0,0 +1,80 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package authorization
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/tracing"
+)
+
+// dataRequest is the request of the OPA data API.
+type dataRequest struct {
+	Input interface{} `json:"input"`
+}
+
+// dataResponse is the response of the OPA data API, the result is missing if the document is undefined.
+type dataResponse struct {
+	Result json.RawMessage `json:"result"`
+}
+
+// Decide evaluates the OPA document of path (e.g. rbac/clusters/patch) with the input, and decodes its value into
+// decision. An undefined document leaves decision unchanged, so decision should hold the default of the document.
+func Decide(ctx context.Context, authorizationURL string, authorizationCABundle *certificates.CABundle, path string,
+	input interface{}, decision interface{}) (err error) {
+	path = strings.Trim(path, "/")
+
+	ctx, span := tracing.Start(ctx, "authorization.decide", opaQueryKey.String(path))
+	defer func() { tracing.End(span, err) }()
+
+	jsonDataRequest, err := json.Marshal(&dataRequest{Input: input})
+	if err != nil {
+		return fmt.Errorf("unable to marshal json: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/v1/data/%s", authorizationURL, path),
+		bytes.NewBuffer(jsonDataRequest))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	resp, err := authorizationCABundle.Client().Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %d", errStatusNotOK, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read the decision response body: %w", err)
+	}
+
+	response := &dataResponse{}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("failed to unmarshall json: %w", err)
+	}
+
+	if len(response.Result) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(response.Result, decision); err != nil {
+		return fmt.Errorf("unexpected decision of %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package authorization
+
+import (
+	"context"
+	"time"
+
+	opatypes "github.com/open-policy-agent/opa/server/types"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+)
+
+// UntranslatedTerm is a part of a partial evaluation that could not be translated to SQL, replaced by FALSE in the
+// SQL predicate (by TRUE if negated).
+type UntranslatedTerm struct {
+	Term  interface{} `json:"term"`
+	Error string      `json:"error"`
+}
+
+// Explanation explains the SQL predicate of FilterByAuthorization for a user: the partial evaluation of the OPA query,
+// the SQL predicates of its queries, their disjunction and the terms that could not be translated. If the partial
+// evaluation failed, Error is set and the predicate is FALSE.
+type Explanation struct {
+	User              string                      `json:"user"`
+	Groups            []string                    `json:"groups"`
+	Query             string                      `json:"query"`
+	Unknown           string                      `json:"unknown"`
+	CompileResponse   *opatypes.CompileResponseV1 `json:"compileResponse,omitempty"`
+	Error             string                      `json:"error,omitempty"`
+	Predicates        []string                    `json:"predicates"`
+	Filter            string                      `json:"filter"`
+	UntranslatedTerms []UntranslatedTerm          `json:"untranslatedTerms"`
+}
+
+// Explain returns the explanation of the SQL predicate of FilterByAuthorization for the user and the groups.
+func Explain(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, opaQuery string, unknown string) *Explanation {
+	explanation := &Explanation{
+		User:              user,
+		Groups:            groups,
+		Query:             opaQuery,
+		Unknown:           unknown,
+		Predicates:        []string{},
+		Filter:            denyAll,
+		UntranslatedTerms: []UntranslatedTerm{},
+	}
+
+	start := time.Now()
+	compileResponse, err := getPartialEvaluation(ctx, user, groups, authorizationURL, authorizationCABundle, opaQuery,
+		unknown)
+
+	metrics.ObserveOPACompile(opaQuery, time.Since(start), err)
+
+	if err != nil {
+		explanation.Error = err.Error()
+		return explanation
+	}
+
+	explanation.CompileResponse = compileResponse
+
+	translator := &sqlTranslator{unknown: unknown, log: logging.FromContext(ctx)}
+
+	predicates := translator.translate(compileResponse)
+	explanation.Filter = disjunction(predicates)
+
+	if len(predicates) > 0 {
+		explanation.Predicates = predicates
+	}
+
+	if len(translator.untranslatedTerms) > 0 {
+		explanation.UntranslatedTerms = translator.untranslatedTerms
+	}
+
+	return explanation
+}
+
+// AllowsAll returns true if the partial evaluation allows all the rows, without conditions.
+func (explanation *Explanation) AllowsAll() bool {
+	return len(explanation.Predicates) == 1 && explanation.Predicates[0] == allowAll
+}
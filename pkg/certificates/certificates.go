@@ -0,0 +1,242 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package certificates contains the certificate of the server and the CA bundles of the servers it calls, reloaded
+// when their files change, e.g. when OpenShift rotates the service serving certificates.
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/tracing"
+)
+
+var (
+	errFailedToLoadCertificate = errors.New("failed to load certificate/key")
+	errUnableToAppendCABundle  = errors.New("unable to append CA bundle")
+)
+
+// Reloadable is loaded from files, and reloaded when the files change.
+type Reloadable interface {
+	// Reload reloads the files, it returns true if they changed. If the new files are invalid, the previous content
+	// is kept.
+	Reload() (bool, error)
+	// Paths returns the paths of the files, to be logged.
+	Paths() []string
+}
+
+// Certificate is the TLS certificate and key of the server, to be served by tls.Config.GetCertificate.
+type Certificate struct {
+	certificatePath string
+	keyPath         string
+	mutex           sync.RWMutex
+	certificateData []byte
+	keyData         []byte
+	certificate     *tls.Certificate
+}
+
+// LoadCertificate returns the certificate of the certificate and key files.
+func LoadCertificate(certificatePath, keyPath string) (*Certificate, error) {
+	certificate := &Certificate{certificatePath: certificatePath, keyPath: keyPath}
+
+	if _, err := certificate.Reload(); err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
+}
+
+// GetCertificate returns the current certificate, see tls.Config.GetCertificate.
+func (certificate *Certificate) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certificate.mutex.RLock()
+	defer certificate.mutex.RUnlock()
+
+	return certificate.certificate, nil
+}
+
+// Leaf returns the current certificate of the server, without its chain.
+func (certificate *Certificate) Leaf() *x509.Certificate {
+	certificate.mutex.RLock()
+	defer certificate.mutex.RUnlock()
+
+	return certificate.certificate.Leaf
+}
+
+// TLSConfig returns the TLS configuration of a server that serves the current certificate.
+func (certificate *Certificate) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: certificate.GetCertificate,
+	}
+}
+
+// Reload reloads the certificate and key files, see Reloadable.
+func (certificate *Certificate) Reload() (bool, error) {
+	certificateData, err := ioutil.ReadFile(certificate.certificatePath)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errFailedToLoadCertificate, certificate.certificatePath, err)
+	}
+
+	keyData, err := ioutil.ReadFile(certificate.keyPath)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errFailedToLoadCertificate, certificate.keyPath, err)
+	}
+
+	certificate.mutex.RLock()
+	changed := !bytes.Equal(certificateData, certificate.certificateData) || !bytes.Equal(keyData, certificate.keyData)
+	certificate.mutex.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	// the certificate and the key files are not replaced at once, they may not match until both are replaced
+	keyPair, err := tls.X509KeyPair(certificateData, keyData)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s/%s: %v", errFailedToLoadCertificate, certificate.certificatePath,
+			certificate.keyPath, err)
+	}
+
+	if keyPair.Leaf, err = x509.ParseCertificate(keyPair.Certificate[0]); err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errFailedToLoadCertificate, certificate.certificatePath, err)
+	}
+
+	certificate.mutex.Lock()
+	defer certificate.mutex.Unlock()
+
+	certificate.certificateData, certificate.keyData, certificate.certificate = certificateData, keyData, &keyPair
+
+	return true, nil
+}
+
+// Paths returns the paths of the certificate and key files, see Reloadable.
+func (certificate *Certificate) Paths() []string {
+	return []string{certificate.certificatePath, certificate.keyPath}
+}
+
+// CABundle is the CA bundle of a server called by this server, with an HTTP client that trusts it. The client is
+// replaced when the bundle changes. If the path of the bundle is empty, the client skips the verification of the
+// server certificates.
+type CABundle struct {
+	path   string
+	mutex  sync.RWMutex
+	data   []byte
+	client *http.Client
+}
+
+// LoadCABundle returns the CA bundle of the file, path may be empty.
+func LoadCABundle(path string) (*CABundle, error) {
+	bundle := &CABundle{path: path}
+
+	if path == "" {
+		bundle.client = newClient(&tls.Config{
+			//nolint:gosec
+			InsecureSkipVerify: true,
+		})
+
+		return bundle, nil
+	}
+
+	if _, err := bundle.Reload(); err != nil {
+		return nil, err
+	}
+
+	return bundle, nil
+}
+
+// Client returns the HTTP client of the current CA bundle.
+func (bundle *CABundle) Client() *http.Client {
+	bundle.mutex.RLock()
+	defer bundle.mutex.RUnlock()
+
+	return bundle.client
+}
+
+// Reload reloads the CA bundle file, see Reloadable.
+func (bundle *CABundle) Reload() (bool, error) {
+	if bundle.path == "" {
+		return false, nil
+	}
+
+	data, err := ioutil.ReadFile(bundle.path)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", errFailedToLoadCertificate, bundle.path, err)
+	}
+
+	bundle.mutex.RLock()
+	changed := !bytes.Equal(data, bundle.data)
+	bundle.mutex.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	rootCAs := x509.NewCertPool()
+	if ok := rootCAs.AppendCertsFromPEM(data); !ok {
+		return false, fmt.Errorf("%w: %s", errUnableToAppendCABundle, bundle.path)
+	}
+
+	client := newClient(&tls.Config{
+		MinVersion: tls.VersionTLS12,
+		RootCAs:    rootCAs,
+	})
+
+	bundle.mutex.Lock()
+	previousClient := bundle.client
+	bundle.data, bundle.client = data, client
+	bundle.mutex.Unlock()
+
+	// the requests in progress keep their connections, the idle connections trust the previous bundle
+	if previousClient != nil {
+		previousClient.CloseIdleConnections()
+	}
+
+	return true, nil
+}
+
+// Paths returns the path of the CA bundle file, see Reloadable.
+func (bundle *CABundle) Paths() []string {
+	if bundle.path == "" {
+		return nil
+	}
+
+	return []string{bundle.path}
+}
+
+// newClient returns a client that trusts the TLS configuration, its requests are traced.
+func newClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{Transport: tracing.NewTransport(&http.Transport{TLSClientConfig: tlsConfig})}
+}
+
+// Watch reloads the files every interval until the context is done. The files are polled rather than watched by
+// inotify, since the files of the mounted secrets and config maps are replaced through symbolic links.
+func Watch(ctx context.Context, log logr.Logger, interval time.Duration, reloadables ...Reloadable) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, reloadable := range reloadables {
+				changed, err := reloadable.Reload()
+				if err != nil {
+					log.Error(err, "Failed to reload, keeping the previous content", "paths", reloadable.Paths())
+				} else if changed {
+					log.Info("Reloaded", "paths", reloadable.Paths())
+				}
+			}
+		}
+	}
+}
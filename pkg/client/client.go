@@ -0,0 +1,514 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package client is a typed Go client of the managed clusters API of this server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/negotiation"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	managedClustersPath      = "/managedclusters"
+	managedClustersStatsPath = "/managedclusterstats"
+	labelsPathPrefix         = "/metadata/labels/"
+	contentTypeJSON          = "application/json"
+	contentTypeJSONPatch     = "application/json-patch+json"
+	managedClustersResource  = "managedclusters"
+
+	defaultTimeout       = 30 * time.Second
+	defaultRetryAttempts = 5
+	defaultRetryBackoff  = 100 * time.Millisecond
+	maxRetryBackoff      = 5 * time.Second
+)
+
+// the Accept header of Table responses.
+var acceptTable = negotiation.NewMediaType(negotiation.MediaTypeJSON, negotiation.AsTable).String()
+
+var (
+	errMissingURL      = errors.New("the URL of the server is not set")
+	errInvalidCABundle = errors.New("no certificates are found in the CA bundle")
+)
+
+// Interface is the interface of the managed clusters API, implemented by Client and by the fake client of the fake
+// package.
+type Interface interface {
+	List(ctx context.Context, options ListOptions) (*clusterv1.ManagedClusterList, error)
+	Get(ctx context.Context, name string, options GetOptions) (*clusterv1.ManagedCluster, error)
+	Watch(ctx context.Context, options ListOptions) (watch.Interface, error)
+	PatchLabels(ctx context.Context, name string, labelsToAdd map[string]string, labelsToRemove []string,
+		options PatchOptions) (*clusterv1.ManagedCluster, error)
+	Stats(ctx context.Context, groupBy ...string) (*Stats, error)
+}
+
+// Config is the configuration of a Client.
+type Config struct {
+	// URL is the URL of the server, including the base path, e.g.
+	// https://multicloud-console.apps.<cluster>/multicloud/hub-of-hubs-nonk8s-api.
+	URL string
+	// BearerToken is the token to authenticate with.
+	BearerToken string
+	// BearerTokenFile is a file to read the token from on each request, if BearerToken is not set, so that rotated
+	// tokens (e.g. of service accounts) are used.
+	BearerTokenFile string
+	// CAData is the PEM bundle of the CAs to verify the server certificate with. The system CAs are used if not set.
+	CAData []byte
+	// CertData and KeyData are the PEM client certificate and key, for mutual TLS.
+	CertData []byte
+	KeyData  []byte
+	// Insecure skips the verification of the server certificate.
+	Insecure bool
+	// Timeout is the timeout of the requests, except of watches. Defaults to 30 seconds.
+	Timeout time.Duration
+	// RetryAttempts is the number of attempts of the requests that fail with a connection error, 429 or 5xx. Defaults
+	// to 5, 1 disables the retries.
+	RetryAttempts int
+	// RetryBackoff is the initial backoff between attempts, doubled on each retry up to 5 seconds. Defaults to 100ms.
+	RetryBackoff time.Duration
+	// Transport overrides the HTTP transport built from the TLS settings above, e.g. for tests.
+	Transport http.RoundTripper
+}
+
+// ListOptions are the options of List and Watch.
+type ListOptions struct {
+	// Search is the free text to search in the names, the labels, the claims and the URLs of the managed clusters.
+	Search string
+	// Fields are the dot-separated JSON paths of the fields to return, e.g. metadata.labels. All the fields are
+	// returned if empty.
+	Fields []string
+}
+
+// GetOptions are the options of Get.
+type GetOptions struct {
+	// HubCluster is the hub cluster of the managed cluster, required if clusters with the same name exist in multiple
+	// hub clusters.
+	HubCluster string
+	// Fields are the dot-separated JSON paths of the fields to return, see ListOptions.
+	Fields []string
+}
+
+// PatchOptions are the options of PatchLabels.
+type PatchOptions struct {
+	// HubCluster is the hub cluster of the managed cluster, see GetOptions.
+	HubCluster string
+}
+
+// StatsGroup is the number of the managed clusters with the values of the groupBy dimensions, nil for no value.
+type StatsGroup struct {
+	Values map[string]*string `json:"values"`
+	Count  int64              `json:"count"`
+}
+
+// Stats is the number of the managed clusters, grouped by the groupBy dimensions.
+type Stats struct {
+	GroupBy []string      `json:"groupBy"`
+	Total   int64         `json:"total"`
+	Groups  []*StatsGroup `json:"groups"`
+}
+
+// request is a request of the API, name is the name of the managed cluster of the request, if any.
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	accept      string
+	contentType string
+	body        []byte
+	name        string
+}
+
+type patch struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value string `json:"value,omitempty"`
+}
+
+// Client is the client of the managed clusters API.
+type Client struct {
+	config     Config
+	baseURL    *url.URL
+	httpClient *http.Client
+}
+
+var _ Interface = (*Client)(nil)
+
+// New returns a new Client of the server of the configuration.
+func New(config *Config) (*Client, error) {
+	if config.URL == "" {
+		return nil, errMissingURL
+	}
+
+	baseURL, err := url.Parse(strings.TrimSuffix(config.URL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	client := &Client{config: *config, baseURL: baseURL}
+
+	if client.config.RetryAttempts <= 0 {
+		client.config.RetryAttempts = defaultRetryAttempts
+	}
+
+	if client.config.RetryBackoff <= 0 {
+		client.config.RetryBackoff = defaultRetryBackoff
+	}
+
+	if client.config.Timeout <= 0 {
+		client.config.Timeout = defaultTimeout
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		if transport, err = newTransport(config); err != nil {
+			return nil, err
+		}
+	}
+
+	// the timeout is set per request, so that watches are not limited
+	client.httpClient = &http.Client{Transport: transport}
+
+	return client, nil
+}
+
+func newTransport(config *Config) (http.RoundTripper, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.Insecure, //nolint:gosec // opted in by the configuration
+	}
+
+	if len(config.CAData) > 0 {
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(config.CAData) {
+			return nil, errInvalidCABundle
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if len(config.CertData) > 0 || len(config.KeyData) > 0 {
+		certificate, err := tls.X509KeyPair(config.CertData, config.KeyData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// List returns the managed clusters the caller is authorized to view.
+func (client *Client) List(ctx context.Context, options ListOptions) (*clusterv1.ManagedClusterList, error) {
+	managedClusterList := &clusterv1.ManagedClusterList{}
+	if err := client.do(ctx, listRequest(options, contentTypeJSON), managedClusterList); err != nil {
+		return nil, err
+	}
+
+	return managedClusterList, nil
+}
+
+// ListTable returns the managed clusters the caller is authorized to view as a Table, with the columns of the
+// ManagedCluster CRD, like kubectl get.
+func (client *Client) ListTable(ctx context.Context, options ListOptions) (*metav1.Table, error) {
+	table := &metav1.Table{}
+	if err := client.do(ctx, listRequest(options, acceptTable), table); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// Get returns a managed cluster.
+func (client *Client) Get(ctx context.Context, name string, options GetOptions) (*clusterv1.ManagedCluster, error) {
+	query := url.Values{}
+	setHubCluster(query, options.HubCluster)
+	setFields(query, options.Fields)
+
+	managedCluster := &clusterv1.ManagedCluster{}
+	if err := client.do(ctx, &request{
+		method: http.MethodGet, path: managedClusterPath(name), query: query, accept: contentTypeJSON, name: name,
+	}, managedCluster); err != nil {
+		return nil, err
+	}
+
+	return managedCluster, nil
+}
+
+// Watch watches the managed clusters the caller is authorized to view. The server polls the database, each poll sends
+// an ADDED event per managed cluster and a DELETED event per managed cluster that is no longer visible. The watch
+// stops when Stop is called or when ctx is done.
+func (client *Client) Watch(ctx context.Context, options ListOptions) (watch.Interface, error) {
+	return client.watch(ctx, options, contentTypeJSON, func() runtime.Object { return &clusterv1.ManagedCluster{} })
+}
+
+// WatchTable watches the managed clusters like Watch, the objects of the events are Tables with a single row.
+func (client *Client) WatchTable(ctx context.Context, options ListOptions) (watch.Interface, error) {
+	return client.watch(ctx, options, acceptTable, func() runtime.Object { return &metav1.Table{} })
+}
+
+func (client *Client) watch(ctx context.Context, options ListOptions, accept string,
+	newObject func() runtime.Object) (watch.Interface, error) {
+	watchRequest := listRequest(options, accept)
+	watchRequest.query.Set("watch", "true")
+
+	response, err := client.send(ctx, watchRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+
+		return nil, responseError(response, "")
+	}
+
+	return watch.NewStreamWatcher(newWatchDecoder(response.Body, newObject), errorReporter{}), nil
+}
+
+// PatchLabels adds and removes labels of a managed cluster, and returns the managed cluster with the patched labels.
+// The labels are applied to the managed cluster on its hub cluster asynchronously.
+func (client *Client) PatchLabels(ctx context.Context, name string, labelsToAdd map[string]string,
+	labelsToRemove []string, options PatchOptions) (*clusterv1.ManagedCluster, error) {
+	patches := make([]patch, 0, len(labelsToAdd)+len(labelsToRemove))
+
+	for key, value := range labelsToAdd {
+		patches = append(patches, patch{Op: "add", Path: labelPath(key), Value: value})
+	}
+
+	for _, key := range labelsToRemove {
+		patches = append(patches, patch{Op: "remove", Path: labelPath(key)})
+	}
+
+	body, err := json.Marshal(patches)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the patch: %w", err)
+	}
+
+	query := url.Values{}
+	setHubCluster(query, options.HubCluster)
+
+	managedCluster := &clusterv1.ManagedCluster{}
+	if err := client.do(ctx, &request{
+		method: http.MethodPatch, path: managedClusterPath(name), query: query, accept: contentTypeJSON,
+		contentType: contentTypeJSONPatch, body: body, name: name,
+	}, managedCluster); err != nil {
+		return nil, err
+	}
+
+	return managedCluster, nil
+}
+
+// Stats returns the number of the managed clusters the caller is authorized to view, grouped by leafHub,
+// kubernetesVersion, vendor, label:<key> or condition:<type>.
+func (client *Client) Stats(ctx context.Context, groupBy ...string) (*Stats, error) {
+	query := url.Values{}
+	for _, dimension := range groupBy {
+		query.Add("groupBy", dimension)
+	}
+
+	stats := &Stats{}
+	if err := client.do(ctx, &request{
+		method: http.MethodGet, path: managedClustersStatsPath, query: query, accept: contentTypeJSON,
+	}, stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// do sends a request with retries and decodes the JSON response into result.
+func (client *Client) do(ctx context.Context, aRequest *request, result interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, client.config.Timeout)
+	defer cancel()
+
+	response, err := client.send(ctx, aRequest)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return responseError(response, aRequest.name)
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to decode the response: %w", err)
+	}
+
+	return nil
+}
+
+// send sends a request, retrying it with exponential backoff on connection errors, 429 and 5xx responses. The
+// requests of this API are idempotent (a patch of labels sets or removes the labels), so all of them are retried.
+func (client *Client) send(ctx context.Context, aRequest *request) (*http.Response, error) {
+	backoff := client.config.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		response, err := client.sendOnce(ctx, aRequest)
+		if err == nil && !isRetryable(response.StatusCode) {
+			return response, nil
+		}
+
+		if attempt >= client.config.RetryAttempts || ctx.Err() != nil {
+			if err != nil {
+				return nil, err
+			}
+
+			return response, nil
+		}
+
+		if response != nil {
+			_, _ = io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("the request is canceled while retrying: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+func (client *Client) sendOnce(ctx context.Context, aRequest *request) (*http.Response, error) {
+	requestURL := *client.baseURL
+	requestURL.Path += aRequest.path
+	requestURL.RawQuery = aRequest.query.Encode()
+
+	var bodyReader io.Reader
+	if aRequest.body != nil {
+		bodyReader = bytes.NewReader(aRequest.body)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, aRequest.method, requestURL.String(), bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the request: %w", err)
+	}
+
+	httpRequest.Header.Set("Accept", aRequest.accept)
+
+	if aRequest.contentType != "" {
+		httpRequest.Header.Set("Content-Type", aRequest.contentType)
+	}
+
+	token, err := client.token()
+	if err != nil {
+		return nil, err
+	}
+
+	if token != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := client.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send the request: %w", err)
+	}
+
+	return response, nil
+}
+
+func (client *Client) token() (string, error) {
+	if client.config.BearerToken != "" || client.config.BearerTokenFile == "" {
+		return client.config.BearerToken, nil
+	}
+
+	token, err := ioutil.ReadFile(client.config.BearerTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the bearer token file: %w", err)
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
+
+func isRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= http.StatusInternalServerError
+}
+
+// responseError returns the error of a response that is not OK: the Kubernetes Status of the response if it is one,
+// otherwise a Status with the message of the response, so that the errors can be checked with
+// k8s.io/apimachinery/pkg/api/errors, e.g. errors.IsNotFound.
+func responseError(response *http.Response, name string) error {
+	body, _ := ioutil.ReadAll(response.Body)
+
+	status := &metav1.Status{}
+	if err := json.Unmarshal(body, status); err == nil && status.Kind == "Status" {
+		return apierrors.FromObject(status)
+	}
+
+	message := strings.TrimSpace(string(body))
+
+	var statusMessage struct {
+		Status string `json:"status"`
+	}
+
+	if err := json.Unmarshal(body, &statusMessage); err == nil && statusMessage.Status != "" {
+		message = statusMessage.Status
+	}
+
+	statusError := apierrors.NewGenericServerResponse(response.StatusCode, response.Request.Method,
+		clusterv1.GroupVersion.WithResource(managedClustersResource).GroupResource(), name, message, 0, false)
+	if message != "" {
+		statusError.ErrStatus.Message = message // the generic message of some codes does not include it
+	}
+
+	return statusError
+}
+
+func listRequest(options ListOptions, accept string) *request {
+	query := url.Values{}
+	if options.Search != "" {
+		query.Set("search", options.Search)
+	}
+
+	setFields(query, options.Fields)
+
+	return &request{method: http.MethodGet, path: managedClustersPath, query: query, accept: accept}
+}
+
+func setFields(query url.Values, fields []string) {
+	for _, field := range fields {
+		query.Add("fields", field)
+	}
+}
+
+func setHubCluster(query url.Values, hubCluster string) {
+	if hubCluster != "" {
+		query.Set("hubCluster", hubCluster)
+	}
+}
+
+func managedClusterPath(name string) string {
+	return managedClustersPath + "/" + url.PathEscape(name)
+}
+
+// labelPath returns the JSON pointer (RFC 6901) of a label.
+func labelPath(key string) string {
+	return labelsPathPrefix + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
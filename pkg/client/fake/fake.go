@@ -0,0 +1,349 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package fake is an in-memory implementation of the managed clusters client, for tests of the code that uses the
+// client.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/client"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// DefaultHubCluster is the hub cluster of the managed clusters passed to NewClient.
+const DefaultHubCluster = "hub1"
+
+const (
+	groupByLeafHub           = "leafHub"
+	groupByKubernetesVersion = "kubernetesVersion"
+	groupByVendor            = "vendor"
+	groupByLabelPrefix       = "label:"
+	groupByConditionPrefix   = "condition:"
+)
+
+var managedClustersGroupResource = clusterv1.GroupVersion.WithResource("managedclusters").GroupResource()
+
+type key struct {
+	hubCluster string
+	name       string
+}
+
+type watcher struct {
+	watcher *watch.RaceFreeFakeWatcher
+	options client.ListOptions
+}
+
+// Client is an in-memory client of the managed clusters. Like the server, it sends an ADDED event to the watches
+// when a managed cluster is added or modified, and a DELETED event when it is deleted. The watches buffer up to
+// watch.DefaultChanSize events. The fields of the list options are ignored, the managed clusters are returned as a
+// whole.
+type Client struct {
+	mutex           sync.Mutex
+	managedClusters map[key]*clusterv1.ManagedCluster
+	watchers        []*watcher
+}
+
+var _ client.Interface = (*Client)(nil)
+
+// NewClient returns a new fake client with the managed clusters, in DefaultHubCluster.
+func NewClient(managedClusters ...*clusterv1.ManagedCluster) *Client {
+	fakeClient := &Client{managedClusters: map[key]*clusterv1.ManagedCluster{}}
+
+	for _, managedCluster := range managedClusters {
+		fakeClient.Add(DefaultHubCluster, managedCluster)
+	}
+
+	return fakeClient
+}
+
+// Add adds or replaces a managed cluster of a hub cluster.
+func (fakeClient *Client) Add(hubCluster string, managedCluster *clusterv1.ManagedCluster) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	fakeClient.managedClusters[key{hubCluster: hubCluster, name: managedCluster.GetName()}] = managedCluster.DeepCopy()
+	fakeClient.notify(watch.Added, managedCluster)
+}
+
+// Delete deletes a managed cluster of a hub cluster.
+func (fakeClient *Client) Delete(hubCluster, name string) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	managedClusterKey := key{hubCluster: hubCluster, name: name}
+
+	managedCluster, found := fakeClient.managedClusters[managedClusterKey]
+	if !found {
+		return
+	}
+
+	delete(fakeClient.managedClusters, managedClusterKey)
+	fakeClient.notify(watch.Deleted, managedCluster)
+}
+
+// List returns the managed clusters that match the search of the options, ordered by name.
+func (fakeClient *Client) List(_ context.Context, options client.ListOptions) (*clusterv1.ManagedClusterList,
+	error) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	managedClusterList := &clusterv1.ManagedClusterList{Items: []clusterv1.ManagedCluster{}}
+	managedClusterList.Kind = "ManagedClusterList"
+	managedClusterList.APIVersion = clusterv1.GroupVersion.String()
+
+	for _, managedClusterKey := range fakeClient.sortedKeys() {
+		managedCluster := fakeClient.managedClusters[managedClusterKey]
+		if matches(managedCluster, options.Search) {
+			managedClusterList.Items = append(managedClusterList.Items, *managedCluster.DeepCopy())
+		}
+	}
+
+	return managedClusterList, nil
+}
+
+// Get returns a managed cluster, a NotFound error if it does not exist or a BadRequest error if the hub cluster is
+// not specified and clusters with the name exist in multiple hub clusters.
+func (fakeClient *Client) Get(_ context.Context, name string, options client.GetOptions) (*clusterv1.ManagedCluster,
+	error) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	managedClusterKey, err := fakeClient.find(name, options.HubCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	return fakeClient.managedClusters[managedClusterKey].DeepCopy(), nil
+}
+
+// Watch returns a watch of the managed clusters that match the search of the options. Like the server, it starts
+// with an ADDED event per managed cluster.
+func (fakeClient *Client) Watch(ctx context.Context, options client.ListOptions) (watch.Interface, error) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	aWatcher := &watcher{watcher: watch.NewRaceFreeFake(), options: options}
+
+	for _, managedClusterKey := range fakeClient.sortedKeys() {
+		managedCluster := fakeClient.managedClusters[managedClusterKey]
+		if matches(managedCluster, options.Search) {
+			aWatcher.watcher.Add(managedCluster.DeepCopy())
+		}
+	}
+
+	fakeClient.watchers = append(fakeClient.watchers, aWatcher)
+
+	go func() {
+		<-ctx.Done()
+		aWatcher.watcher.Stop()
+	}()
+
+	return aWatcher.watcher, nil
+}
+
+// PatchLabels adds and removes labels of a managed cluster, and returns the patched managed cluster.
+func (fakeClient *Client) PatchLabels(_ context.Context, name string, labelsToAdd map[string]string,
+	labelsToRemove []string, options client.PatchOptions) (*clusterv1.ManagedCluster, error) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	managedClusterKey, err := fakeClient.find(name, options.HubCluster)
+	if err != nil {
+		return nil, err
+	}
+
+	managedCluster := fakeClient.managedClusters[managedClusterKey]
+
+	labels := managedCluster.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	for labelKey, value := range labelsToAdd {
+		labels[labelKey] = value
+	}
+
+	for _, labelKey := range labelsToRemove {
+		delete(labels, labelKey)
+	}
+
+	managedCluster.SetLabels(labels)
+	fakeClient.notify(watch.Added, managedCluster)
+
+	return managedCluster.DeepCopy(), nil
+}
+
+// Stats returns the number of the managed clusters, grouped like by the server.
+func (fakeClient *Client) Stats(_ context.Context, groupBy ...string) (*client.Stats, error) {
+	fakeClient.mutex.Lock()
+	defer fakeClient.mutex.Unlock()
+
+	stats := &client.Stats{GroupBy: groupBy, Groups: []*client.StatsGroup{}}
+	groups := map[string]*client.StatsGroup{}
+
+	for _, managedClusterKey := range fakeClient.sortedKeys() {
+		values := make(map[string]*string, len(groupBy))
+		groupKey := make([]string, 0, len(groupBy))
+
+		for _, aGroupBy := range groupBy {
+			value, err := groupByValue(managedClusterKey.hubCluster, fakeClient.managedClusters[managedClusterKey],
+				aGroupBy)
+			if err != nil {
+				return nil, err
+			}
+
+			values[aGroupBy] = value
+
+			if value == nil {
+				groupKey = append(groupKey, "\x00")
+			} else {
+				groupKey = append(groupKey, *value)
+			}
+		}
+
+		group, found := groups[strings.Join(groupKey, "\x01")]
+		if !found {
+			group = &client.StatsGroup{Values: values}
+			groups[strings.Join(groupKey, "\x01")] = group
+			stats.Groups = append(stats.Groups, group)
+		}
+
+		group.Count++
+		stats.Total++
+	}
+
+	return stats, nil
+}
+
+// find returns the key of a managed cluster, must be called with the mutex locked.
+func (fakeClient *Client) find(name, hubCluster string) (key, error) {
+	if hubCluster != "" {
+		managedClusterKey := key{hubCluster: hubCluster, name: name}
+		if _, found := fakeClient.managedClusters[managedClusterKey]; !found {
+			return key{}, apierrors.NewNotFound(managedClustersGroupResource, name)
+		}
+
+		return managedClusterKey, nil
+	}
+
+	hubClusters := []string{}
+
+	for managedClusterKey := range fakeClient.managedClusters {
+		if managedClusterKey.name == name {
+			hubClusters = append(hubClusters, managedClusterKey.hubCluster)
+		}
+	}
+
+	sort.Strings(hubClusters)
+
+	switch len(hubClusters) {
+	case 0:
+		return key{}, apierrors.NewNotFound(managedClustersGroupResource, name)
+	case 1:
+		return key{hubCluster: hubClusters[0], name: name}, nil
+	default:
+		return key{}, apierrors.NewBadRequest(fmt.Sprintf(
+			"cluster %s exists in multiple hub clusters %v, specify the hubCluster query parameter", name,
+			hubClusters))
+	}
+}
+
+// notify sends an event to the watches that match the managed cluster, must be called with the mutex locked.
+func (fakeClient *Client) notify(eventType watch.EventType, managedCluster *clusterv1.ManagedCluster) {
+	watchers := fakeClient.watchers[:0]
+
+	for _, aWatcher := range fakeClient.watchers {
+		if aWatcher.watcher.IsStopped() {
+			continue
+		}
+
+		watchers = append(watchers, aWatcher)
+
+		if matches(managedCluster, aWatcher.options.Search) {
+			aWatcher.watcher.Action(eventType, managedCluster.DeepCopy())
+		}
+	}
+
+	fakeClient.watchers = watchers
+}
+
+func (fakeClient *Client) sortedKeys() []key {
+	keys := make([]key, 0, len(fakeClient.managedClusters))
+	for managedClusterKey := range fakeClient.managedClusters {
+		keys = append(keys, managedClusterKey)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].name != keys[j].name {
+			return keys[i].name < keys[j].name
+		}
+
+		return keys[i].hubCluster < keys[j].hubCluster
+	})
+
+	return keys
+}
+
+// matches returns true if the search is a case-insensitive substring of the name or of a label of the managed
+// cluster, an approximation of the search of the server.
+func matches(managedCluster *clusterv1.ManagedCluster, search string) bool {
+	if search == "" {
+		return true
+	}
+
+	search = strings.ToLower(search)
+
+	if strings.Contains(strings.ToLower(managedCluster.GetName()), search) {
+		return true
+	}
+
+	for labelKey, value := range managedCluster.GetLabels() {
+		if strings.Contains(strings.ToLower(labelKey+"="+value), search) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func groupByValue(hubCluster string, managedCluster *clusterv1.ManagedCluster, groupBy string) (*string, error) {
+	optional := func(value string, found bool) *string {
+		if !found {
+			return nil
+		}
+
+		return &value
+	}
+
+	switch {
+	case groupBy == groupByLeafHub:
+		return &hubCluster, nil
+	case groupBy == groupByKubernetesVersion:
+		return optional(managedCluster.Status.Version.Kubernetes, managedCluster.Status.Version.Kubernetes != ""), nil
+	case groupBy == groupByVendor:
+		value, found := managedCluster.GetLabels()["vendor"]
+		return optional(value, found), nil
+	case strings.HasPrefix(groupBy, groupByLabelPrefix) && len(groupBy) > len(groupByLabelPrefix):
+		value, found := managedCluster.GetLabels()[strings.TrimPrefix(groupBy, groupByLabelPrefix)]
+		return optional(value, found), nil
+	case strings.HasPrefix(groupBy, groupByConditionPrefix) && len(groupBy) > len(groupByConditionPrefix):
+		for _, condition := range managedCluster.Status.Conditions {
+			if condition.Type == strings.TrimPrefix(groupBy, groupByConditionPrefix) {
+				return optional(string(condition.Status), true), nil
+			}
+		}
+
+		return nil, nil
+	default:
+		return nil, apierrors.NewBadRequest("unknown groupBy, expected one of leafHub, kubernetesVersion, vendor, " +
+			"label:<key> or condition:<type>")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// watchDecoder decodes the watch events of the managed clusters, sent by the server as JSON objects separated by
+// newlines.
+type watchDecoder struct {
+	body      io.ReadCloser
+	decoder   *json.Decoder
+	newObject func() runtime.Object // returns an empty object of the events, a ManagedCluster or a Table
+}
+
+func newWatchDecoder(body io.ReadCloser, newObject func() runtime.Object) *watchDecoder {
+	return &watchDecoder{body: body, decoder: json.NewDecoder(body), newObject: newObject}
+}
+
+// Decode returns the next watch event.
+func (decoder *watchDecoder) Decode() (watch.EventType, runtime.Object, error) {
+	event := &metav1.WatchEvent{}
+	if err := decoder.decoder.Decode(event); err != nil {
+		return "", nil, err //nolint:wrapcheck // io.EOF ends the watch
+	}
+
+	if watch.EventType(event.Type) == watch.Error {
+		status := &metav1.Status{}
+		if err := json.Unmarshal(event.Object.Raw, status); err != nil {
+			return "", nil, fmt.Errorf("failed to decode the status of an error event: %w", err)
+		}
+
+		return watch.Error, status, nil
+	}
+
+	object := decoder.newObject()
+	if err := json.Unmarshal(event.Object.Raw, object); err != nil {
+		return "", nil, fmt.Errorf("failed to decode the object of a watch event: %w", err)
+	}
+
+	return watch.EventType(event.Type), object, nil
+}
+
+// Close closes the response body of the watch.
+func (decoder *watchDecoder) Close() {
+	decoder.body.Close()
+}
+
+// errorReporter reports the errors of decoding watch events as Error events.
+type errorReporter struct{}
+
+// AsObject returns the Status of the error.
+func (errorReporter) AsObject(err error) runtime.Object {
+	return &apierrors.NewInternalError(err).ErrStatus
+}
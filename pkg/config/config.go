@@ -0,0 +1,588 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package config contains the configuration of the server, loaded from defaults, a YAML file, environment variables
+// and command-line flags, in this order of precedence (the flags win).
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	auditv1 "k8s.io/apiserver/pkg/apis/audit/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	configFlag                = "config"
+	environmentVariableConfig = "CONFIG_PATH"
+	redactedValue             = "xxxxx"
+
+	defaultAddress            = ":8080"
+	defaultGRPCAddress        = ":8081"
+	defaultReadHeaderTimeout  = 10 * time.Second
+	defaultShutdownTimeout    = 5 * time.Second
+	defaultReloadInterval     = 30 * time.Second
+	defaultWatchInterval      = 4 * time.Second
+	defaultPatchRetryAttempts = 5
+	defaultSamplingRatio      = 1.0
+	defaultAuditMaxSize       = 100
+	defaultAuditMaxBackups    = 10
+	defaultAuditMaxAge        = 30
+)
+
+var (
+	errRequired = errors.New("is required")
+	errInvalid  = errors.New("is invalid")
+
+	// the path of an OPA document, e.g. admission/managedclusters/deny.
+	opaPathRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(/[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+	// the password of a keyword/value PostgreSQL connection string, e.g. host=db password='secret'.
+	keywordPasswordRegexp = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	Server          ServerConfig          `json:"server"`
+	TLS             TLSConfig             `json:"tls"`
+	Database        DatabaseConfig        `json:"database"`
+	ClusterAPI      EndpointConfig        `json:"clusterAPI"`
+	Authorization   EndpointConfig        `json:"authorization"`
+	ManagedClusters ManagedClustersConfig `json:"managedClusters"`
+	Cache           CacheConfig           `json:"cache"`
+	Features        FeaturesConfig        `json:"features"`
+	Tracing         TracingConfig         `json:"tracing"`
+	Logging         LoggingConfig         `json:"logging"`
+	Audit           AuditConfig           `json:"audit"`
+	Admission       AdmissionConfig       `json:"admission"`
+}
+
+// ServerConfig is the configuration of the REST and gRPC listeners. A timeout of 0 means no timeout, the write
+// timeout should stay 0 for the watches to stream.
+type ServerConfig struct {
+	Address           string          `json:"address"`
+	GRPCAddress       string          `json:"grpcAddress"`
+	BasePath          string          `json:"basePath"`
+	ReadTimeout       metav1.Duration `json:"readTimeout"`
+	ReadHeaderTimeout metav1.Duration `json:"readHeaderTimeout"`
+	WriteTimeout      metav1.Duration `json:"writeTimeout"`
+	IdleTimeout       metav1.Duration `json:"idleTimeout"`
+	ShutdownTimeout   metav1.Duration `json:"shutdownTimeout"`
+}
+
+// TLSConfig is the certificate and the key of the server.
+type TLSConfig struct {
+	CertificatePath string `json:"certificatePath"`
+	KeyPath         string `json:"keyPath"`
+	// ReloadInterval is the interval of the checks of the certificate, key and CA bundle files for changes, 0
+	// disables the reload.
+	ReloadInterval metav1.Duration `json:"reloadInterval"`
+}
+
+// DatabaseConfig is the configuration of the database connection pool. The pool settings left 0 keep the values of
+// the URL (e.g. pool_max_conns) or the pgx defaults.
+type DatabaseConfig struct {
+	URL                   string          `json:"url"`
+	MaxConnections        int32           `json:"maxConnections"`
+	MinConnections        int32           `json:"minConnections"`
+	MaxConnectionLifetime metav1.Duration `json:"maxConnectionLifetime"`
+	MaxConnectionIdleTime metav1.Duration `json:"maxConnectionIdleTime"`
+	HealthCheckPeriod     metav1.Duration `json:"healthCheckPeriod"`
+	ConnectTimeout        metav1.Duration `json:"connectTimeout"`
+}
+
+// EndpointConfig is a server called by this server. If the CA bundle path is empty, the verification of the server
+// certificates is skipped.
+type EndpointConfig struct {
+	URL          string `json:"url"`
+	CABundlePath string `json:"caBundlePath"`
+}
+
+// ManagedClustersConfig is the configuration of the managed clusters API.
+type ManagedClustersConfig struct {
+	// WatchInterval is the interval of the database polls of the REST and gRPC watches.
+	WatchInterval metav1.Duration `json:"watchInterval"`
+	// PatchRetryAttempts is the number of attempts of the update of the labels on transient database errors.
+	PatchRetryAttempts int `json:"patchRetryAttempts"`
+	// PatchFallbackToRead authorizes the label changes of the visible managed clusters if the policy does not define
+	// the data.rbac.clusters.patch rule, as before the rule. It is false by default: an undefined rule denies them.
+	PatchFallbackToRead bool `json:"patchFallbackToRead"`
+	// AuthorizationAdminGroups are the groups of the users that may explain the authorization of other users.
+	AuthorizationAdminGroups []string `json:"authorizationAdminGroups"`
+}
+
+// CacheConfig is the time to live of the cached entries, 0 disables the cache.
+type CacheConfig struct {
+	// AuthenticationTTL is the time the users authenticated by the cluster API are cached, by bearer token. It trades
+	// the revocation for fewer calls of the cluster API: a revoked token, or a user removed from a group, is still
+	// authenticated with the cached user and groups for up to the TTL. Disabled by default.
+	AuthenticationTTL metav1.Duration `json:"authenticationTTL"`
+}
+
+// FeaturesConfig enables or disables the optional APIs of the server.
+type FeaturesConfig struct {
+	GRPC          bool `json:"grpc"`
+	GraphQL       bool `json:"graphQL"`
+	OpenAPI       bool `json:"openAPI"`
+	KubernetesAPI bool `json:"kubernetesAPI"`
+	Metrics       bool `json:"metrics"`
+}
+
+// TracingConfig is the OpenTelemetry tracing of the server, disabled if the endpoint is empty.
+type TracingConfig struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector the spans are exported to.
+	Endpoint string `json:"endpoint"`
+	// Insecure exports the spans over HTTP rather than HTTPS.
+	Insecure bool `json:"insecure"`
+	// SamplingRatio is the ratio of the traces started by the server that are sampled, the traces of the incoming
+	// requests keep the sampling decision of their parent.
+	SamplingRatio float64 `json:"samplingRatio"`
+}
+
+// LoggingConfig is the structured logging of the server.
+type LoggingConfig struct {
+	// Level is error, info or debug. The SQL queries and the groups of the users are logged at the debug level only.
+	Level string `json:"level"`
+	// Format is json, for production, or console.
+	Format string `json:"format"`
+}
+
+// AuditConfig is the audit log of the server, disabled if the level is None or if there is no sink.
+type AuditConfig struct {
+	// Level is None, Metadata, Request (with the request bodies) or RequestResponse (with the response bodies).
+	Level string `json:"level"`
+	// Path is the file of the events, empty disables the file sink.
+	Path string `json:"path"`
+	// MaxSize is the size in megabytes at which the file is rotated.
+	MaxSize int `json:"maxSize"`
+	// MaxBackups is the number of rotated files kept, 0 keeps them all.
+	MaxBackups int `json:"maxBackups"`
+	// MaxAge is the number of days the rotated files are kept, 0 keeps them.
+	MaxAge int `json:"maxAge"`
+	// Database inserts the events in the audit.events table.
+	Database bool `json:"database"`
+}
+
+// AdmissionConfig is the validation of the changes of the labels of the managed clusters, before they are written.
+// The syntax of the labels is always validated.
+type AdmissionConfig struct {
+	// ProtectedLabels are the label keys, or the key prefixes ending with /, that only the privileged groups may
+	// change.
+	ProtectedLabels []string `json:"protectedLabels"`
+	// PrivilegedGroups are the groups of the users that may change the protected labels.
+	PrivilegedGroups []string `json:"privilegedGroups"`
+	// OPAPolicy is the path of the OPA document of the reasons to deny a change (e.g. admission/managedclusters/deny),
+	// evaluated with the change as input. Empty disables the evaluation.
+	OPAPolicy string `json:"opaPolicy"`
+}
+
+// option is a configuration value that can be set by a command-line flag and an environment variable.
+type option struct {
+	flag                string
+	environmentVariable string
+	usage               string
+	value               interface{}
+}
+
+// NewDefault returns the default configuration.
+func NewDefault() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:           defaultAddress,
+			GRPCAddress:       defaultGRPCAddress,
+			ReadHeaderTimeout: metav1.Duration{Duration: defaultReadHeaderTimeout},
+			ShutdownTimeout:   metav1.Duration{Duration: defaultShutdownTimeout},
+		},
+		TLS: TLSConfig{
+			ReloadInterval: metav1.Duration{Duration: defaultReloadInterval},
+		},
+		ManagedClusters: ManagedClustersConfig{
+			WatchInterval:            metav1.Duration{Duration: defaultWatchInterval},
+			PatchRetryAttempts:       defaultPatchRetryAttempts,
+			PatchFallbackToRead:      false,
+			AuthorizationAdminGroups: []string{"system:masters"},
+		},
+		Features: FeaturesConfig{
+			GRPC:          true,
+			GraphQL:       true,
+			OpenAPI:       true,
+			KubernetesAPI: true,
+			Metrics:       true,
+		},
+		Tracing: TracingConfig{
+			SamplingRatio: defaultSamplingRatio,
+		},
+		Logging: LoggingConfig{
+			Level:  logging.LevelInfo,
+			Format: logging.FormatJSON,
+		},
+		Audit: AuditConfig{
+			Level:      string(auditv1.LevelMetadata),
+			MaxSize:    defaultAuditMaxSize,
+			MaxBackups: defaultAuditMaxBackups,
+			MaxAge:     defaultAuditMaxAge,
+		},
+		Admission: AdmissionConfig{
+			ProtectedLabels:  []string{"cluster.open-cluster-management.io/", "name", "vendor"},
+			PrivilegedGroups: []string{"system:masters"},
+		},
+	}
+}
+
+// Load returns the configuration of the command-line arguments (without the program name), the environment variables
+// and the YAML file of the --config flag or of the CONFIG_PATH environment variable, over the defaults. The
+// configuration is validated. If the arguments contain --help, the usage is printed and pflag.ErrHelp is returned.
+func Load(args []string) (*Config, error) {
+	configPath, err := parseConfigPath(args)
+	if err != nil {
+		return nil, err
+	}
+
+	config := NewDefault()
+
+	if configPath != "" {
+		if err := config.loadFile(configPath); err != nil {
+			return nil, err
+		}
+	}
+
+	flagSet, options := config.newFlagSet()
+	// the usage shows the defaults, rather than the values of the file that may contain secrets
+	flagSet.Usage = func() {
+		defaultFlagSet, _ := NewDefault().newFlagSet()
+
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		defaultFlagSet.PrintDefaults()
+	}
+
+	for _, option := range options {
+		if value, found := os.LookupEnv(option.environmentVariable); found {
+			if err := flagSet.Set(option.flag, value); err != nil {
+				return nil, fmt.Errorf("invalid value of environment variable %s: %w", option.environmentVariable, err)
+			}
+		}
+	}
+
+	if err := flagSet.Parse(args); err != nil {
+		return nil, fmt.Errorf("failed to parse the command-line arguments: %w", err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// newFlagSet returns the flags of the options of the configuration, with their current values as defaults.
+func (config *Config) newFlagSet() (*pflag.FlagSet, []option) {
+	flagSet := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	flagSet.String(configFlag, "", "the path of the YAML configuration file, also "+environmentVariableConfig)
+
+	options := config.options()
+	for _, option := range options {
+		addFlag(flagSet, option)
+	}
+
+	return flagSet, options
+}
+
+// parseConfigPath returns the path of the configuration file, the other flags are parsed after the file is loaded.
+func parseConfigPath(args []string) (string, error) {
+	flagSet := pflag.NewFlagSet(os.Args[0], pflag.ContinueOnError)
+	flagSet.ParseErrorsWhitelist.UnknownFlags = true
+	flagSet.Usage = func() {}
+	flagSet.BoolP("help", "h", false, "") // --help is handled with the usage of all the flags
+
+	configPath := flagSet.String(configFlag, os.Getenv(environmentVariableConfig), "")
+
+	if err := flagSet.Parse(args); err != nil {
+		return "", fmt.Errorf("failed to parse the command-line arguments: %w", err)
+	}
+
+	return *configPath, nil
+}
+
+func (config *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the configuration file: %w", err)
+	}
+
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return fmt.Errorf("failed to parse the configuration file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// options returns the configuration values that can be set by flags and environment variables. The environment
+// variables of the first versions of the server keep their names.
+//
+//nolint:funlen
+func (config *Config) options() []option {
+	return []option{
+		{"listen-address", "LISTEN_ADDRESS", "the address of the REST server", &config.Server.Address},
+		{"grpc-address", "GRPC_ADDRESS", "the address of the gRPC server", &config.Server.GRPCAddress},
+		{"base-path", "BASE_PATH", "the path prefix of the REST routes", &config.Server.BasePath},
+		{"read-timeout", "READ_TIMEOUT", "the maximum duration to read a request", &config.Server.ReadTimeout},
+		{"read-header-timeout", "READ_HEADER_TIMEOUT", "the maximum duration to read the headers of a request",
+			&config.Server.ReadHeaderTimeout},
+		{"write-timeout", "WRITE_TIMEOUT", "the maximum duration to write a response, 0 for the watches",
+			&config.Server.WriteTimeout},
+		{"idle-timeout", "IDLE_TIMEOUT", "the maximum duration of an idle keep-alive connection",
+			&config.Server.IdleTimeout},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", "the time given to the requests to finish on shutdown",
+			&config.Server.ShutdownTimeout},
+		{"certificate-path", "CERTIFICATE_PATH", "the path of the TLS certificate of the server",
+			&config.TLS.CertificatePath},
+		{"key-path", "KEY_PATH", "the path of the TLS private key of the server", &config.TLS.KeyPath},
+		{"tls-reload-interval", "TLS_RELOAD_INTERVAL", "the interval of the checks of the certificate files, 0 to disable",
+			&config.TLS.ReloadInterval},
+		{"database-url", "DATABASE_URL", "the URL of the database", &config.Database.URL},
+		{"database-max-connections", "DATABASE_MAX_CONNECTIONS", "the maximum size of the connection pool",
+			&config.Database.MaxConnections},
+		{"database-min-connections", "DATABASE_MIN_CONNECTIONS", "the minimum size of the connection pool",
+			&config.Database.MinConnections},
+		{"database-max-connection-lifetime", "DATABASE_MAX_CONNECTION_LIFETIME",
+			"the time after which a connection is closed",
+			&config.Database.MaxConnectionLifetime},
+		{"database-max-connection-idle-time", "DATABASE_MAX_CONNECTION_IDLE_TIME",
+			"the time after which an idle connection is closed",
+			&config.Database.MaxConnectionIdleTime},
+		{"database-health-check-period", "DATABASE_HEALTH_CHECK_PERIOD",
+			"the interval of the health checks of the idle connections",
+			&config.Database.HealthCheckPeriod},
+		{"database-connect-timeout", "DATABASE_CONNECT_TIMEOUT", "the maximum duration to connect to the database",
+			&config.Database.ConnectTimeout},
+		{"cluster-api-url", "CLUSTER_API_URL", "the URL of the Kubernetes API server", &config.ClusterAPI.URL},
+		{"cluster-api-ca-bundle-path", "CLUSTER_API_CA_BUNDLE_PATH", "the CA bundle of the Kubernetes API server",
+			&config.ClusterAPI.CABundlePath},
+		{"authorization-url", "AUTHORIZATION_URL", "the URL of the authorization server", &config.Authorization.URL},
+		{"authorization-ca-bundle-path", "AUTHORIZATION_CA_BUNDLE_PATH", "the CA bundle of the authorization server",
+			&config.Authorization.CABundlePath},
+		{"watch-interval", "WATCH_INTERVAL", "the interval of the database polls of the watches",
+			&config.ManagedClusters.WatchInterval},
+		{"patch-retry-attempts", "PATCH_RETRY_ATTEMPTS", "the attempts of the label updates on transient database errors",
+			&config.ManagedClusters.PatchRetryAttempts},
+		{"patch-fallback-to-read", "PATCH_FALLBACK_TO_READ",
+			"authorize the label changes of the visible clusters if the patch rule of the policy is undefined",
+			&config.ManagedClusters.PatchFallbackToRead},
+		{"authorization-admin-groups", "AUTHORIZATION_ADMIN_GROUPS",
+			"the groups that may explain the authorization of other users", &config.ManagedClusters.AuthorizationAdminGroups},
+		{"authentication-cache-ttl", "AUTHENTICATION_CACHE_TTL",
+			"the time authenticated users are cached, a revoked token is accepted for up to this time, 0 to disable",
+			&config.Cache.AuthenticationTTL},
+		{"enable-grpc", "ENABLE_GRPC", "serve the gRPC API", &config.Features.GRPC},
+		{"enable-graphql", "ENABLE_GRAPHQL", "serve the GraphQL API", &config.Features.GraphQL},
+		{"enable-openapi", "ENABLE_OPENAPI", "serve the OpenAPI documents", &config.Features.OpenAPI},
+		{"enable-kubernetes-api", "ENABLE_KUBERNETES_API", "serve the Kubernetes API discovery and paths",
+			&config.Features.KubernetesAPI},
+		{"enable-metrics", "ENABLE_METRICS", "serve the Prometheus metrics on /metrics", &config.Features.Metrics},
+		{"tracing-endpoint", "TRACING_ENDPOINT", "the host:port of the OTLP/HTTP collector of the traces, empty to disable",
+			&config.Tracing.Endpoint},
+		{"tracing-insecure", "TRACING_INSECURE", "export the traces over HTTP rather than HTTPS",
+			&config.Tracing.Insecure},
+		{"tracing-sampling-ratio", "TRACING_SAMPLING_RATIO", "the ratio of the sampled traces, from 0 to 1",
+			&config.Tracing.SamplingRatio},
+		{"log-level", "LOG_LEVEL", "the log level, error, info or debug", &config.Logging.Level},
+		{"log-format", "LOG_FORMAT", "the log format, json or console", &config.Logging.Format},
+		{"audit-level", "AUDIT_LEVEL", "the audit level, None, Metadata, Request or RequestResponse",
+			&config.Audit.Level},
+		{"audit-log-path", "AUDIT_LOG_PATH", "the file of the audit events, empty to disable", &config.Audit.Path},
+		{"audit-log-max-size", "AUDIT_LOG_MAX_SIZE", "the size in megabytes at which the audit file is rotated",
+			&config.Audit.MaxSize},
+		{"audit-log-max-backups", "AUDIT_LOG_MAX_BACKUPS", "the number of rotated audit files kept, 0 to keep all",
+			&config.Audit.MaxBackups},
+		{"audit-log-max-age", "AUDIT_LOG_MAX_AGE", "the days the rotated audit files are kept, 0 to keep them",
+			&config.Audit.MaxAge},
+		{"audit-database", "AUDIT_DATABASE", "insert the audit events in the audit.events table",
+			&config.Audit.Database},
+		{"admission-protected-labels", "ADMISSION_PROTECTED_LABELS",
+			"the label keys, or key prefixes ending with /, only the privileged groups may change",
+			&config.Admission.ProtectedLabels},
+		{"admission-privileged-groups", "ADMISSION_PRIVILEGED_GROUPS", "the groups that may change the protected labels",
+			&config.Admission.PrivilegedGroups},
+		{"admission-opa-policy", "ADMISSION_OPA_POLICY",
+			"the path of the OPA document of the reasons to deny a label change, empty to disable",
+			&config.Admission.OPAPolicy},
+	}
+}
+
+// addFlag adds the flag of an option, with the current value of the option as the default.
+func addFlag(flagSet *pflag.FlagSet, option option) {
+	usage := fmt.Sprintf("%s, also %s", option.usage, option.environmentVariable)
+
+	switch value := option.value.(type) {
+	case *string:
+		flagSet.StringVar(value, option.flag, *value, usage)
+	case *int:
+		flagSet.IntVar(value, option.flag, *value, usage)
+	case *int32:
+		flagSet.Int32Var(value, option.flag, *value, usage)
+	case *bool:
+		flagSet.BoolVar(value, option.flag, *value, usage)
+	case *float64:
+		flagSet.Float64Var(value, option.flag, *value, usage)
+	case *metav1.Duration:
+		flagSet.DurationVar(&value.Duration, option.flag, value.Duration, usage)
+	case *[]string:
+		flagSet.StringSliceVar(value, option.flag, *value, usage)
+	default:
+		panic(fmt.Sprintf("unsupported type of option %s: %T", option.flag, option.value))
+	}
+}
+
+// Validate returns the errors of the configuration, aggregated.
+func (config *Config) Validate() error {
+	errs := []error{}
+
+	type field struct {
+		name  string
+		value string
+	}
+
+	required := []field{
+		{"server.address", config.Server.Address},
+		{"tls.certificatePath", config.TLS.CertificatePath},
+		{"tls.keyPath", config.TLS.KeyPath},
+		{"database.url", config.Database.URL},
+		{"clusterAPI.url", config.ClusterAPI.URL},
+		{"authorization.url", config.Authorization.URL},
+	}
+
+	if config.Features.GRPC {
+		required = append(required, field{"server.grpcAddress", config.Server.GRPCAddress})
+	}
+
+	for _, requiredField := range required {
+		if requiredField.value == "" {
+			errs = append(errs, fmt.Errorf("%s %w", requiredField.name, errRequired))
+		}
+	}
+
+	if config.Server.BasePath != "" &&
+		(!strings.HasPrefix(config.Server.BasePath, "/") || strings.HasSuffix(config.Server.BasePath, "/")) {
+		errs = append(errs, fmt.Errorf("server.basePath %w: %q must start and must not end with /", errInvalid,
+			config.Server.BasePath))
+	}
+
+	nonNegativeDurations := []struct {
+		name  string
+		value metav1.Duration
+	}{
+		{"server.readTimeout", config.Server.ReadTimeout},
+		{"server.readHeaderTimeout", config.Server.ReadHeaderTimeout},
+		{"server.writeTimeout", config.Server.WriteTimeout},
+		{"server.idleTimeout", config.Server.IdleTimeout},
+		{"tls.reloadInterval", config.TLS.ReloadInterval},
+		{"database.maxConnectionLifetime", config.Database.MaxConnectionLifetime},
+		{"database.maxConnectionIdleTime", config.Database.MaxConnectionIdleTime},
+		{"database.healthCheckPeriod", config.Database.HealthCheckPeriod},
+		{"database.connectTimeout", config.Database.ConnectTimeout},
+		{"cache.authenticationTTL", config.Cache.AuthenticationTTL},
+	}
+
+	for _, duration := range nonNegativeDurations {
+		if duration.value.Duration < 0 {
+			errs = append(errs, fmt.Errorf("%s %w: must not be negative", duration.name, errInvalid))
+		}
+	}
+
+	if config.Server.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("server.shutdownTimeout %w: must be positive", errInvalid))
+	}
+
+	if config.ManagedClusters.WatchInterval.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("managedClusters.watchInterval %w: must be positive", errInvalid))
+	}
+
+	if config.ManagedClusters.PatchRetryAttempts < 1 {
+		errs = append(errs, fmt.Errorf("managedClusters.patchRetryAttempts %w: must be at least 1", errInvalid))
+	}
+
+	if config.Tracing.SamplingRatio < 0 || config.Tracing.SamplingRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.samplingRatio %w: must be from 0 to 1", errInvalid))
+	}
+
+	switch config.Logging.Level {
+	case logging.LevelError, logging.LevelInfo, logging.LevelDebug:
+	default:
+		errs = append(errs, fmt.Errorf("logging.level %w: %q must be error, info or debug", errInvalid,
+			config.Logging.Level))
+	}
+
+	switch auditv1.Level(config.Audit.Level) {
+	case auditv1.LevelNone, auditv1.LevelMetadata, auditv1.LevelRequest, auditv1.LevelRequestResponse:
+	default:
+		errs = append(errs, fmt.Errorf("audit.level %w: %q must be None, Metadata, Request or RequestResponse",
+			errInvalid, config.Audit.Level))
+	}
+
+	if config.Audit.MaxSize < 0 || config.Audit.MaxBackups < 0 || config.Audit.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("audit.maxSize, audit.maxBackups and audit.maxAge %w: must not be negative",
+			errInvalid))
+	}
+
+	if config.Logging.Format != logging.FormatJSON && config.Logging.Format != logging.FormatConsole {
+		errs = append(errs, fmt.Errorf("logging.format %w: %q must be json or console", errInvalid,
+			config.Logging.Format))
+	}
+
+	for _, protectedLabel := range config.Admission.ProtectedLabels {
+		if strings.TrimSpace(protectedLabel) == "" || protectedLabel == "/" {
+			errs = append(errs, fmt.Errorf("admission.protectedLabels %w: %q must be a label key or a key prefix",
+				errInvalid, protectedLabel))
+		}
+	}
+
+	if config.Admission.OPAPolicy != "" && !opaPathRegexp.MatchString(config.Admission.OPAPolicy) {
+		errs = append(errs, fmt.Errorf("admission.opaPolicy %w: %q must be a path like admission/managedclusters/deny",
+			errInvalid, config.Admission.OPAPolicy))
+	}
+
+	if config.Database.MaxConnections < 0 || config.Database.MinConnections < 0 {
+		errs = append(errs, fmt.Errorf("database.maxConnections and database.minConnections %w: must not be negative",
+			errInvalid))
+	} else if config.Database.MaxConnections > 0 && config.Database.MinConnections > config.Database.MaxConnections {
+		errs = append(errs, fmt.Errorf("database.minConnections %w: must not be above database.maxConnections",
+			errInvalid))
+	}
+
+	return utilerrors.NewAggregate(errs)
+}
+
+// Redacted returns a copy of the configuration without the secrets, to be printed.
+func (config *Config) Redacted() *Config {
+	redacted := *config
+	redacted.Database.URL = redactDatabaseURL(config.Database.URL)
+
+	return &redacted
+}
+
+// redactDatabaseURL returns the database URL without its password, in the URL or in the keyword/value format.
+func redactDatabaseURL(databaseURL string) string {
+	parsedURL, err := url.Parse(databaseURL)
+	if err != nil || parsedURL.Scheme == "" {
+		return keywordPasswordRegexp.ReplaceAllString(databaseURL, "${1}"+redactedValue)
+	}
+
+	query := parsedURL.Query()
+	if query.Get("password") != "" {
+		query.Set("password", redactedValue)
+		parsedURL.RawQuery = query.Encode()
+	}
+
+	return parsedURL.Redacted()
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package discovery
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// APIPath - the path of the core API group (empty in this server).
+	APIPath = "/api"
+	// APIsPath - the path of the named API groups.
+	APIsPath = "/apis"
+	// ClusterGroupPath - the path of the cluster.open-cluster-management.io API group.
+	ClusterGroupPath = APIsPath + "/cluster.open-cluster-management.io"
+	// ClusterGroupVersionPath - the path of the cluster.open-cluster-management.io/v1 API group version.
+	ClusterGroupVersionPath = ClusterGroupPath + "/v1"
+)
+
+// the verbs that are supported for managed clusters, see createServer.
+var managedClusterVerbs = metav1.Verbs{"get", "list", "watch", "patch"}
+
+// APIVersions returns the discovery document of the core API group, kubectl requires it. The core group is not served.
+func APIVersions() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		ginCtx.JSON(http.StatusOK, &metav1.APIVersions{
+			TypeMeta: metav1.TypeMeta{Kind: "APIVersions"},
+			Versions: []string{},
+		})
+	}
+}
+
+// APIGroupList returns the discovery document of the API groups.
+func APIGroupList() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		ginCtx.JSON(http.StatusOK, &metav1.APIGroupList{
+			TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"},
+			Groups:   []metav1.APIGroup{clusterAPIGroup()},
+		})
+	}
+}
+
+// APIGroup returns the discovery document of the cluster.open-cluster-management.io API group.
+func APIGroup() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		apiGroup := clusterAPIGroup()
+		apiGroup.TypeMeta = metav1.TypeMeta{Kind: "APIGroup", APIVersion: "v1"}
+
+		ginCtx.JSON(http.StatusOK, &apiGroup)
+	}
+}
+
+// APIResourceList returns the discovery document of the resources of cluster.open-cluster-management.io/v1.
+func APIResourceList() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		ginCtx.JSON(http.StatusOK, &metav1.APIResourceList{
+			TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+			GroupVersion: clusterv1.GroupVersion.String(),
+			APIResources: []metav1.APIResource{
+				{
+					Name:         "managedclusters",
+					SingularName: "managedcluster",
+					Namespaced:   false,
+					Kind:         "ManagedCluster",
+					Verbs:        managedClusterVerbs,
+					ShortNames:   []string{"mcl", "mcls"},
+				},
+			},
+		})
+	}
+}
+
+func clusterAPIGroup() metav1.APIGroup {
+	groupVersion := metav1.GroupVersionForDiscovery{
+		GroupVersion: clusterv1.GroupVersion.String(),
+		Version:      clusterv1.GroupVersion.Version,
+	}
+
+	return metav1.APIGroup{
+		Name:             clusterv1.GroupName,
+		Versions:         []metav1.GroupVersionForDiscovery{groupVersion},
+		PreferredVersion: groupVersion,
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package health contains the health checks of the server, served like the /livez, /readyz and /healthz endpoints
+// of the Kubernetes API server.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+)
+
+const (
+	// LivezPath - the path of the liveness checks.
+	LivezPath = "/livez"
+	// ReadyzPath - the path of the readiness checks.
+	ReadyzPath = "/readyz"
+	// HealthzPath - the path of all the checks, like ReadyzPath.
+	HealthzPath = "/healthz"
+
+	verboseQueryParameter = "verbose"
+	excludeQueryParameter = "exclude"
+	checkTimeout          = 5 * time.Second
+)
+
+var (
+	errUnhealthyStatus    = errors.New("unhealthy response status")
+	errCertificateExpired = errors.New("the certificate expired")
+	errCertificateNotYet  = errors.New("the certificate is not yet valid")
+)
+
+// Checker is a named health check. Check returns a detail of the check if it passes, or an error if it fails.
+type Checker struct {
+	Name  string
+	Check func(ctx context.Context) (string, error)
+}
+
+// checkResult is the result of a Checker.
+type checkResult struct {
+	name   string
+	detail string
+	err    error
+}
+
+// PingChecker returns a Checker that always passes, it checks that the server serves requests.
+func PingChecker() Checker {
+	return Checker{
+		Name: "ping",
+		Check: func(context.Context) (string, error) {
+			return "", nil
+		},
+	}
+}
+
+// DatabaseChecker returns a Checker that pings the database through a connection of the pool.
+func DatabaseChecker(dbConnectionPool *pgxpool.Pool) Checker {
+	return Checker{
+		Name: "database",
+		Check: func(ctx context.Context) (string, error) {
+			if err := dbConnectionPool.Ping(ctx); err != nil {
+				return "", fmt.Errorf("failed to ping the database: %w", err)
+			}
+
+			stat := dbConnectionPool.Stat()
+
+			return fmt.Sprintf("%d/%d connections", stat.TotalConns(), stat.MaxConns()), nil
+		},
+	}
+}
+
+// HTTPChecker returns a Checker that the server of url is reachable with the client of the CA bundle. The server is
+// considered healthy if it responds without a server error, the responses of unauthenticated requests included.
+func HTTPChecker(name string, url string, caBundle *certificates.CABundle) Checker {
+	return Checker{
+		Name: name,
+		Check: func(ctx context.Context) (string, error) {
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+			if err != nil {
+				return "", fmt.Errorf("unable to create request: %w", err)
+			}
+
+			response, err := caBundle.Client().Do(request)
+			if err != nil {
+				return "", fmt.Errorf("unable to reach %s: %w", url, err)
+			}
+			defer response.Body.Close()
+
+			if response.StatusCode >= http.StatusInternalServerError {
+				return "", fmt.Errorf("%w from %s: %d", errUnhealthyStatus, url, response.StatusCode)
+			}
+
+			return fmt.Sprintf("%s responded %d", url, response.StatusCode), nil
+		},
+	}
+}
+
+// CertificateChecker returns a Checker that the current certificate of the server is valid.
+func CertificateChecker(certificate *certificates.Certificate) Checker {
+	return Checker{
+		Name: "certificate",
+		Check: func(context.Context) (string, error) {
+			leaf := certificate.Leaf()
+			now := time.Now()
+
+			if now.Before(leaf.NotBefore) {
+				return "", fmt.Errorf("%w: valid from %s", errCertificateNotYet, leaf.NotBefore.UTC().Format(time.RFC3339))
+			}
+
+			if now.After(leaf.NotAfter) {
+				return "", fmt.Errorf("%w at %s", errCertificateExpired, leaf.NotAfter.UTC().Format(time.RFC3339))
+			}
+
+			return fmt.Sprintf("expires at %s, in %s", leaf.NotAfter.UTC().Format(time.RFC3339),
+				leaf.NotAfter.Sub(now).Round(time.Minute)), nil
+		},
+	}
+}
+
+// Handler middleware. The checks run concurrently, the response is ok if they all pass. The failed checks are always
+// listed, all the checks are listed with the verbose query parameter. The exclude query parameters skip checks.
+func Handler(name string, checkers ...Checker) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		_, verbose := ginCtx.GetQuery(verboseQueryParameter)
+		excluded := map[string]bool{}
+
+		for _, checkName := range ginCtx.QueryArray(excludeQueryParameter) {
+			excluded[checkName] = true
+		}
+
+		results := runChecks(ginCtx.Request.Context(), checkers, excluded)
+		failed := false
+
+		for _, result := range results {
+			if result.err != nil {
+				failed = true
+
+				logging.FromContext(ginCtx.Request.Context()).Error(result.err, "Health check failed", "endpoint", name,
+					"check", result.name)
+			}
+		}
+
+		if !failed && !verbose {
+			ginCtx.String(http.StatusOK, "ok")
+			return
+		}
+
+		var body strings.Builder
+
+		for _, result := range results {
+			switch {
+			case result.err != nil:
+				fmt.Fprintf(&body, "[-]%s failed: %v\n", result.name, result.err)
+			case result.detail != "":
+				fmt.Fprintf(&body, "[+]%s ok: %s\n", result.name, result.detail)
+			default:
+				fmt.Fprintf(&body, "[+]%s ok\n", result.name)
+			}
+		}
+
+		for _, checker := range checkers {
+			if excluded[checker.Name] {
+				fmt.Fprintf(&body, "[+]%s excluded: ok\n", checker.Name)
+			}
+		}
+
+		if failed {
+			fmt.Fprintf(&body, "%s check failed\n", name)
+			ginCtx.String(http.StatusInternalServerError, body.String())
+
+			return
+		}
+
+		fmt.Fprintf(&body, "%s check passed\n", name)
+		ginCtx.String(http.StatusOK, body.String())
+	}
+}
+
+// runChecks runs the checks that are not excluded concurrently, each with a timeout, and returns their results in the
+// order of the checkers.
+func runChecks(ctx context.Context, checkers []Checker, excluded map[string]bool) []checkResult {
+	results := make([]checkResult, len(checkers))
+
+	var waitGroup sync.WaitGroup
+
+	for index, checker := range checkers {
+		if excluded[checker.Name] {
+			continue
+		}
+
+		waitGroup.Add(1)
+
+		go func(index int, checker Checker) {
+			defer waitGroup.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			detail, err := checker.Check(checkCtx)
+			results[index] = checkResult{name: checker.Name, detail: detail, err: err}
+		}(index, checker)
+	}
+
+	waitGroup.Wait()
+
+	ranResults := make([]checkResult, 0, len(results))
+
+	for index, checker := range checkers {
+		if !excluded[checker.Name] {
+			ranResults = append(ranResults, results[index])
+		}
+	}
+
+	return ranResults
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package logging contains the structured logging of the server. Each request has a logger in its context, with the
+// request ID, the route and, once authenticated, the user. The values of the sensitive keys (e.g. tokens and passwords)
+// are redacted from all the log entries.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// LevelError logs the errors only.
+	LevelError = "error"
+	// LevelInfo logs the errors and the informational messages, e.g. the served requests.
+	LevelInfo = "info"
+	// LevelDebug logs everything, including the SQL queries and the groups of the users (logr V(1)).
+	LevelDebug = "debug"
+
+	// FormatJSON logs a JSON object per entry, for production.
+	FormatJSON = "json"
+	// FormatConsole logs human-readable lines, for development.
+	FormatConsole = "console"
+
+	redactedValue = "xxxxx"
+)
+
+var (
+	errUnknownLevel  = errors.New("unknown log level")
+	errUnknownFormat = errors.New("unknown log format")
+
+	// the substrings of the keys whose values are redacted, in lowercase.
+	sensitiveKeys = []string{"authorization", "token", "password", "secret", "cookie"}
+
+	// the logger of the code that runs outside of requests, e.g. at startup.
+	defaultLogger      = logr.Discard()
+	defaultLoggerMutex sync.RWMutex
+)
+
+// New returns a logger of the level (LevelError, LevelInfo or LevelDebug) and the format (FormatJSON or
+// FormatConsole).
+func New(level string, format string) (logr.Logger, error) {
+	var zapConfig zap.Config
+
+	switch format {
+	case FormatJSON:
+		zapConfig = zap.NewProductionConfig()
+	case FormatConsole:
+		zapConfig = zap.NewDevelopmentConfig()
+		// the development config panics on the programming errors of the log calls, e.g. an odd number of values
+		zapConfig.Development = false
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownFormat, format)
+	}
+
+	switch level {
+	case LevelError:
+		zapConfig.Level = zap.NewAtomicLevelAt(zapcore.ErrorLevel)
+	case LevelInfo:
+		zapConfig.Level = zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	case LevelDebug:
+		zapConfig.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnknownLevel, level)
+	}
+
+	zapLog, err := zapConfig.Build(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &redactingCore{Core: core}
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the logger: %w", err)
+	}
+
+	return zapr.NewLogger(zapLog), nil
+}
+
+// SetDefault sets the logger of the code that runs outside of requests, and the fallback of FromContext.
+func SetDefault(log logr.Logger) {
+	defaultLoggerMutex.Lock()
+	defer defaultLoggerMutex.Unlock()
+
+	defaultLogger = log
+}
+
+// Default returns the logger set by SetDefault.
+func Default() logr.Logger {
+	defaultLoggerMutex.RLock()
+	defer defaultLoggerMutex.RUnlock()
+
+	return defaultLogger
+}
+
+// FromContext returns the logger of the request of ctx, or the default logger.
+func FromContext(ctx context.Context) logr.Logger {
+	if log := logr.FromContext(ctx); log != nil {
+		return log
+	}
+
+	return Default()
+}
+
+// IntoContext returns a copy of ctx with the logger.
+func IntoContext(ctx context.Context, log logr.Logger) context.Context {
+	return logr.NewContext(ctx, log)
+}
+
+// redactingCore is a zapcore.Core that redacts the values of the sensitive keys.
+type redactingCore struct {
+	zapcore.Core
+}
+
+// With adds the fields, redacted, see zapcore.Core.
+func (core *redactingCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactingCore{Core: core.Core.With(redact(fields))}
+}
+
+// Check adds this core to the checked entry if the level is enabled, see zapcore.Core.
+func (core *redactingCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if core.Enabled(entry.Level) {
+		return checked.AddCore(entry, core)
+	}
+
+	return checked
+}
+
+// Write writes the entry with the fields redacted, see zapcore.Core.
+func (core *redactingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return core.Core.Write(entry, redact(fields)) //nolint:wrapcheck // the error of the wrapped core
+}
+
+// redact returns the fields with the values of the sensitive keys redacted.
+func redact(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+
+	for index, field := range fields {
+		if !isSensitive(field.Key) {
+			continue
+		}
+
+		if redacted == nil { // the fields of the caller are not modified
+			redacted = append([]zapcore.Field{}, fields...)
+		}
+
+		redacted[index] = zap.String(field.Key, redactedValue)
+	}
+
+	if redacted == nil {
+		return fields
+	}
+
+	return redacted
+}
+
+func isSensitive(key string) bool {
+	lowercaseKey := strings.ToLower(key)
+
+	for _, sensitiveKey := range sensitiveKeys {
+		if strings.Contains(lowercaseKey, sensitiveKey) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,143 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package logging
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-logr/logr"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIDHeader - the header of the ID of a request, set in the responses. The ID of the request is kept if it
+	// is valid, e.g. set by a proxy.
+	RequestIDHeader = "X-Request-Id"
+
+	requestIDMetadataKey = "x-request-id" // gRPC metadata keys are lowercase
+	unmatchedRoute       = "<unmatched>"
+)
+
+// requestIDContextKey is the key of the request ID in the context of a request.
+type requestIDContextKey struct{}
+
+// the request IDs accepted from the clients, other IDs are replaced so that they cannot forge log entries.
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// Middleware sets the logger of each request, with the request ID, the method and the route template, in the context
+// of the request, and logs the served requests.
+func Middleware(log logr.Logger) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		start := time.Now()
+
+		requestID := ginCtx.GetHeader(RequestIDHeader)
+		if !requestIDRegexp.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		ginCtx.Header(RequestIDHeader, requestID)
+
+		route := ginCtx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		requestLog := log.WithValues("requestID", requestID, "method", ginCtx.Request.Method, "route", route)
+		ctx := context.WithValue(ginCtx.Request.Context(), requestIDContextKey{}, requestID)
+		ginCtx.Request = ginCtx.Request.WithContext(IntoContext(ctx, requestLog))
+
+		ginCtx.Next()
+
+		// the logger of the request may have more values by now, e.g. the user
+		FromContext(ginCtx.Request.Context()).Info("Request served", "status", ginCtx.Writer.Status(),
+			"duration", time.Since(start).String())
+	}
+}
+
+// WithValues adds the values to the logger of the request, and returns the logger.
+func WithValues(ginCtx *gin.Context, keysAndValues ...interface{}) logr.Logger {
+	requestLog := FromContext(ginCtx.Request.Context()).WithValues(keysAndValues...)
+	ginCtx.Request = ginCtx.Request.WithContext(IntoContext(ginCtx.Request.Context(), requestLog))
+
+	return requestLog
+}
+
+// RequestIDFromContext returns the ID of the request (or of the gRPC call) of ctx, empty if the request is not logged.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+
+	return requestID
+}
+
+// UnaryServerInterceptor sets the logger of each unary gRPC call in its context, like Middleware, and logs the served
+// calls.
+func UnaryServerInterceptor(log logr.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = callContext(ctx, log, info.FullMethod)
+
+		response, err := handler(ctx, request)
+
+		FromContext(ctx).Info("Call served", "code", status.Code(err).String(), "duration", time.Since(start).String())
+
+		return response, err
+	}
+}
+
+// StreamServerInterceptor sets the logger of each streaming gRPC call in its context, like Middleware, and logs the
+// served calls.
+func StreamServerInterceptor(log logr.Logger) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := callContext(stream.Context(), log, info.FullMethod)
+
+		err := handler(server, &loggedServerStream{ServerStream: stream, ctx: ctx})
+
+		FromContext(ctx).Info("Call served", "code", status.Code(err).String(), "duration", time.Since(start).String())
+
+		return err
+	}
+}
+
+// callContext returns the context of a gRPC call with its logger, with the request ID of the metadata if it is valid
+// and the trace ID if the call is traced.
+func callContext(ctx context.Context, log logr.Logger, method string) context.Context {
+	incomingMetadata, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := ""
+	if requestIDs := incomingMetadata.Get(requestIDMetadataKey); len(requestIDs) > 0 {
+		requestID = requestIDs[0]
+	}
+
+	if !requestIDRegexp.MatchString(requestID) {
+		requestID = uuid.New().String()
+	}
+
+	callLog := log.WithValues("requestID", requestID, "method", method)
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		callLog = callLog.WithValues("traceID", spanContext.TraceID().String())
+	}
+
+	return IntoContext(context.WithValue(ctx, requestIDContextKey{}, requestID), callLog)
+}
+
+// loggedServerStream is a server stream with the context of the logged call.
+type loggedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *loggedServerStream) Context() context.Context {
+	return stream.ctx
+}
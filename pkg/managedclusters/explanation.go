@@ -0,0 +1,219 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+)
+
+const (
+	userQueryParameter      = "user"
+	groupQueryParameter     = "group"
+	decisionsQueryParameter = "decisions"
+	clusterQueryParameter   = "cluster"
+)
+
+// authorizationExplanation explains the managed clusters visible to a user, with the decision of each managed cluster
+// if requested. The denied managed clusters are listed for the members of the admin groups only, the other users get
+// their number.
+type authorizationExplanation struct {
+	*authorization.Explanation
+	Decisions      []*clusterDecision `json:"decisions,omitempty"`
+	DeniedClusters *int64             `json:"deniedClusters,omitempty"`
+}
+
+type clusterDecision struct {
+	Cluster    string `json:"cluster"`
+	HubCluster string `json:"hubCluster"`
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason"`
+}
+
+// ExplainAuthorization middleware, explains the managed clusters visible to the user, or to the user and the groups of
+// the user and group query parameters for the members of the admin groups.
+func ExplainAuthorization(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool, adminGroups []string) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		explainedUser, explainedGroups := user, groups
+		isAdmin := isMemberOfAny(groups, adminGroups)
+
+		otherUser, hasOtherUser := ginCtx.GetQuery(userQueryParameter)
+		otherGroups, hasOtherGroups := ginCtx.GetQueryArray(groupQueryParameter)
+
+		if hasOtherUser || hasOtherGroups {
+			if !isAdmin {
+				ginCtx.JSON(http.StatusForbidden, gin.H{"status": fmt.Sprintf(
+					"only the members of the groups %v can explain the authorization of other users", adminGroups)})
+
+				return
+			}
+
+			if hasOtherUser {
+				explainedUser = otherUser
+			}
+
+			explainedGroups = otherGroups
+			if explainedGroups == nil {
+				explainedGroups = []string{}
+			}
+		}
+
+		withDecisions := false
+
+		if value := ginCtx.Query(decisionsQueryParameter); value != "" {
+			var err error
+
+			if withDecisions, err = strconv.ParseBool(value); err != nil {
+				ginCtx.JSON(http.StatusBadRequest, gin.H{"status": "decisions must be true or false"})
+				return
+			}
+		}
+
+		explanation := &authorizationExplanation{
+			Explanation: authorization.Explain(ginCtx.Request.Context(), explainedUser, explainedGroups,
+				authorizationURL, authorizationCABundle, authorization.ClustersQuery, authorization.ClusterUnknown),
+		}
+
+		if withDecisions {
+			decisions, deniedClusters, err := getClusterDecisions(ginCtx.Request.Context(), explanation.Explanation,
+				ginCtx.Query(clusterQueryParameter), !isAdmin, dbConnectionPool)
+			if err != nil {
+				ginCtx.String(http.StatusInternalServerError, "internal error")
+				log.Error(err, "Error in querying the decisions of managed clusters")
+
+				return
+			}
+
+			explanation.Decisions, explanation.DeniedClusters = decisions, deniedClusters
+		}
+
+		ginCtx.JSON(http.StatusOK, explanation)
+	}
+}
+
+// getClusterDecisions returns whether each managed cluster (of the name, if it is not empty) is visible according to
+// the explanation, and why: the first query of the partial evaluation that holds for the managed cluster. If
+// onlyAllowed is true, only the decisions of the visible managed clusters are returned, with the number of the denied
+// ones, so that the explanation does not disclose the managed clusters the user may not view.
+func getClusterDecisions(ctx context.Context, explanation *authorization.Explanation, cluster string, onlyAllowed bool,
+	dbConnectionPool *pgxpool.Pool) ([]*clusterDecision, *int64, error) {
+	condition := "TRUE"
+
+	var deniedClusters *int64
+
+	if onlyAllowed {
+		condition = explanation.Filter
+		deniedClusters = new(int64)
+
+		if err := dbConnectionPool.QueryRow(ctx, fmt.Sprintf(`SELECT COUNT(*) FROM status.managed_clusters
+			WHERE ($1 = '' OR payload -> 'metadata' ->> 'name' = $1) AND NOT COALESCE((%s), FALSE)`, condition),
+			cluster).Scan(deniedClusters); err != nil {
+			return nil, nil, fmt.Errorf("failed to count the denied managed clusters: %w", err)
+		}
+	}
+
+	// the predicates are evaluated separately, to find the query that allows each managed cluster
+	query := fmt.Sprintf(`SELECT leaf_hub_name, payload -> 'metadata' ->> 'name', ARRAY[%s]::boolean[]
+		FROM status.managed_clusters WHERE ($1 = '' OR payload -> 'metadata' ->> 'name' = $1) AND %s
+		ORDER BY payload -> 'metadata' ->> 'name', leaf_hub_name`, strings.Join(explanation.Predicates, ", "),
+		condition)
+
+	logging.FromContext(ctx).V(1).Info("Decisions query", "sql", query)
+
+	rows, err := dbConnectionPool.Query(ctx, query, cluster)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query the managed clusters: %w", err)
+	}
+	defer rows.Close()
+
+	decisions := []*clusterDecision{}
+
+	for rows.Next() {
+		var (
+			decision = &clusterDecision{}
+			holds    []bool
+		)
+
+		if err := rows.Scan(&decision.HubCluster, &decision.Cluster, &holds); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan a managed cluster: %w", err)
+		}
+
+		decision.Allowed, decision.Reason = explainDecision(explanation, holds)
+		decisions = append(decisions, decision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read the managed clusters: %w", err)
+	}
+
+	return decisions, deniedClusters, nil
+}
+
+// explainDecision returns whether a managed cluster is visible, given which predicates of the explanation hold for it,
+// and the reason.
+func explainDecision(explanation *authorization.Explanation, holds []bool) (bool, string) {
+	switch {
+	case explanation.Error != "":
+		return false, "the partial evaluation of the authorization query failed"
+	case len(explanation.Predicates) == 0:
+		return false, "the partial evaluation of the authorization query allows no managed cluster"
+	case explanation.AllowsAll():
+		return true, "the partial evaluation of the authorization query allows all the managed clusters"
+	}
+
+	for index, predicateHolds := range holds {
+		if predicateHolds {
+			return true, fmt.Sprintf("query %d of the partial evaluation holds: %s", index,
+				explanation.Predicates[index])
+		}
+	}
+
+	reason := fmt.Sprintf("none of the %d queries of the partial evaluation holds", len(explanation.Predicates))
+	if len(explanation.UntranslatedTerms) > 0 {
+		reason += fmt.Sprintf(", %d terms could not be translated to SQL and are FALSE (TRUE if negated)",
+			len(explanation.UntranslatedTerms))
+	}
+
+	return false, reason
+}
+
+// isMemberOfAny returns true if one of the groups is one of the other groups.
+func isMemberOfAny(groups []string, otherGroups []string) bool {
+	for _, group := range groups {
+		for _, otherGroup := range otherGroups {
+			if group == otherGroup {
+				return true
+			}
+		}
+	}
+
+	return false
+}
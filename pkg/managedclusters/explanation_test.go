@@ -0,0 +1,77 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+)
+
+// the table of the managed clusters of the decisions tests, see testDatabaseURLEnvironmentVariable.
+var testManagedClustersSchema = []string{
+	`CREATE SCHEMA IF NOT EXISTS status`,
+	`CREATE TABLE IF NOT EXISTS status.managed_clusters (
+		leaf_hub_name text NOT NULL,
+		payload jsonb NOT NULL)`,
+}
+
+func TestClusterDecisionsOfNonAdmin(t *testing.T) {
+	ctx, dbConnectionPool := connectTestDatabase(t, testManagedClustersSchema)
+
+	// the same cluster name in the hub cluster the user may view and in the other hub clusters
+	cluster := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+	allowedHubCluster, deniedHubClusters := "allowed-hub", []string{"denied-hub1", "denied-hub2"}
+
+	for _, hubCluster := range append([]string{allowedHubCluster}, deniedHubClusters...) {
+		if _, err := dbConnectionPool.Exec(ctx, `INSERT INTO status.managed_clusters (leaf_hub_name, payload)
+			VALUES ($1, jsonb_build_object('metadata', jsonb_build_object('name', $2::text)))`, hubCluster,
+			cluster); err != nil {
+			t.Fatalf("failed to insert a managed cluster: %v", err)
+		}
+	}
+
+	t.Cleanup(func() {
+		if _, err := dbConnectionPool.Exec(context.Background(),
+			`DELETE FROM status.managed_clusters WHERE payload -> 'metadata' ->> 'name' = $1`, cluster); err != nil {
+			t.Errorf("failed to delete the managed clusters of the test: %v", err)
+		}
+	})
+
+	predicate := fmt.Sprintf("(leaf_hub_name = '%s')", allowedHubCluster)
+	explanation := &authorization.Explanation{Predicates: []string{predicate}, Filter: predicate}
+
+	decisions, deniedClusters, err := getClusterDecisions(ctx, explanation, cluster, true, dbConnectionPool)
+	if err != nil {
+		t.Fatalf("failed to get the decisions: %v", err)
+	}
+
+	if len(decisions) != 1 || decisions[0].HubCluster != allowedHubCluster || !decisions[0].Allowed {
+		t.Errorf("the decisions of a user outside the admin groups are %+v, expected only the cluster of %s",
+			decisions, allowedHubCluster)
+	}
+
+	if deniedClusters == nil || *deniedClusters != int64(len(deniedHubClusters)) {
+		t.Errorf("the number of the denied clusters is %v, expected %d", deniedClusters, len(deniedHubClusters))
+	}
+
+	// the members of the admin groups get the denied clusters too
+	decisions, deniedClusters, err = getClusterDecisions(ctx, explanation, cluster, false, dbConnectionPool)
+	if err != nil {
+		t.Fatalf("failed to get the decisions: %v", err)
+	}
+
+	if len(decisions) != 1+len(deniedHubClusters) || deniedClusters != nil {
+		t.Errorf("the decisions of a member of the admin groups are %+v, expected all the clusters", decisions)
+	}
+
+	for _, decision := range decisions {
+		if decision.Allowed != (decision.HubCluster == allowedHubCluster) {
+			t.Errorf("the decision of the cluster of %s is %+v", decision.HubCluster, decision)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/negotiation"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/util"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const managedClustersResource = "managedclusters"
+
+// the managed clusters with the version of their labels in the spec (0 if their labels were never changed) as their
+// metadata.resourceVersion, so that the patches of the labels can be conditioned on the version the clients read.
+var managedClustersRelation = managedClustersRelationWhere("TRUE")
+
+// managedClustersRelationWhere returns managedClustersRelation of the managed clusters of the condition, on the payload
+// of status.managed_clusters. Unlike a condition on the relation, it can use the indexes of the table.
+func managedClustersRelationWhere(condition string) string {
+	return fmt.Sprintf(`(SELECT cluster.leaf_hub_name,
+	jsonb_set(cluster.payload, '{metadata,resourceVersion}', to_jsonb(COALESCE(labels.version, 0)::text)) AS payload
+	FROM status.managed_clusters AS cluster
+	LEFT JOIN spec.managed_clusters_labels AS labels ON labels.leaf_hub_name = cluster.leaf_hub_name AND
+	labels.managed_cluster_name = cluster.payload -> 'metadata' ->> 'name' WHERE %s) AS managed_clusters`, condition)
+}
+
+// the media types of get responses, in the order of preference.
+var getOffers = append(
+	negotiation.Offers([]string{negotiation.MediaTypeJSON, negotiation.MediaTypeYAML}, "", negotiation.AsTable),
+	negotiation.Offers([]string{negotiation.MediaTypeJSON, negotiation.MediaTypeYAML, negotiation.MediaTypeProtobuf},
+		negotiation.AsPartialObjectMetadata)...)
+
+// Get middleware.
+func Get(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+	customResourceColumnDefinitions := util.GetCustomResourceColumnDefinitions(CRDName,
+		clusterv1.GroupVersion.Version)
+
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		cluster := ginCtx.Param("cluster")
+
+		fields, err := parseFields(ginCtx.QueryArray(fieldsQueryParameter))
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+			return
+		}
+
+		mediaType, acceptable := negotiation.Negotiate(ginCtx.GetHeader("Accept"), getOffers)
+		if !acceptable {
+			negotiation.NotAcceptable(ginCtx, getOffers)
+			return
+		}
+
+		// a cluster name of several hub clusters is ambiguous, rather than one of the clusters
+		hubCluster := ginCtx.Query("hubCluster")
+		if hubCluster == "" {
+			var found bool
+
+			if hubCluster, found = getHubCluster(ginCtx, user, groups, authorizationURL, authorizationCABundle,
+				dbConnectionPool, cluster); !found {
+				return
+			}
+		}
+
+		selectExpression, args := payloadExpression, []interface{}{}
+
+		switch {
+		case mediaType.As() == negotiation.AsPartialObjectMetadata:
+			selectExpression = partialObjectMetadataExpression
+		case len(fields) > 0:
+			selectExpression, args = projectionExpression(fields)
+		}
+
+		query, args := getSQLQuery(ginCtx.Request.Context(), user, groups, authorizationURL, authorizationCABundle,
+			selectExpression, args, cluster, hubCluster)
+		log.V(1).Info("Query", "sql", query)
+
+		var object json.RawMessage
+
+		if err := dbConnectionPool.QueryRow(ginCtx.Request.Context(), query, args...).Scan(&object); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				notFound(ginCtx, cluster)
+				return
+			}
+
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			log.Error(err, "Error in querying managed cluster")
+
+			return
+		}
+
+		writeObject(ginCtx, mediaType, object, len(fields) > 0, customResourceColumnDefinitions)
+	}
+}
+
+// getSQLQuery returns the query that selects selectExpression (with its args) from the managed cluster of the hub
+// cluster visible to the user, and the arguments of the query.
+func getSQLQuery(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, selectExpression string, args []interface{}, cluster string,
+	hubCluster string) (string, []interface{}) {
+	args = append(args, cluster, hubCluster)
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE payload -> 'metadata' ->> 'name' = $%d
+		AND leaf_hub_name = $%d AND %s`,
+		selectExpression, managedClustersRelation, len(args)-1, len(args),
+		authorization.FilterByAuthorization(ctx, user, groups, authorizationURL, authorizationCABundle,
+			authorization.ClustersQuery, authorization.ClusterUnknown))
+
+	return query, args
+}
+
+// writeObject writes a managed cluster (or its projection) according to the media type.
+func writeObject(ginCtx *gin.Context, mediaType negotiation.MediaType, object json.RawMessage, projected bool,
+	customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) {
+	var (
+		response interface{} = object
+		err      error
+	)
+
+	objectMetadata := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(object, objectMetadata); err == nil {
+		setETag(ginCtx, objectMetadata.GetResourceVersion())
+	}
+
+	switch {
+	case mediaType.As() == negotiation.AsTable:
+		response, err = convertToTable(metav1.ListMeta{}, []json.RawMessage{object}, customResourceColumnDefinitions)
+	case mediaType.As() == negotiation.AsPartialObjectMetadata:
+		response = objectMetadata
+	case !projected:
+		managedCluster := &clusterv1.ManagedCluster{}
+		err = json.Unmarshal(object, managedCluster)
+		response = managedCluster
+	}
+
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in converting managed cluster")
+
+		return
+	}
+
+	negotiation.Write(ginCtx, http.StatusOK, mediaType, response)
+}
+
+// notFound writes a Kubernetes NotFound Status for the managed cluster.
+func notFound(ginCtx *gin.Context, cluster string) {
+	status := apierrors.NewNotFound(clusterv1.GroupVersion.WithResource(managedClustersResource).GroupResource(),
+		cluster).Status()
+	status.Kind = "Status"
+	status.APIVersion = metav1.SchemeGroupVersion.Version
+
+	ginCtx.JSON(http.StatusNotFound, status)
+}
+
+// setETag sets the ETag header of the response to the resourceVersion of the managed cluster, the If-Match header of a
+// patch with this ETag succeeds only if the labels did not change since.
+func setETag(ginCtx *gin.Context, resourceVersion string) {
+	if resourceVersion != "" {
+		ginCtx.Header("ETag", strconv.Quote(resourceVersion))
+	}
+}
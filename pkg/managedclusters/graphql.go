@@ -0,0 +1,537 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	graphqlgo "github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+const (
+	graphQLQueryParameter         = "query"
+	graphQLOperationNameParameter = "operationName"
+	graphQLVariablesParameter     = "variables"
+
+	hubClusterArgument    = "hubCluster"
+	nameArgument          = "name"
+	searchArgument        = "search"
+	labelSelectorArgument = "labelSelector"
+	conditionsArgument    = "conditions"
+	limitArgument         = "limit"
+	offsetArgument        = "offset"
+
+	itemsField      = "items"
+	totalCountField = "totalCount"
+
+	labelsExpression      = "payload -> 'metadata' -> 'labels'"
+	clusterNameExpression = "payload -> 'metadata' ->> 'name'"
+
+	// the page size of the managedClusters query if its limit is not specified, and the maximum limit, so that a
+	// query does not return all the managed clusters of the database at once.
+	defaultGraphQLLimit = 100
+	maxGraphQLLimit     = 1000
+)
+
+var (
+	errMissingGraphQLQuery   = errors.New("missing GraphQL query")
+	errNegativeOffset        = errors.New("offset must not be negative")
+	errInvalidLimit          = fmt.Errorf("limit must be from 1 to %d", maxGraphQLLimit)
+	errGraphQLCallerNotFound = errors.New("the GraphQL caller is not found in the context")
+	errUnsupportedOperator   = errors.New("unsupported label selector operator")
+	errInternal              = errors.New("internal error")
+)
+
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type graphQLContextKey struct{}
+
+// graphQLCaller is the caller of a GraphQL request.
+type graphQLCaller struct {
+	filter string // the SQL filter of the managed clusters the caller is authorized to view, by OPA
+}
+
+// managedClustersFilter is the filter of the managedClusters and managedCluster GraphQL queries, pushed down to SQL.
+type managedClustersFilter struct {
+	hubCluster    string
+	name          string
+	search        string
+	labelSelector labels.Selector
+	conditions    []map[string]interface{}
+	limit         int
+	offset        int
+}
+
+// GraphQL middleware.
+func GraphQL(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+	schema, schemaErr := graphQLSchema(dbConnectionPool)
+
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		if schemaErr != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			log.Error(schemaErr, "Error in building the GraphQL schema")
+
+			return
+		}
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		request, err := parseGraphQLRequest(ginCtx)
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+			return
+		}
+
+		caller := &graphQLCaller{
+			filter: authorization.FilterByAuthorization(ginCtx.Request.Context(), user, groups, authorizationURL,
+				authorizationCABundle, authorization.ClustersQuery, authorization.ClusterUnknown),
+		}
+
+		result := graphqlgo.Do(graphqlgo.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			VariableValues: request.Variables,
+			OperationName:  request.OperationName,
+			Context:        context.WithValue(ginCtx.Request.Context(), graphQLContextKey{}, caller),
+		})
+
+		ginCtx.JSON(http.StatusOK, result)
+	}
+}
+
+// parseGraphQLRequest returns the GraphQL request of the JSON body of a POST request, or of the query parameters of a
+// GET request.
+func parseGraphQLRequest(ginCtx *gin.Context) (*graphQLRequest, error) {
+	request := &graphQLRequest{}
+
+	if ginCtx.Request.Method == http.MethodPost {
+		if err := ginCtx.ShouldBindJSON(request); err != nil {
+			return nil, fmt.Errorf("invalid GraphQL request: %w", err)
+		}
+	} else {
+		request.Query = ginCtx.Query(graphQLQueryParameter)
+		request.OperationName = ginCtx.Query(graphQLOperationNameParameter)
+
+		if variables := ginCtx.Query(graphQLVariablesParameter); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				return nil, fmt.Errorf("invalid GraphQL variables: %w", err)
+			}
+		}
+	}
+
+	if strings.TrimSpace(request.Query) == "" {
+		return nil, errMissingGraphQLQuery
+	}
+
+	return request, nil
+}
+
+// graphQLSchema returns the GraphQL schema of the managed clusters.
+func graphQLSchema(dbConnectionPool *pgxpool.Pool) (graphqlgo.Schema, error) {
+	managedClusterType, err := managedClusterGraphQLType()
+	if err != nil {
+		return graphqlgo.Schema{}, err
+	}
+
+	conditionFilterType := graphqlgo.NewInputObject(graphqlgo.InputObjectConfig{
+		Name:        "ConditionFilter",
+		Description: "matches the managed clusters that have a condition of the type, with the status if specified",
+		Fields: graphqlgo.InputObjectConfigFieldMap{
+			conditionTypeArgument:   &graphqlgo.InputObjectFieldConfig{Type: graphqlgo.NewNonNull(graphqlgo.String)},
+			conditionStatusArgument: &graphqlgo.InputObjectFieldConfig{Type: graphqlgo.String},
+		},
+	})
+
+	pageType := graphqlgo.NewObject(graphqlgo.ObjectConfig{
+		Name: "ManagedClusterPage",
+		Fields: graphqlgo.Fields{
+			itemsField: &graphqlgo.Field{
+				Type: graphqlgo.NewNonNull(graphqlgo.NewList(graphqlgo.NewNonNull(managedClusterType))),
+				Resolve: func(params graphqlgo.ResolveParams) (interface{}, error) {
+					filter, _ := params.Source.(*managedClustersFilter)
+
+					managedClusters, err := queryGraphQLManagedClusters(params, managedClusterType, filter,
+						dbConnectionPool)
+					if err != nil {
+						return nil, err
+					}
+
+					metrics.ObserveListRows(metrics.APIGraphQL, len(managedClusters))
+
+					return managedClusters, nil
+				},
+			},
+			totalCountField: &graphqlgo.Field{
+				Type:        graphqlgo.NewNonNull(graphqlgo.Int),
+				Description: "the number of the managed clusters that match the filter, regardless of limit and offset",
+				Resolve: func(params graphqlgo.ResolveParams) (interface{}, error) {
+					filter, _ := params.Source.(*managedClustersFilter)
+
+					return countGraphQLManagedClusters(params.Context, filter, dbConnectionPool)
+				},
+			},
+		},
+	})
+
+	queryType := graphqlgo.NewObject(graphqlgo.ObjectConfig{
+		Name: "Query",
+		Fields: graphqlgo.Fields{
+			"managedClusters": &graphqlgo.Field{
+				Type:        graphqlgo.NewNonNull(pageType),
+				Description: "the managed clusters the caller is authorized to view, ordered by name",
+				Args: graphqlgo.FieldConfigArgument{
+					hubClusterArgument: &graphqlgo.ArgumentConfig{Type: graphqlgo.String},
+					nameArgument:       &graphqlgo.ArgumentConfig{Type: graphqlgo.String},
+					searchArgument: &graphqlgo.ArgumentConfig{
+						Type:        graphqlgo.String,
+						Description: "free text to search in the names, the labels, the claims and the URLs",
+					},
+					labelSelectorArgument: &graphqlgo.ArgumentConfig{
+						Type:        graphqlgo.String,
+						Description: "a Kubernetes label selector, e.g. environment=dev,!deprecated",
+					},
+					conditionsArgument: &graphqlgo.ArgumentConfig{
+						Type: graphqlgo.NewList(graphqlgo.NewNonNull(conditionFilterType)),
+					},
+					limitArgument: &graphqlgo.ArgumentConfig{
+						Type:         graphqlgo.Int,
+						DefaultValue: defaultGraphQLLimit,
+						Description:  fmt.Sprintf("the maximum number of managed clusters, up to %d", maxGraphQLLimit),
+					},
+					offsetArgument: &graphqlgo.ArgumentConfig{Type: graphqlgo.Int, DefaultValue: 0},
+				},
+				Resolve: func(params graphqlgo.ResolveParams) (interface{}, error) {
+					return newManagedClustersFilter(params.Args)
+				},
+			},
+			"managedCluster": &graphqlgo.Field{
+				Type: managedClusterType,
+				Description: "a managed cluster the caller is authorized to view. If the hub cluster is not specified " +
+					"and clusters with the same name exist in multiple hub clusters, the first hub cluster by name is " +
+					"chosen",
+				Args: graphqlgo.FieldConfigArgument{
+					nameArgument:       &graphqlgo.ArgumentConfig{Type: graphqlgo.NewNonNull(graphqlgo.String)},
+					hubClusterArgument: &graphqlgo.ArgumentConfig{Type: graphqlgo.String},
+				},
+				Resolve: func(params graphqlgo.ResolveParams) (interface{}, error) {
+					filter, err := newManagedClustersFilter(params.Args)
+					if err != nil {
+						return nil, err
+					}
+
+					filter.limit = 1
+
+					managedClusters, err := queryGraphQLManagedClusters(params, managedClusterType, filter,
+						dbConnectionPool)
+					if err != nil || len(managedClusters) == 0 {
+						return nil, err
+					}
+
+					return managedClusters[0], nil
+				},
+			},
+		},
+	})
+
+	schema, err := graphqlgo.NewSchema(graphqlgo.SchemaConfig{Query: queryType})
+	if err != nil {
+		return graphqlgo.Schema{}, fmt.Errorf("failed to create the GraphQL schema: %w", err)
+	}
+
+	return schema, nil
+}
+
+func newManagedClustersFilter(args map[string]interface{}) (*managedClustersFilter, error) {
+	filter := &managedClustersFilter{limit: defaultGraphQLLimit}
+
+	filter.hubCluster, _ = args[hubClusterArgument].(string)
+	filter.name, _ = args[nameArgument].(string)
+	filter.search, _ = args[searchArgument].(string)
+	filter.offset, _ = args[offsetArgument].(int)
+
+	if limit, found := args[limitArgument].(int); found {
+		if limit < 1 || limit > maxGraphQLLimit {
+			return nil, errInvalidLimit
+		}
+
+		filter.limit = limit
+	}
+
+	if filter.offset < 0 {
+		return nil, errNegativeOffset
+	}
+
+	if labelSelector, _ := args[labelSelectorArgument].(string); labelSelector != "" {
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid label selector: %w", err)
+		}
+
+		filter.labelSelector = selector
+	}
+
+	conditions, _ := args[conditionsArgument].([]interface{})
+	for _, condition := range conditions {
+		if conditionMap, isMap := condition.(map[string]interface{}); isMap {
+			filter.conditions = append(filter.conditions, conditionMap)
+		}
+	}
+
+	return filter, nil
+}
+
+// where returns the relation of the managed clusters, the WHERE condition of the filter on the relation (including
+// the OPA filter of the caller) and the expression to order by, with the arguments of the condition appended to args.
+// The search condition is in the relation, on status.managed_clusters, so that it can use the trigram index.
+func (filter *managedClustersFilter) where(caller *graphQLCaller, args []interface{}) (string, string, string,
+	[]interface{}, error) {
+	relation := managedClustersRelation
+	conditions := []string{caller.filter}
+	orderBy := clusterNameExpression + ", leaf_hub_name"
+
+	if filter.hubCluster != "" {
+		args = append(args, filter.hubCluster)
+		conditions = append(conditions, fmt.Sprintf("leaf_hub_name = $%d", len(args)))
+	}
+
+	if filter.name != "" {
+		args = append(args, filter.name)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", clusterNameExpression, len(args)))
+	}
+
+	if filter.search != "" {
+		var condition string
+
+		condition, orderBy, args = searchCondition(filter.search, args)
+		orderBy += ", leaf_hub_name"
+		relation = managedClustersRelationWhere(condition)
+	}
+
+	if filter.labelSelector != nil {
+		requirements, _ := filter.labelSelector.Requirements()
+
+		for _, requirement := range requirements {
+			var (
+				condition string
+				err       error
+			)
+
+			condition, args, err = labelRequirementCondition(requirement, args)
+			if err != nil {
+				return "", "", "", nil, err
+			}
+
+			conditions = append(conditions, condition)
+		}
+	}
+
+	for _, condition := range filter.conditions {
+		conditionStatus, _ := condition[conditionStatusArgument].(string)
+
+		args = append(args, condition[conditionTypeArgument], conditionStatus)
+		conditions = append(conditions, fmt.Sprintf(`EXISTS (SELECT 1 FROM
+			jsonb_array_elements(COALESCE(payload -> 'status' -> 'conditions', '[]'::jsonb)) AS condition
+			WHERE condition ->> 'type' = $%d AND ($%d = '' OR condition ->> 'status' = $%d))`,
+			len(args)-1, len(args), len(args)))
+	}
+
+	return relation, strings.Join(conditions, " AND "), orderBy, args, nil
+}
+
+// labelRequirementCondition returns the SQL condition of a requirement of a label selector, with the Kubernetes
+// semantics (e.g. != and notin match the managed clusters without the label).
+func labelRequirementCondition(requirement labels.Requirement, args []interface{}) (string, []interface{}, error) {
+	args = append(args, requirement.Key())
+	key := fmt.Sprintf("$%d", len(args))
+	value := fmt.Sprintf("%s ->> %s", labelsExpression, key)
+
+	switch requirement.Operator() {
+	case selection.Exists:
+		return fmt.Sprintf("(%s ? %s)", labelsExpression, key), args, nil
+	case selection.DoesNotExist:
+		return fmt.Sprintf("NOT COALESCE(%s ? %s, FALSE)", labelsExpression, key), args, nil
+	case selection.Equals, selection.DoubleEquals, selection.In:
+		args = append(args, requirement.Values().List())
+		return fmt.Sprintf("%s = ANY($%d::text[])", value, len(args)), args, nil
+	case selection.NotEquals, selection.NotIn:
+		args = append(args, requirement.Values().List())
+		return fmt.Sprintf("(%s IS NULL OR %s <> ALL($%d::text[]))", value, value, len(args)), args, nil
+	case selection.GreaterThan, selection.LessThan:
+		operator := ">"
+		if requirement.Operator() == selection.LessThan {
+			operator = "<"
+		}
+
+		args = append(args, requirement.Values().List()[0])
+
+		// the label value is compared only if it is an integer, like in Kubernetes
+		return fmt.Sprintf("(CASE WHEN %s ~ '^-?[0-9]+$' THEN (%s)::bigint %s ($%d)::bigint ELSE FALSE END)",
+			value, value, operator, len(args)), args, nil
+	default:
+		return "", nil, fmt.Errorf("%w: %s", errUnsupportedOperator, requirement.Operator())
+	}
+}
+
+// queryGraphQLManagedClusters returns the managed clusters of the filter, with only the fields selected in the GraphQL
+// query.
+func queryGraphQLManagedClusters(params graphqlgo.ResolveParams, managedClusterType *graphqlgo.Object,
+	filter *managedClustersFilter, dbConnectionPool *pgxpool.Pool) ([]map[string]interface{}, error) {
+	caller, found := params.Context.Value(graphQLContextKey{}).(*graphQLCaller)
+	if !found || filter == nil {
+		return nil, errGraphQLCallerNotFound
+	}
+
+	fields := [][]string{}
+	for _, fieldAST := range params.Info.FieldASTs {
+		fields = selectedFields(fieldAST.SelectionSet, managedClusterType, params.Info.Fragments, []string{},
+			fields)
+	}
+
+	selectExpression, args := projectionExpression(fields)
+
+	relation, condition, orderBy, args, err := filter.where(caller, args)
+	if err != nil {
+		return nil, err
+	}
+
+	args = append(args, filter.limit)
+	query := fmt.Sprintf("SELECT %s, leaf_hub_name FROM %s WHERE %s ORDER BY %s LIMIT $%d", selectExpression,
+		relation, condition, orderBy, len(args))
+
+	if filter.offset > 0 {
+		args = append(args, filter.offset)
+		query += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	log := logging.FromContext(params.Context)
+	log.V(1).Info("GraphQL query", "sql", query)
+
+	rows, err := dbConnectionPool.Query(params.Context, query, args...)
+	if err != nil {
+		log.Error(err, "Error in querying managed clusters")
+		return nil, errInternal
+	}
+	defer rows.Close()
+
+	managedClusters := []map[string]interface{}{}
+
+	for rows.Next() {
+		var (
+			managedCluster map[string]interface{}
+			hubCluster     string
+		)
+
+		if err := rows.Scan(&managedCluster, &hubCluster); err != nil {
+			log.Error(err, "Error in scanning a managed cluster")
+			continue
+		}
+
+		managedCluster[hubClusterField] = hubCluster
+		managedClusters = append(managedClusters, managedCluster)
+	}
+
+	return managedClusters, nil
+}
+
+func countGraphQLManagedClusters(ctx context.Context, filter *managedClustersFilter,
+	dbConnectionPool *pgxpool.Pool) (int64, error) {
+	caller, found := ctx.Value(graphQLContextKey{}).(*graphQLCaller)
+	if !found || filter == nil {
+		return 0, errGraphQLCallerNotFound
+	}
+
+	relation, condition, _, args, err := filter.where(caller, []interface{}{})
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+
+	if err := dbConnectionPool.QueryRow(ctx, "SELECT COUNT(*) FROM "+relation+" WHERE "+condition,
+		args...).Scan(&count); err != nil {
+		logging.FromContext(ctx).Error(err, "Error in counting managed clusters")
+		return 0, errInternal
+	}
+
+	return count, nil
+}
+
+// selectedFields appends to fields the JSON paths of the fields selected in the selection set of an object type. The
+// lists, the JSON values and the fields with arguments are selected as a whole, the hubCluster field is not a field of
+// the payload.
+func selectedFields(selectionSet *ast.SelectionSet, objectType *graphqlgo.Object,
+	fragments map[string]ast.Definition, path []string, fields [][]string) [][]string {
+	if selectionSet == nil {
+		return fields
+	}
+
+	for _, aSelection := range selectionSet.Selections {
+		switch typedSelection := aSelection.(type) {
+		case *ast.Field:
+			name := typedSelection.Name.Value
+			if strings.HasPrefix(name, "__") || (len(path) == 0 && name == hubClusterField) {
+				continue
+			}
+
+			field, found := objectType.Fields()[name]
+			if !found {
+				continue
+			}
+
+			fieldPath := make([]string, len(path), len(path)+1)
+			copy(fieldPath, path)
+			fieldPath = append(fieldPath, name)
+
+			fieldObjectType, isObject := field.Type.(*graphqlgo.Object)
+			if !isObject || len(typedSelection.Arguments) > 0 {
+				fields = append(fields, fieldPath)
+				continue
+			}
+
+			fields = selectedFields(typedSelection.SelectionSet, fieldObjectType, fragments, fieldPath, fields)
+		case *ast.InlineFragment:
+			fields = selectedFields(typedSelection.SelectionSet, objectType, fragments, path, fields)
+		case *ast.FragmentSpread:
+			if fragment, isFragment := fragments[typedSelection.Name.Value].(*ast.FragmentDefinition); isFragment {
+				fields = selectedFields(fragment.SelectionSet, objectType, fragments, path, fields)
+			}
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	graphqlgo "github.com/graphql-go/graphql"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	hubClusterField         = "hubCluster"
+	conditionTypeArgument   = "type"
+	conditionStatusArgument = "status"
+)
+
+var (
+	// the types that are marshaled to JSON strings by their MarshalJSON methods.
+	stringMarshaledTypes = map[reflect.Type]bool{
+		reflect.TypeOf(metav1.Time{}):       true,
+		reflect.TypeOf(metav1.MicroTime{}):  true,
+		reflect.TypeOf(resource.Quantity{}): true,
+	}
+	conditionType        = reflect.TypeOf(metav1.Condition{})
+	jsonMarshalerType    = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	errUnsupportedGoType = errors.New("unsupported Go type")
+)
+
+// jsonScalar is the GraphQL scalar of the values that have no GraphQL type, e.g. the labels (maps), returned as is. It
+// is an output type only.
+var jsonScalar = graphqlgo.NewScalar(graphqlgo.ScalarConfig{
+	Name:        "JSON",
+	Description: "A JSON value, e.g. the labels of a managed cluster",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+})
+
+// graphQLTypeBuilder builds the GraphQL types of Go types by reflection, according to their JSON representation.
+type graphQLTypeBuilder struct {
+	objects map[reflect.Type]*graphqlgo.Object
+	fields  map[reflect.Type]graphqlgo.Fields
+}
+
+// managedClusterGraphQLType returns the GraphQL type derived from the ManagedCluster Go type, with the hubCluster
+// field of the leaf hub of the managed cluster.
+func managedClusterGraphQLType() (*graphqlgo.Object, error) {
+	builder := &graphQLTypeBuilder{
+		objects: map[reflect.Type]*graphqlgo.Object{},
+		fields:  map[reflect.Type]graphqlgo.Fields{},
+	}
+	goType := reflect.TypeOf(clusterv1.ManagedCluster{})
+
+	managedClusterType, err := builder.objectType(goType)
+	if err != nil {
+		return nil, err
+	}
+
+	builder.fields[goType][hubClusterField] = &graphqlgo.Field{
+		Type:        graphqlgo.NewNonNull(graphqlgo.String),
+		Description: "the hub cluster of the managed cluster",
+	}
+
+	return managedClusterType, nil
+}
+
+func (builder *graphQLTypeBuilder) outputType(goType reflect.Type) (graphqlgo.Output, error) {
+	for goType.Kind() == reflect.Ptr {
+		goType = goType.Elem()
+	}
+
+	if stringMarshaledTypes[goType] {
+		return graphqlgo.String, nil
+	}
+
+	if goType.Implements(jsonMarshalerType) || reflect.PtrTo(goType).Implements(jsonMarshalerType) {
+		return jsonScalar, nil
+	}
+
+	switch goType.Kind() {
+	case reflect.String:
+		return graphqlgo.String, nil
+	case reflect.Bool:
+		return graphqlgo.Boolean, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return graphqlgo.Int, nil
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return graphqlgo.Float, nil // may exceed the 32 bits of GraphQL Int
+	case reflect.Map, reflect.Interface:
+		return jsonScalar, nil
+	case reflect.Slice, reflect.Array:
+		if goType.Elem().Kind() == reflect.Uint8 {
+			return graphqlgo.String, nil // []byte is marshaled as a base64 string
+		}
+
+		elementType, err := builder.outputType(goType.Elem())
+		if err != nil {
+			return nil, err
+		}
+
+		return graphqlgo.NewList(elementType), nil
+	case reflect.Struct:
+		return builder.objectType(goType)
+	default:
+		return nil, fmt.Errorf("%w: %s", errUnsupportedGoType, goType)
+	}
+}
+
+// objectType returns the GraphQL object of a Go struct. The fields are named by their JSON names, so that the
+// default resolver resolves them from the JSON objects of the database.
+func (builder *graphQLTypeBuilder) objectType(goType reflect.Type) (*graphqlgo.Object, error) {
+	if object, found := builder.objects[goType]; found {
+		return object, nil
+	}
+
+	fields := graphqlgo.Fields{}
+	object := graphqlgo.NewObject(graphqlgo.ObjectConfig{
+		Name: goType.Name(),
+		// the fields are a thunk, so that recursive types can be referenced before they are complete
+		Fields: graphqlgo.FieldsThunk(func() graphqlgo.Fields { return fields }),
+	})
+	builder.objects[goType] = object
+	builder.fields[goType] = fields
+
+	if err := builder.addFields(goType, fields); err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+func (builder *graphQLTypeBuilder) addFields(goType reflect.Type, fields graphqlgo.Fields) error {
+	for index := 0; index < goType.NumField(); index++ {
+		structField := goType.Field(index)
+		if structField.PkgPath != "" { // unexported
+			continue
+		}
+
+		name, inline := jsonFieldName(structField)
+		if name == "-" {
+			continue
+		}
+
+		if inline {
+			if err := builder.addFields(structField.Type, fields); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		fieldType, err := builder.outputType(structField.Type)
+		if err != nil {
+			return fmt.Errorf("failed to build the GraphQL type of %s.%s: %w", goType.Name(), structField.Name, err)
+		}
+
+		field := &graphqlgo.Field{Type: fieldType}
+
+		if structField.Type.Kind() == reflect.Slice && structField.Type.Elem() == conditionType {
+			field.Args = graphqlgo.FieldConfigArgument{
+				conditionTypeArgument:   &graphqlgo.ArgumentConfig{Type: graphqlgo.String},
+				conditionStatusArgument: &graphqlgo.ArgumentConfig{Type: graphqlgo.String},
+			}
+			field.Resolve = resolveConditions
+		}
+
+		fields[name] = field
+	}
+
+	return nil
+}
+
+// jsonFieldName returns the JSON name of a struct field, and whether the field is inlined (embedded without a name).
+func jsonFieldName(structField reflect.StructField) (string, bool) {
+	tag := structField.Tag.Get("json")
+	name := strings.Split(tag, ",")[0]
+
+	if structField.Anonymous && name == "" {
+		return "", true
+	}
+
+	if strings.Contains(tag, ",inline") {
+		return "", true
+	}
+
+	if name == "" {
+		return structField.Name, false
+	}
+
+	return name, false
+}
+
+// resolveConditions resolves the conditions of a managed cluster, filtered by the type and the status arguments.
+func resolveConditions(params graphqlgo.ResolveParams) (interface{}, error) {
+	source, isMap := params.Source.(map[string]interface{})
+	if !isMap {
+		return nil, nil
+	}
+
+	conditions, isList := source[params.Info.FieldName].([]interface{})
+	if !isList {
+		return nil, nil
+	}
+
+	conditionTypeFilter, _ := params.Args[conditionTypeArgument].(string)
+	conditionStatusFilter, _ := params.Args[conditionStatusArgument].(string)
+
+	filtered := make([]interface{}, 0, len(conditions))
+
+	for _, condition := range conditions {
+		conditionMap, isMap := condition.(map[string]interface{})
+		if !isMap {
+			continue
+		}
+
+		if (conditionTypeFilter != "" && conditionMap["type"] != conditionTypeFilter) ||
+			(conditionStatusFilter != "" && conditionMap["status"] != conditionStatusFilter) {
+			continue
+		}
+
+		filtered = append(filtered, condition)
+	}
+
+	return filtered, nil
+}
@@ -0,0 +1,390 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/admission"
+	managedclustersv1 "github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/api/managedclusters/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/audit"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// the select expression of the gRPC queries, the hub cluster is returned with each managed cluster.
+const grpcSelectExpression = payloadExpression + ", leaf_hub_name"
+
+var errUnauthenticated = status.Error(codes.Unauthenticated, "the call is not authenticated")
+
+// watchedManagedCluster is a managed cluster sent by a gRPC watch.
+type watchedManagedCluster struct {
+	hubCluster string
+	name       string
+	data       string // the JSON of the managed cluster, to detect modifications
+}
+
+// GRPCServer serves the managed clusters by gRPC, over the same database and authorization as the REST API.
+type GRPCServer struct {
+	managedclustersv1.UnimplementedManagedClustersServer
+	authorizationURL      string
+	authorizationCABundle *certificates.CABundle
+	dbConnectionPool      *pgxpool.Pool
+	watchInterval         time.Duration
+	patchRetryAttempts    int
+	patchFallbackToRead   bool
+	admissionController   *admission.Controller
+}
+
+// NewGRPCServer returns a new gRPC server of the managed clusters.
+func NewGRPCServer(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool, watchInterval time.Duration, patchRetryAttempts int, patchFallbackToRead bool,
+	admissionController *admission.Controller) *GRPCServer {
+	return &GRPCServer{
+		authorizationURL:      authorizationURL,
+		authorizationCABundle: authorizationCABundle,
+		dbConnectionPool:      dbConnectionPool,
+		watchInterval:         watchInterval,
+		patchRetryAttempts:    patchRetryAttempts,
+		patchFallbackToRead:   patchFallbackToRead,
+		admissionController:   admissionController,
+	}
+}
+
+// List returns the managed clusters visible to the user.
+func (server *GRPCServer) List(ctx context.Context,
+	request *managedclustersv1.ListRequest) (*managedclustersv1.ListResponse, error) {
+	user, groups, authenticated := authentication.UserFromContext(ctx)
+	if !authenticated {
+		return nil, errUnauthenticated
+	}
+
+	log := logging.FromContext(ctx)
+
+	query, args := sqlQuery(ctx, user, groups, server.authorizationURL, server.authorizationCABundle,
+		grpcSelectExpression, []interface{}{}, request.GetSearch())
+	log.V(1).Info("gRPC query", "sql", query)
+
+	managedClusters, err := server.queryManagedClusters(ctx, query, args)
+	if err != nil {
+		log.Error(err, "Error in querying managed clusters")
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	metrics.ObserveListRows(metrics.APIGRPC, len(managedClusters))
+
+	return &managedclustersv1.ListResponse{Items: managedClusters}, nil
+}
+
+// Get returns a managed cluster visible to the user.
+func (server *GRPCServer) Get(ctx context.Context,
+	request *managedclustersv1.GetRequest) (*managedclustersv1.ManagedCluster, error) {
+	user, groups, authenticated := authentication.UserFromContext(ctx)
+	if !authenticated {
+		return nil, errUnauthenticated
+	}
+
+	log := logging.FromContext(ctx)
+
+	hubCluster, err := server.getHubCluster(ctx, user, groups, request.GetName(), request.GetHubCluster())
+	if err != nil {
+		return nil, err
+	}
+
+	query, args := getSQLQuery(ctx, user, groups, server.authorizationURL, server.authorizationCABundle,
+		grpcSelectExpression, []interface{}{}, request.GetName(), hubCluster)
+	log.V(1).Info("gRPC query", "sql", query)
+
+	var object json.RawMessage
+
+	if err := server.dbConnectionPool.QueryRow(ctx, query, args...).Scan(&object, &hubCluster); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, status.Errorf(codes.NotFound, "managed cluster %s not found", request.GetName())
+		}
+
+		log.Error(err, "Error in querying managed cluster")
+
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return newManagedCluster(ctx, object, hubCluster)
+}
+
+// Watch streams the changes of the managed clusters visible to the user. Like the REST watch, the managed clusters
+// are polled from the database.
+func (server *GRPCServer) Watch(request *managedclustersv1.WatchRequest,
+	stream managedclustersv1.ManagedClusters_WatchServer) error {
+	user, groups, authenticated := authentication.UserFromContext(stream.Context())
+	if !authenticated {
+		return errUnauthenticated
+	}
+
+	query, args := sqlQuery(stream.Context(), user, groups, server.authorizationURL, server.authorizationCABundle,
+		grpcSelectExpression, []interface{}{}, request.GetSearch())
+	logging.FromContext(stream.Context()).V(1).Info("gRPC watch query", "sql", query)
+
+	defer metrics.WatchStarted(metrics.APIGRPC)()
+
+	ticker := time.NewTicker(server.watchInterval)
+	defer ticker.Stop()
+
+	// the previously sent managed clusters, by hub cluster and name
+	previousManagedClusters := map[string]*watchedManagedCluster{}
+
+	for {
+		if err := server.sendWatchEvents(stream, query, args, previousManagedClusters); err != nil {
+			return err
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendWatchEvents sends the events of the changes since previousManagedClusters, and updates it.
+func (server *GRPCServer) sendWatchEvents(stream managedclustersv1.ManagedClusters_WatchServer, query string,
+	args []interface{}, previousManagedClusters map[string]*watchedManagedCluster) error {
+	log := logging.FromContext(stream.Context())
+
+	managedClusters, err := server.queryManagedClusters(stream.Context(), query, args)
+	if err != nil {
+		log.Error(err, "Error in querying managed clusters")
+		return nil // try again on the next tick
+	}
+
+	currentManagedClusters := make(map[string]*watchedManagedCluster, len(managedClusters))
+
+	for _, managedCluster := range managedClusters {
+		data, err := protojson.Marshal(managedCluster.GetObject())
+		if err != nil {
+			log.Error(err, "Error in marshalling a managed cluster")
+			continue
+		}
+
+		current := &watchedManagedCluster{
+			hubCluster: managedCluster.GetHubCluster(),
+			name: managedCluster.GetObject().GetFields()["metadata"].GetStructValue().GetFields()["name"].
+				GetStringValue(),
+			data: string(data),
+		}
+		key := current.hubCluster + "/" + current.name
+		currentManagedClusters[key] = current
+
+		previous, found := previousManagedClusters[key]
+
+		eventType := managedclustersv1.WatchEvent_ADDED
+		if found {
+			if previous.data == current.data {
+				continue
+			}
+
+			eventType = managedclustersv1.WatchEvent_MODIFIED
+		}
+
+		event := &managedclustersv1.WatchEvent{Type: eventType, ManagedCluster: managedCluster}
+		if err := stream.Send(event); err != nil {
+			return fmt.Errorf("failed to send a watch event: %w", err)
+		}
+	}
+
+	for key, previous := range previousManagedClusters {
+		if _, found := currentManagedClusters[key]; found {
+			continue
+		}
+
+		if err := stream.Send(deletedWatchEvent(previous)); err != nil {
+			return fmt.Errorf("failed to send a watch event: %w", err)
+		}
+	}
+
+	for key := range previousManagedClusters {
+		delete(previousManagedClusters, key)
+	}
+
+	for key, current := range currentManagedClusters {
+		previousManagedClusters[key] = current
+	}
+
+	return nil
+}
+
+// PatchLabels adds and removes labels of a managed cluster the user is authorized to patch.
+func (server *GRPCServer) PatchLabels(ctx context.Context,
+	request *managedclustersv1.PatchLabelsRequest) (*managedclustersv1.ManagedCluster, error) {
+	user, groups, authenticated := authentication.UserFromContext(ctx)
+	if !authenticated {
+		return nil, errUnauthenticated
+	}
+
+	log := logging.FromContext(ctx)
+
+	cluster := request.GetName()
+
+	hubCluster, err := server.getHubCluster(ctx, user, groups, cluster, request.GetHubCluster())
+	if err != nil {
+		return nil, err
+	}
+
+	audit.AddAnnotation(ctx, audit.HubClusterAnnotation, hubCluster)
+
+	labelsToAdd := request.GetLabelsToAdd()
+	if labelsToAdd == nil {
+		labelsToAdd = map[string]string{}
+	}
+
+	labelsToRemove := getMap(request.GetLabelsToRemove())
+
+	for key := range labelsToAdd {
+		delete(labelsToRemove, key)
+	}
+
+	allowed, err := isPatchAuthorized(ctx, user, groups, server.authorizationURL, server.authorizationCABundle,
+		server.dbConnectionPool, server.patchFallbackToRead, verbPatch, cluster, hubCluster, labelsToAdd, labelsToRemove)
+	if err != nil {
+		log.Error(err, "Error in authorizing the patch of managed cluster")
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	if !allowed {
+		return nil, status.Error(codes.PermissionDenied, "the current user cannot patch the cluster")
+	}
+
+	if err := admitLabels(ctx, server.admissionController, user, groups, cluster, hubCluster, labelsToAdd,
+		labelsToRemove); err != nil {
+		switch {
+		case errors.Is(err, admission.ErrInvalid):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, admission.ErrDenied):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		default:
+			log.Error(err, "Error in the admission of managed cluster labels")
+			return nil, status.Error(codes.Internal, "internal error")
+		}
+	}
+
+	version, err := updateLabelsWithRetries(ctx, user, cluster, hubCluster, "", labelsToAdd, labelsToRemove, nil,
+		server.dbConnectionPool, server.patchRetryAttempts)
+	if err != nil {
+		log.Error(err, "Error in updating managed cluster labels")
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	managedCluster, err := getPatchedCluster(ctx, cluster, hubCluster, version, labelsToAdd, labelsToRemove,
+		server.dbConnectionPool)
+	if err != nil {
+		log.Error(err, "Error in querying the patched managed cluster")
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	object, err := json.Marshal(managedCluster)
+	if err != nil {
+		log.Error(err, "Error in marshalling the patched managed cluster")
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return newManagedCluster(ctx, object, hubCluster)
+}
+
+// getHubCluster returns hubCluster if it is not empty, otherwise the hub cluster of the managed cluster visible to the
+// user. Returns a NotFound error if the user sees no such managed cluster, and an InvalidArgument error if the managed
+// cluster name exists in several hub clusters.
+func (server *GRPCServer) getHubCluster(ctx context.Context, user string, groups []string, cluster string,
+	hubCluster string) (string, error) {
+	if hubCluster != "" {
+		return hubCluster, nil
+	}
+
+	hubClusters, err := findHubClusters(ctx, user, groups, server.authorizationURL, server.authorizationCABundle,
+		server.dbConnectionPool, cluster)
+	if err != nil {
+		logging.FromContext(ctx).Error(err, "Error in querying managed clusters")
+		return "", status.Error(codes.Internal, "internal error")
+	}
+
+	switch len(hubClusters) {
+	case 0:
+		return "", status.Errorf(codes.NotFound, "managed cluster %s not found", cluster)
+	case 1:
+		return hubClusters[0], nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument,
+			"cluster %s exists in multiple hub clusters %v, specify the hub cluster", cluster, hubClusters)
+	}
+}
+
+// queryManagedClusters returns the managed clusters of a query that selects grpcSelectExpression.
+func (server *GRPCServer) queryManagedClusters(ctx context.Context, query string,
+	args []interface{}) ([]*managedclustersv1.ManagedCluster, error) {
+	rows, err := server.dbConnectionPool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query managed clusters: %w", err)
+	}
+	defer rows.Close()
+
+	log := logging.FromContext(ctx)
+	managedClusters := []*managedclustersv1.ManagedCluster{}
+
+	for rows.Next() {
+		var (
+			object     json.RawMessage
+			hubCluster string
+		)
+
+		if err := rows.Scan(&object, &hubCluster); err != nil {
+			log.Error(err, "Error in scanning a managed cluster")
+			continue
+		}
+
+		managedCluster, err := newManagedCluster(ctx, object, hubCluster)
+		if err != nil {
+			log.Error(err, "Error in converting a managed cluster")
+			continue
+		}
+
+		managedClusters = append(managedClusters, managedCluster)
+	}
+
+	return managedClusters, nil
+}
+
+func newManagedCluster(ctx context.Context, object json.RawMessage,
+	hubCluster string) (*managedclustersv1.ManagedCluster, error) {
+	objectStruct := &structpb.Struct{}
+	if err := protojson.Unmarshal(object, objectStruct); err != nil {
+		logging.FromContext(ctx).Error(err, "Error in converting a managed cluster")
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+
+	return &managedclustersv1.ManagedCluster{HubCluster: hubCluster, Object: objectStruct}, nil
+}
+
+// deletedWatchEvent returns the DELETED event of the managed cluster, with its name only.
+func deletedWatchEvent(managedCluster *watchedManagedCluster) *managedclustersv1.WatchEvent {
+	object, _ := structpb.NewStruct(map[string]interface{}{
+		"apiVersion": clusterv1.GroupVersion.String(),
+		"kind":       "ManagedCluster",
+		"metadata":   map[string]interface{}{"name": managedCluster.name},
+	})
+
+	return &managedclustersv1.WatchEvent{
+		Type:           managedclustersv1.WatchEvent_DELETED,
+		ManagedCluster: &managedclustersv1.ManagedCluster{HubCluster: managedCluster.hubCluster, Object: object},
+	}
+}
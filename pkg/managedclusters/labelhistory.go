@@ -0,0 +1,355 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/admission"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/audit"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+)
+
+var (
+	errLabelVersionNotFound = errors.New("the version of the labels is not found")
+	errLabelsNotRestored    = errors.New("the labels of the version could not be restored")
+)
+
+// labelChange is a change of the labels of a managed cluster, as recorded in the history. Version 0 is the labels
+// before the first change, recorded with it (same user and timestamp) with no change.
+type labelChange struct {
+	// Version is the version of the labels after the change.
+	Version int64 `json:"version"`
+	// User is the user that changed the labels.
+	User      string    `json:"user"`
+	Timestamp time.Time `json:"timestamp"`
+	// AddedLabels and RemovedLabelKeys are the change.
+	AddedLabels      map[string]string `json:"addedLabels"`
+	RemovedLabelKeys []string          `json:"removedLabelKeys"`
+	// Labels and DeletedLabelKeys are the labels to add and to remove of the version, that a revert restores.
+	Labels           map[string]string `json:"labels"`
+	DeletedLabelKeys []string          `json:"deletedLabelKeys"`
+}
+
+// labelsSnapshot is the labels and the deleted label keys of a version, that a revert restores.
+type labelsSnapshot struct {
+	labels           map[string]string
+	deletedLabelKeys map[string]struct{}
+}
+
+// equals returns true if the labels and the deleted label keys are the ones of the snapshot.
+func (snapshot *labelsSnapshot) equals(labels map[string]string, deletedLabelKeys []string) bool {
+	if len(labels) != len(snapshot.labels) || len(getMap(deletedLabelKeys)) != len(snapshot.deletedLabelKeys) {
+		return false
+	}
+
+	for key, value := range snapshot.labels {
+		if labelValue, found := labels[key]; !found || labelValue != value {
+			return false
+		}
+	}
+
+	for _, key := range deletedLabelKeys {
+		if _, found := snapshot.deletedLabelKeys[key]; !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// labelHistory is the history of the label changes of a managed cluster, the last change first.
+type labelHistory struct {
+	Cluster    string         `json:"cluster"`
+	HubCluster string         `json:"hubCluster"`
+	Items      []*labelChange `json:"items"`
+}
+
+// LabelHistory middleware.
+func LabelHistory(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		cluster := ginCtx.Param("cluster")
+
+		hubCluster := ginCtx.Query("hubCluster")
+		if hubCluster == "" {
+			var found bool
+
+			if hubCluster, found = getHubCluster(ginCtx, user, groups, authorizationURL, authorizationCABundle,
+				dbConnectionPool, cluster); !found {
+				return
+			}
+		}
+
+		// the history of the clusters the user cannot view is not found
+		if !isAuthorized(ginCtx.Request.Context(), user, groups, authorizationURL, authorizationCABundle,
+			dbConnectionPool, cluster, hubCluster) {
+			notFound(ginCtx, cluster)
+			return
+		}
+
+		history, err := getLabelHistory(ginCtx.Request.Context(), cluster, hubCluster, dbConnectionPool)
+		if err != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			log.Error(err, "Error in querying the label history")
+
+			return
+		}
+
+		ginCtx.JSON(http.StatusOK, history)
+	}
+}
+
+// RevertLabels middleware.
+func RevertLabels(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool, retryAttempts int, patchFallbackToRead bool,
+	admissionController *admission.Controller) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		cluster := ginCtx.Param("cluster")
+
+		version, err := strconv.ParseInt(ginCtx.Param("version"), 10, 64)
+		if err != nil || version < 0 {
+			ginCtx.JSON(http.StatusBadRequest, gin.H{"status": "the version must be a non-negative integer"})
+			return
+		}
+
+		hubCluster := ginCtx.Query("hubCluster")
+		if hubCluster == "" {
+			var found bool
+
+			if hubCluster, found = getHubCluster(ginCtx, user, groups, authorizationURL, authorizationCABundle,
+				dbConnectionPool, cluster); !found {
+				return
+			}
+		}
+
+		audit.AddAnnotation(ginCtx.Request.Context(), audit.HubClusterAnnotation, hubCluster)
+
+		// like its history, the versions of the labels of the clusters the user cannot view are not found
+		if !isAuthorized(ginCtx.Request.Context(), user, groups, authorizationURL, authorizationCABundle,
+			dbConnectionPool, cluster, hubCluster) {
+			notFound(ginCtx, cluster)
+			return
+		}
+
+		snapshot, labelsToAdd, labelsToRemove, err := revertedLabels(ginCtx.Request.Context(), cluster, hubCluster,
+			version, dbConnectionPool)
+		if errors.Is(err, errLabelVersionNotFound) {
+			ginCtx.JSON(http.StatusNotFound, gin.H{"status": fmt.Sprintf(
+				"version %d of the labels of cluster %s is not found", version, cluster)})
+
+			return
+		} else if err != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			log.Error(err, "Error in querying the label history")
+
+			return
+		}
+
+		// like a patch, a revert can be conditioned on the resourceVersion of the If-Match header
+		resourceVersion, err := getResourceVersionPrecondition(ginCtx.GetHeader("If-Match"), "")
+		if err != nil {
+			conflict(ginCtx, cluster, err)
+			return
+		}
+
+		log.V(1).Info("Reverting managed cluster labels", "cluster", cluster, "hubCluster", hubCluster,
+			"version", version, "resourceVersion", resourceVersion, "labelsToAdd", labelsToAdd,
+			"labelsToRemove", labelsToRemove)
+
+		// a revert is authorized and admitted like a patch of the labels it changes
+		if !authorizePatch(ginCtx, user, groups, authorizationURL, authorizationCABundle, dbConnectionPool,
+			patchFallbackToRead, verbRevert, cluster, hubCluster, labelsToAdd, labelsToRemove) {
+			return
+		}
+
+		if err := admitLabels(ginCtx.Request.Context(), admissionController, user, groups, cluster, hubCluster,
+			labelsToAdd, labelsToRemove); err != nil {
+			admissionFailed(ginCtx, cluster, err)
+			return
+		}
+
+		// the revert replaces the labels by the ones of the version, recorded as a new version
+		newVersion, err := updateLabelsWithRetries(ginCtx.Request.Context(), user, cluster, hubCluster,
+			resourceVersion, labelsToAdd, labelsToRemove, snapshot, dbConnectionPool, retryAttempts)
+		if errors.Is(err, errResourceVersionConflict) {
+			conflict(ginCtx, cluster, err)
+			return
+		} else if err != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			log.Error(err, "Error in updating managed cluster labels")
+
+			return
+		}
+
+		returnPatchedCluster(ginCtx, cluster, hubCluster, newVersion, labelsToAdd, labelsToRemove, dbConnectionPool)
+	}
+}
+
+// getLabelHistory returns the label changes of the managed cluster of the hub cluster, the last change first.
+func getLabelHistory(ctx context.Context, cluster, hubCluster string,
+	dbConnectionPool *pgxpool.Pool) (*labelHistory, error) {
+	rows, err := dbConnectionPool.Query(ctx, `SELECT version, username, updated_at, added_labels, removed_label_keys,
+		labels, deleted_label_keys FROM spec.managed_clusters_labels_history WHERE managed_cluster_name = $1 AND
+		leaf_hub_name = $2 ORDER BY version DESC`, cluster, hubCluster)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the label history: %w", err)
+	}
+	defer rows.Close()
+
+	history := &labelHistory{Cluster: cluster, HubCluster: hubCluster, Items: []*labelChange{}}
+
+	for rows.Next() {
+		change := &labelChange{}
+
+		if err := rows.Scan(&change.Version, &change.User, &change.Timestamp, &change.AddedLabels,
+			&change.RemovedLabelKeys, &change.Labels, &change.DeletedLabelKeys); err != nil {
+			return nil, fmt.Errorf("failed to scan a label change: %w", err)
+		}
+
+		history.Items = append(history.Items, change)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the label history: %w", err)
+	}
+
+	return history, nil
+}
+
+// revertedLabels returns the snapshot of the labels of the managed cluster of version, and the change of the labels of
+// the managed cluster that restoring the snapshot makes: the labels added or changed, and the labels removed. The
+// change includes the labels of the managed cluster that the snapshot deletes, or no longer deletes.
+func revertedLabels(ctx context.Context, cluster, hubCluster string, version int64,
+	dbConnectionPool *pgxpool.Pool) (*labelsSnapshot, map[string]string, map[string]struct{}, error) {
+	var (
+		labels           map[string]string
+		deletedLabelKeys []string
+	)
+
+	err := dbConnectionPool.QueryRow(ctx, `SELECT labels, deleted_label_keys FROM spec.managed_clusters_labels_history
+		WHERE managed_cluster_name = $1 AND leaf_hub_name = $2 AND version = $3`, cluster, hubCluster,
+		version).Scan(&labels, &deletedLabelKeys)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil, errLabelVersionNotFound
+	} else if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to query the labels of the version: %w", err)
+	}
+
+	snapshot := &labelsSnapshot{labels: labels, deletedLabelKeys: getMap(deletedLabelKeys)}
+	if snapshot.labels == nil {
+		snapshot.labels = map[string]string{}
+	}
+
+	var (
+		clusterLabels           map[string]string
+		currentLabels           map[string]string
+		currentDeletedLabelKeys []string
+	)
+
+	err = dbConnectionPool.QueryRow(ctx, `SELECT cluster.payload -> 'metadata' -> 'labels', labels.labels,
+		labels.deleted_label_keys FROM status.managed_clusters AS cluster
+		LEFT JOIN spec.managed_clusters_labels AS labels ON labels.leaf_hub_name = cluster.leaf_hub_name AND
+		labels.managed_cluster_name = cluster.payload -> 'metadata' ->> 'name'
+		WHERE cluster.payload -> 'metadata' ->> 'name' = $1 AND cluster.leaf_hub_name = $2`, cluster,
+		hubCluster).Scan(&clusterLabels, &currentLabels, &currentDeletedLabelKeys)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil, fmt.Errorf("failed to query the current labels: %w", err)
+	}
+
+	currentEffectiveLabels := effectiveLabels(clusterLabels, currentLabels, getMap(currentDeletedLabelKeys))
+	revertedEffectiveLabels := effectiveLabels(clusterLabels, snapshot.labels, snapshot.deletedLabelKeys)
+
+	labelsToAdd := map[string]string{}
+
+	for key, value := range revertedEffectiveLabels {
+		if currentValue, found := currentEffectiveLabels[key]; !found || currentValue != value {
+			labelsToAdd[key] = value
+		}
+	}
+
+	labelsToRemove := map[string]struct{}{}
+
+	for key := range currentEffectiveLabels {
+		if _, found := revertedEffectiveLabels[key]; !found {
+			labelsToRemove[key] = struct{}{}
+		}
+	}
+
+	return snapshot, labelsToAdd, labelsToRemove, nil
+}
+
+// effectiveLabels returns the labels of the managed cluster without the deleted label keys, with the labels.
+func effectiveLabels(clusterLabels map[string]string, labels map[string]string,
+	deletedLabelKeys map[string]struct{}) map[string]string {
+	effective := make(map[string]string, len(clusterLabels)+len(labels))
+
+	for key, value := range clusterLabels {
+		if _, deleted := deletedLabelKeys[key]; !deleted {
+			effective[key] = value
+		}
+	}
+
+	for key, value := range labels {
+		effective[key] = value
+	}
+
+	return effective
+}
+
+// insertLabelHistory records the change of the labels by the user, and the labels of the version.
+func insertLabelHistory(ctx context.Context, tx pgx.Tx, user, cluster, hubCluster string, version int64,
+	addedLabels map[string]string, removedLabelKeys map[string]struct{}, labels map[string]string,
+	deletedLabelKeys map[string]struct{}) error {
+	if _, err := tx.Exec(ctx, `INSERT INTO spec.managed_clusters_labels_history (leaf_hub_name, managed_cluster_name,
+		version, username, updated_at, added_labels, removed_label_keys, labels, deleted_label_keys)
+		VALUES ($1, $2, $3, $4, now(), $5::jsonb, $6::jsonb, $7::jsonb, $8::jsonb)`, hubCluster, cluster, version,
+		user, addedLabels, getKeys(removedLabelKeys), labels, getKeys(deletedLabelKeys)); err != nil {
+		return fmt.Errorf("failed to insert into the managed_clusters_labels_history table: %w", err)
+	}
+
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,10 +18,16 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
 	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/negotiation"
 	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/util"
 	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apiextensions-apiserver/pkg/registry/customresource/tableconvertor"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -28,66 +35,171 @@ import (
 )
 
 const (
-	syncIntervalInSeconds                       = 4
-	onlyPatchOfLabelsIsImplemented              = "only patch of labels is currently implemented"
-	onlyAddOrRemoveAreImplemented               = "only add or remove operations are currently implemented"
-	noRowsAffectedByOptimisticConcurrencyUpdate = "no rows were affected by an optimistic-concurrency update query"
-	optimisticConcurrencyRetryAttempts          = 5
-	crdName                                     = "managedclusters.cluster.open-cluster-management.io"
+	onlyPatchOfLabelsIsImplemented = "only patch of labels is currently implemented"
+	onlyAddOrRemoveAreImplemented  = "only add or remove operations are currently implemented"
+	CRDName                        = "managedclusters.cluster.open-cluster-management.io"
+	searchQueryParameter           = "search"
+	asArrayQueryParameter          = "asArray"
+	managedClusterListKind         = "ManagedClusterList"
+)
+
+// the text matched by the search query parameter: the name, the labels, the cluster claims and the URLs of the cluster.
+// The search is applied to status.managed_clusters, where a trigram index (pg_trgm) of this expression is used, see
+// README.md.
+const searchTextExpression = `(COALESCE(payload -> 'metadata' ->> 'name', '') || ' ' ||
+		COALESCE(payload -> 'metadata' ->> 'labels', '') || ' ' ||
+		jsonb_path_query_array(payload, '$.status.clusterClaims[*].value')::text || ' ' ||
+		jsonb_path_query_array(payload, '$.spec.managedClusterClientConfigs[*].url')::text)`
+
+// partialManagedClusterList is a ManagedClusterList of the projected fields of the managed clusters.
+type partialManagedClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []json.RawMessage `json:"items"`
+}
+
+var (
+	// the media types of list responses, in the order of preference. Like for custom resources in Kubernetes,
+	// protobuf is offered only for the PartialObjectMetadataList representation (Table has no protobuf encoding).
+	listOffers = append(
+		negotiation.Offers([]string{negotiation.MediaTypeJSON, negotiation.MediaTypeYAML}, "", negotiation.AsTable),
+		negotiation.Offers([]string{negotiation.MediaTypeJSON, negotiation.MediaTypeYAML, negotiation.MediaTypeProtobuf},
+			negotiation.AsPartialObjectMetadataList)...)
+	// the media types of watch events, in the order of preference.
+	watchOffers = negotiation.Offers([]string{negotiation.MediaTypeJSON}, "", negotiation.AsTable,
+		negotiation.AsPartialObjectMetadata)
 )
 
 // List middleware.
-func List(authorizationURL string, authorizationCABundle []byte,
-	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
-	customResourceColumnDefinitions := util.GetCustomResourceColumnDefinitions(crdName,
+func List(authorizationURL string, authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool,
+	watchInterval time.Duration) gin.HandlerFunc {
+	customResourceColumnDefinitions := util.GetCustomResourceColumnDefinitions(CRDName,
 		clusterv1.GroupVersion.Version)
 
 	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
 		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
 		if !isCorrectType {
-			fmt.Fprintf(gin.DefaultWriter, "unable to get user from context")
+			log.Error(nil, "Unable to get the user from the context")
 
 			user = "Unknown"
 		}
 
 		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
 		if !isCorrectType {
-			fmt.Fprintf(gin.DefaultWriter, "unable to get groups from context")
+			log.Error(nil, "Unable to get the groups from the context")
 
 			groups = []string{}
 		}
 
-		fmt.Fprintf(gin.DefaultWriter, "got authenticated user: %v\n", user)
-		fmt.Fprintf(gin.DefaultWriter, "user groups: %v\n", groups)
+		fields, err := parseFields(ginCtx.QueryArray(fieldsQueryParameter))
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+			return
+		}
 
-		query := sqlQuery(user, groups, authorizationURL, authorizationCABundle)
-		fmt.Fprintf(gin.DefaultWriter, "query: %v\n", query)
+		_, watch := ginCtx.GetQuery("watch")
 
-		if _, watch := ginCtx.GetQuery("watch"); watch {
-			handleRowsForWatch(ginCtx, query, dbConnectionPool)
+		offers := listOffers
+		if watch {
+			offers = watchOffers
+		}
+
+		mediaType, acceptable := negotiation.Negotiate(ginCtx.GetHeader("Accept"), offers)
+		if !acceptable {
+			negotiation.NotAcceptable(ginCtx, offers)
 			return
 		}
 
-		handleRows(ginCtx, query, dbConnectionPool, customResourceColumnDefinitions)
+		asPartialObjectMetadata := mediaType.As() == negotiation.AsPartialObjectMetadataList ||
+			mediaType.As() == negotiation.AsPartialObjectMetadata
+
+		selectExpression, args := payloadExpression, []interface{}{}
+
+		switch {
+		case asPartialObjectMetadata:
+			selectExpression = partialObjectMetadataExpression
+		case len(fields) > 0:
+			selectExpression, args = projectionExpression(fields)
+		}
+
+		query, args := sqlQuery(ginCtx.Request.Context(), user, groups, authorizationURL, authorizationCABundle,
+			selectExpression, args, ginCtx.Query(searchQueryParameter))
+		log.V(1).Info("Query", "sql", query)
+
+		if watch {
+			handleRowsForWatch(ginCtx, mediaType, query, args, dbConnectionPool, customResourceColumnDefinitions,
+				watchInterval)
+			return
+		}
+
+		switch {
+		case asPartialObjectMetadata:
+			handlePartialObjectMetadataRows(ginCtx, mediaType, query, args, dbConnectionPool)
+		case len(fields) > 0:
+			handleProjectedRows(ginCtx, mediaType, query, args, dbConnectionPool, customResourceColumnDefinitions)
+		default:
+			handleRows(ginCtx, mediaType, query, args, dbConnectionPool, customResourceColumnDefinitions)
+		}
+	}
+}
+
+// sqlQuery returns the query that selects selectExpression (with its args) from the managed clusters visible to the
+// user, and the arguments of the query.
+func sqlQuery(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, selectExpression string, args []interface{},
+	search string) (string, []interface{}) {
+	filter := authorization.FilterByAuthorization(ctx, user, groups, authorizationURL, authorizationCABundle,
+		authorization.ClustersQuery, authorization.ClusterUnknown)
+
+	if search == "" {
+		return "SELECT " + selectExpression + " FROM " + managedClustersRelation + " WHERE TRUE AND " + filter +
+			" ORDER BY payload -> 'metadata' ->> 'name'", args
 	}
+
+	condition, orderBy, args := searchCondition(search, args)
+
+	return "SELECT " + selectExpression + " FROM " + managedClustersRelationWhere(condition) + " WHERE TRUE AND " +
+		filter + " ORDER BY " + orderBy, args
 }
 
-func sqlQuery(user string, groups []string, authorizationURL string, authorizationCABundle []byte) string {
-	return "SELECT payload FROM status.managed_clusters WHERE TRUE AND " +
-		filterByAuthorization(user, groups, authorizationURL, authorizationCABundle, gin.DefaultWriter) +
-		" ORDER BY payload -> 'metadata' ->> 'name'"
+// searchCondition returns the condition that matches the managed clusters to the search text, the expression to order
+// them by relevance and the arguments of the query, with the search arguments appended to args.
+func searchCondition(search string, args []interface{}) (string, string, []interface{}) {
+	args = append(args, "%"+escapeLikePattern(search)+"%", search)
+	likeArgument := fmt.Sprintf("$%d", len(args)-1)
+	searchArgument := fmt.Sprintf("$%d", len(args))
+
+	// case-insensitive substring match, or a fuzzy match by pg_trgm word similarity (the <% operator), ranked by
+	// the similarity to the name first
+	condition := "(" + searchTextExpression + " ILIKE " + likeArgument + " OR " + searchArgument + " <% " +
+		searchTextExpression + ")"
+	orderBy := "word_similarity(" + searchArgument + ", payload -> 'metadata' ->> 'name') DESC, " +
+		"word_similarity(" + searchArgument + ", " + searchTextExpression + ") DESC, payload -> 'metadata' ->> 'name'"
+
+	return condition, orderBy, args
 }
 
-func handleRowsForWatch(ginCtx *gin.Context, query string, dbConnectionPool *pgxpool.Pool) {
+// escapeLikePattern escapes the LIKE wildcards (and the default escape character) in s.
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+func handleRowsForWatch(ginCtx *gin.Context, mediaType negotiation.MediaType, query string, args []interface{},
+	dbConnectionPool *pgxpool.Pool, customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition,
+	watchInterval time.Duration) {
 	writer := ginCtx.Writer
 	header := writer.Header()
 	header.Set("Transfer-Encoding", "chunked")
 	header.Set("Content-Type", "application/json")
 	writer.WriteHeader(http.StatusOK)
 
-	ticker := time.NewTicker(syncIntervalInSeconds * time.Second)
+	defer metrics.WatchStarted(metrics.APIREST)()
+
+	ticker := time.NewTicker(watchInterval)
 
-	ctx, cancelContext := context.WithCancel(context.Background())
+	ctx, cancelContext := context.WithCancel(ginCtx.Request.Context())
 	defer cancelContext()
 
 	// TODO - add deleted field to the status.managed_clusters table
@@ -110,30 +222,47 @@ func handleRowsForWatch(ginCtx *gin.Context, query string, dbConnectionPool *pgx
 				return
 			}
 
-			doHandleRowsForWatch(ctx, writer, query, dbConnectionPool, previouslyAddedManagedClusterNames)
+			doHandleRowsForWatch(ctx, writer, mediaType, query, args, dbConnectionPool,
+				previouslyAddedManagedClusterNames, customResourceColumnDefinitions)
 		}
 	}
 }
 
-func doHandleRowsForWatch(ctx context.Context, writer io.Writer, query string, dbConnectionPool *pgxpool.Pool,
-	previouslyAddedManagedClusterNames set.Set) {
-	rows, err := dbConnectionPool.Query(ctx, query)
+func doHandleRowsForWatch(ctx context.Context, writer io.Writer, mediaType negotiation.MediaType, query string,
+	args []interface{}, dbConnectionPool *pgxpool.Pool, previouslyAddedManagedClusterNames set.Set,
+	customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) {
+	log := logging.FromContext(ctx)
+
+	rows, err := dbConnectionPool.Query(ctx, query, args...)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "error in quering managed clusters: %v\n", err)
+		log.Error(err, "Error in querying managed clusters")
+		return
 	}
+	defer rows.Close()
 
 	addedManagedClusterNames := set.NewSet()
 
 	for rows.Next() {
-		managedCluster := &clusterv1.ManagedCluster{}
+		var object json.RawMessage
 
-		err := rows.Scan(managedCluster)
+		err := rows.Scan(&object)
 		if err != nil {
 			continue
 		}
 
-		addedManagedClusterNames.Add(managedCluster.GetName())
-		sendWatchEvent(&metav1.WatchEvent{Type: "ADDED", Object: runtime.RawExtension{Object: managedCluster}}, writer)
+		objectMetadata := &metav1.PartialObjectMetadata{}
+		if err := json.Unmarshal(object, objectMetadata); err != nil {
+			continue
+		}
+
+		eventObject, err := watchEventObject(object, mediaType, customResourceColumnDefinitions)
+		if err != nil {
+			log.Error(err, "Error in creating a watch event")
+			continue
+		}
+
+		addedManagedClusterNames.Add(objectMetadata.GetName())
+		sendWatchEvent(ctx, &metav1.WatchEvent{Type: "ADDED", Object: eventObject}, writer)
 	}
 
 	managedClusterNamesToDelete := previouslyAddedManagedClusterNames.Difference(addedManagedClusterNames)
@@ -147,15 +276,20 @@ func doHandleRowsForWatch(ctx context.Context, writer io.Writer, query string, d
 
 		previouslyAddedManagedClusterNames.Remove(managedClusterNameToDeleteAsString)
 
-		managedClusterToDelete := &clusterv1.ManagedCluster{}
-		managedClusterToDelete.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   clusterv1.GroupVersion.Group,
-			Version: clusterv1.GroupVersion.Version,
-			Kind:    "ManagedCluster",
-		})
-		managedClusterToDelete.SetName(managedClusterNameToDeleteAsString)
-		sendWatchEvent(&metav1.WatchEvent{Type: "DELETED", Object: runtime.RawExtension{Object: managedClusterToDelete}},
-			writer)
+		managedClusterToDelete, err := json.Marshal(deletedObject(managedClusterNameToDeleteAsString,
+			mediaType.As() == negotiation.AsPartialObjectMetadata))
+		if err != nil {
+			log.Error(err, "Error in json marshalling")
+			continue
+		}
+
+		eventObject, err := watchEventObject(managedClusterToDelete, mediaType, customResourceColumnDefinitions)
+		if err != nil {
+			log.Error(err, "Error in creating a watch event")
+			continue
+		}
+
+		sendWatchEvent(ctx, &metav1.WatchEvent{Type: "DELETED", Object: eventObject}, writer)
 	}
 
 	managedClusterNamesToAdd := addedManagedClusterNames.Difference(previouslyAddedManagedClusterNames)
@@ -173,98 +307,292 @@ func doHandleRowsForWatch(ctx context.Context, writer io.Writer, query string, d
 	writer.(http.Flusher).Flush()
 }
 
-func sendWatchEvent(watchEvent *metav1.WatchEvent, writer io.Writer) {
+// watchEventObject returns the object of a watch event, as a Table with a single row if a Table was requested.
+func watchEventObject(object json.RawMessage, mediaType negotiation.MediaType,
+	customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) (runtime.RawExtension, error) {
+	if mediaType.As() != negotiation.AsTable {
+		return runtime.RawExtension{Raw: object}, nil
+	}
+
+	table, err := convertToTable(metav1.ListMeta{}, []json.RawMessage{object}, customResourceColumnDefinitions)
+	if err != nil {
+		return runtime.RawExtension{}, fmt.Errorf("failed to convert to table: %w", err)
+	}
+
+	return runtime.RawExtension{Object: table}, nil
+}
+
+// deletedObject returns the object of the DELETED watch event of the managed cluster.
+func deletedObject(name string, asPartialObjectMetadata bool) runtime.Object {
+	if asPartialObjectMetadata {
+		objectMetadata := &metav1.PartialObjectMetadata{}
+		objectMetadata.SetGroupVersionKind(metav1.SchemeGroupVersion.WithKind("PartialObjectMetadata"))
+		objectMetadata.SetName(name)
+
+		return objectMetadata
+	}
+
+	managedCluster := &clusterv1.ManagedCluster{}
+	managedCluster.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   clusterv1.GroupVersion.Group,
+		Version: clusterv1.GroupVersion.Version,
+		Kind:    "ManagedCluster",
+	})
+	managedCluster.SetName(name)
+
+	return managedCluster
+}
+
+func sendWatchEvent(ctx context.Context, watchEvent *metav1.WatchEvent, writer io.Writer) {
+	log := logging.FromContext(ctx)
+
 	json, err := json.Marshal(watchEvent)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "error in json marshalling: %v\n", err)
+		log.Error(err, "Error in json marshalling")
 		return
 	}
 
 	_, err = writer.Write(json)
 
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "error in writing response: %v\n", err)
+		log.Error(err, "Error in writing response")
 		return
 	}
 
 	_, err = writer.Write([]byte("\n"))
 
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "error in writing response: %v\n", err)
+		log.Error(err, "Error in writing response")
 		return
 	}
 }
 
-func handleRows(ginCtx *gin.Context, query string, dbConnectionPool *pgxpool.Pool,
-	customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) {
-	rows, err := dbConnectionPool.Query(context.TODO(), query)
+func handleRows(ginCtx *gin.Context, mediaType negotiation.MediaType, query string, args []interface{},
+	dbConnectionPool *pgxpool.Pool, customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) {
+	log := logging.FromContext(ginCtx.Request.Context())
+
+	rows, err := dbConnectionPool.Query(ginCtx.Request.Context(), query, args...)
 	if err != nil {
 		ginCtx.String(http.StatusInternalServerError, "internal error")
-		fmt.Fprintf(gin.DefaultWriter, "error in quering managed clusters: %v\n", err)
+		log.Error(err, "Error in querying managed clusters")
+
+		return
 	}
+	defer rows.Close()
 
-	managedClusters := []*clusterv1.ManagedCluster{}
+	managedClusterList := &clusterv1.ManagedClusterList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       managedClusterListKind,
+			APIVersion: clusterv1.GroupVersion.String(),
+		},
+		ListMeta: metav1.ListMeta{},
+		Items:    []clusterv1.ManagedCluster{},
+	}
 
 	for rows.Next() {
-		managedCluster := &clusterv1.ManagedCluster{}
+		managedCluster := clusterv1.ManagedCluster{}
 
-		err := rows.Scan(managedCluster)
+		err := rows.Scan(&managedCluster)
 		if err != nil {
-			fmt.Fprintf(gin.DefaultWriter, "error in scanning a managed cluster: %v\n", err)
+			log.Error(err, "Error in scanning a managed cluster")
 			continue
 		}
 
-		managedClusters = append(managedClusters, managedCluster)
+		managedClusterList.Items = append(managedClusterList.Items, managedCluster)
 	}
 
-	if shouldReturnAsTable(ginCtx) {
-		fmt.Fprintf(gin.DefaultWriter, "Returning as table...\n")
+	metrics.ObserveListRows(metrics.APIREST, len(managedClusterList.Items))
 
-		tableConvertor, err := tableconvertor.New(customResourceColumnDefinitions)
-		if err != nil {
-			fmt.Fprintf(gin.DefaultWriter, "error in creating table convertor: %v\n", err)
-			return
+	if mediaType.As() == negotiation.AsTable {
+		managedClustersData := make([]json.RawMessage, 0, len(managedClusterList.Items))
+
+		for index := range managedClusterList.Items {
+			clusterData, err := json.Marshal(&managedClusterList.Items[index])
+			if err != nil {
+				log.Error(err, "Failed to marshall cluster")
+				continue
+			}
+
+			managedClustersData = append(managedClustersData, clusterData)
 		}
 
-		managedClustersList, err := wrapInList(managedClusters)
-		if err != nil {
-			fmt.Fprintf(gin.DefaultWriter, "error in wrapping managed clusters in a list: %v\n", err)
-			return
+		returnAsTable(ginCtx, mediaType, managedClusterList.ListMeta, managedClustersData,
+			customResourceColumnDefinitions)
+
+		return
+	}
+
+	if shouldReturnAsArray(ginCtx) {
+		negotiation.Write(ginCtx, http.StatusOK, mediaType, managedClusterList.Items)
+		return
+	}
+
+	negotiation.Write(ginCtx, http.StatusOK, mediaType, managedClusterList)
+}
+
+// handleProjectedRows handles the rows of a query with a projection of the fields of the managed clusters.
+func handleProjectedRows(ginCtx *gin.Context, mediaType negotiation.MediaType, query string, args []interface{},
+	dbConnectionPool *pgxpool.Pool, customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) {
+	log := logging.FromContext(ginCtx.Request.Context())
+
+	rows, err := dbConnectionPool.Query(ginCtx.Request.Context(), query, args...)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		log.Error(err, "Error in querying managed clusters")
+
+		return
+	}
+	defer rows.Close()
+
+	managedClusters := []json.RawMessage{}
+
+	for rows.Next() {
+		var managedCluster json.RawMessage
+
+		if err := rows.Scan(&managedCluster); err != nil {
+			log.Error(err, "Error in scanning a managed cluster")
+			continue
 		}
 
-		table, err := tableConvertor.ConvertToTable(context.TODO(), managedClustersList, nil)
-		if err != nil {
-			fmt.Fprintf(gin.DefaultWriter, "error in converting to table: %v\n", err)
-			return
+		managedClusters = append(managedClusters, managedCluster)
+	}
+
+	metrics.ObserveListRows(metrics.APIREST, len(managedClusters))
+
+	managedClusterList := &partialManagedClusterList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       managedClusterListKind,
+			APIVersion: clusterv1.GroupVersion.String(),
+		},
+		ListMeta: metav1.ListMeta{},
+		Items:    managedClusters,
+	}
+
+	if mediaType.As() == negotiation.AsTable {
+		returnAsTable(ginCtx, mediaType, managedClusterList.ListMeta, managedClusters, customResourceColumnDefinitions)
+		return
+	}
+
+	if shouldReturnAsArray(ginCtx) {
+		negotiation.Write(ginCtx, http.StatusOK, mediaType, managedClusters)
+		return
+	}
+
+	negotiation.Write(ginCtx, http.StatusOK, mediaType, managedClusterList)
+}
+
+// handlePartialObjectMetadataRows handles the rows of a query of the metadata of the managed clusters.
+func handlePartialObjectMetadataRows(ginCtx *gin.Context, mediaType negotiation.MediaType, query string,
+	args []interface{}, dbConnectionPool *pgxpool.Pool) {
+	log := logging.FromContext(ginCtx.Request.Context())
+
+	rows, err := dbConnectionPool.Query(ginCtx.Request.Context(), query, args...)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		log.Error(err, "Error in querying managed clusters")
+
+		return
+	}
+	defer rows.Close()
+
+	list := &metav1.PartialObjectMetadataList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PartialObjectMetadataList",
+			APIVersion: metav1.SchemeGroupVersion.String(),
+		},
+		Items: []metav1.PartialObjectMetadata{},
+	}
+
+	for rows.Next() {
+		objectMetadata := metav1.PartialObjectMetadata{}
+
+		if err := rows.Scan(&objectMetadata); err != nil {
+			log.Error(err, "Error in scanning a managed cluster metadata")
+			continue
 		}
 
-		table.Kind = "Table"
-		table.APIVersion = metav1.SchemeGroupVersion.String()
-		ginCtx.JSON(http.StatusOK, table)
+		list.Items = append(list.Items, objectMetadata)
+	}
+
+	metrics.ObserveListRows(metrics.APIREST, len(list.Items))
+
+	negotiation.Write(ginCtx, http.StatusOK, mediaType, list)
+}
+
+func returnAsTable(ginCtx *gin.Context, mediaType negotiation.MediaType, listMeta metav1.ListMeta,
+	managedClusters []json.RawMessage, customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) {
+	table, err := convertToTable(listMeta, managedClusters, customResourceColumnDefinitions)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in converting to table")
 
 		return
 	}
 
-	ginCtx.JSON(http.StatusOK, managedClusters)
+	negotiation.Write(ginCtx, http.StatusOK, mediaType, table)
 }
 
-func wrapInList(managedClusters []*clusterv1.ManagedCluster) (*corev1.List, error) {
+func convertToTable(listMeta metav1.ListMeta, managedClusters []json.RawMessage,
+	customResourceColumnDefinitions []apiextensionsv1.CustomResourceColumnDefinition) (*metav1.Table, error) {
+	tableConvertor, err := tableconvertor.New(customResourceColumnDefinitions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create table convertor: %w", err)
+	}
+
+	managedClustersList, err := wrapInList(listMeta, managedClusters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap managed clusters in a list: %w", err)
+	}
+
+	table, err := tableConvertor.ConvertToTable(context.TODO(), managedClustersList, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert to table: %w", err)
+	}
+
+	// like the default includeObject=Metadata of Kubernetes, the rows contain the metadata of the objects
+	for index := range table.Rows {
+		if err := setRowObjectMetadata(&table.Rows[index]); err != nil {
+			logging.Default().Error(err, "Error in setting the object of a table row")
+		}
+	}
+
+	table.Kind = "Table"
+	table.APIVersion = metav1.SchemeGroupVersion.String()
+
+	return table, nil
+}
+
+func setRowObjectMetadata(row *metav1.TableRow) error {
+	object, err := meta.Accessor(row.Object.Object)
+	if err != nil {
+		return fmt.Errorf("failed to access object metadata: %w", err)
+	}
+
+	objectMetadata := meta.AsPartialObjectMetadata(object)
+	objectMetadata.SetGroupVersionKind(metav1.SchemeGroupVersion.WithKind(negotiation.AsPartialObjectMetadata))
+
+	objectMetadataData, err := json.Marshal(objectMetadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshall object metadata: %w", err)
+	}
+
+	row.Object = runtime.RawExtension{Raw: objectMetadataData}
+
+	return nil
+}
+
+func wrapInList(listMeta metav1.ListMeta, managedClusters []json.RawMessage) (*corev1.List, error) {
 	list := corev1.List{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "List",
 			APIVersion: "v1",
 		},
-		ListMeta: metav1.ListMeta{},
+		ListMeta: listMeta,
 	}
 
-	for _, cluster := range managedClusters {
+	for _, clusterData := range managedClusters {
 		// adopted from
 		// https://github.com/kubernetes/kubectl/blob/4da03973dd2fcd4645f20ac669d8a73cb017ff39/pkg/cmd/get/get.go#L786
-		clusterData, err := json.Marshal(cluster)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshall cluster: %w", err)
-		}
-
 		convertedCluster, err := runtime.Decode(unstructured.UnstructuredJSONScheme, clusterData)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode: %w", err)
@@ -276,17 +604,10 @@ func wrapInList(managedClusters []*clusterv1.ManagedCluster) (*corev1.List, erro
 	return &list, nil
 }
 
-func shouldReturnAsTable(ginCtx *gin.Context) bool {
-	acceptTableHeader := fmt.Sprintf("application/json;as=Table;v=%s;g=%s",
-		metav1.SchemeGroupVersion.Version, metav1.GroupName)
-
-	// implement the real negotiation logic here (with weights)
-	// see https://www.w3.org/Protocols/rfc2616/rfc2616-sec14.html
-	for _, accepted := range strings.Split(ginCtx.GetHeader("Accept"), ",") {
-		if strings.HasPrefix(accepted, acceptTableHeader) {
-			return true
-		}
-	}
+// shouldReturnAsArray returns true if the legacy representation of the list, a JSON array of the managed clusters,
+// was requested by asArray=true.
+func shouldReturnAsArray(ginCtx *gin.Context) bool {
+	asArray, err := strconv.ParseBool(ginCtx.Query(asArrayQueryParameter))
 
-	return false
+	return err == nil && asArray
 }
@@ -5,22 +5,74 @@ package managedclusters
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/admission"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/audit"
 	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var (
-	errOnlyPatchOfLabelsIsImplemented   = errors.New(onlyPatchOfLabelsIsImplemented)
-	errOnlyAddOrRemoveAreImplemented    = errors.New(onlyAddOrRemoveAreImplemented)
-	errOptimisticConcurrencyWriteFailed = errors.New(noRowsAffectedByOptimisticConcurrencyUpdate)
+	errOnlyPatchOfLabelsIsImplemented = errors.New(onlyPatchOfLabelsIsImplemented)
+	errOnlyAddOrRemoveAreImplemented  = errors.New(onlyAddOrRemoveAreImplemented)
+	errResourceVersionConflict        = errors.New(
+		"the labels of the managed cluster were changed since the resourceVersion of the precondition")
+	errLabelsUniqueIndexMissing = errors.New("spec.managed_clusters_labels has no unique index on " +
+		"(leaf_hub_name, managed_cluster_name), see the database requirements in README.md")
 )
 
+const (
+	contentTypeMergePatch          = "application/merge-patch+json"
+	contentTypeStrategicMergePatch = "application/strategic-merge-patch+json"
+
+	resourceVersionPath = "/metadata/resourceVersion"
+
+	// the backoff between the attempts of a label update, doubled on each retry.
+	initialUpdateRetryBackoff = 10 * time.Millisecond
+	maxUpdateRetryBackoff     = time.Second
+
+	// the verbs of the input of the write decision.
+	verbPatch  = "patch"
+	verbRevert = "revert"
+
+	// the PostgreSQL error codes of the transient errors of the label updates.
+	serializationFailureCode = "40001"
+	deadlockDetectedCode     = "40P01"
+
+	// the PostgreSQL error code of an ON CONFLICT without a matching unique index.
+	invalidColumnReferenceCode = "42P10"
+)
+
+// patchAuthorizationInput is the input of the OPA write decision of a change of the labels of a managed cluster, so
+// that the policies can allow a user to change only some label keys of some managed clusters.
+type patchAuthorizationInput struct {
+	User           string            `json:"user"`
+	Groups         []string          `json:"groups"`
+	Verb           string            `json:"verb"`
+	Cluster        json.RawMessage   `json:"cluster"`
+	HubCluster     string            `json:"hubCluster"`
+	LabelsToAdd    map[string]string `json:"labelsToAdd"`
+	LabelsToRemove []string          `json:"labelsToRemove"`
+}
+
 type patch struct {
 	Op    string `json:"op" binding:"required"`
 	Path  string `json:"path" binding:"required"`
@@ -28,164 +80,328 @@ type patch struct {
 }
 
 // Patch middleware.
-func Patch(authorizationURL string, authorizationCABundle []byte,
-	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+func Patch(authorizationURL string, authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool,
+	retryAttempts int, patchFallbackToRead bool, admissionController *admission.Controller) gin.HandlerFunc {
 	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
 		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
 		if !isCorrectType {
-			fmt.Fprintf(gin.DefaultWriter, "unable to get user from context")
+			log.Error(nil, "Unable to get the user from the context")
 
 			user = "Unknown"
 		}
 
 		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
 		if !isCorrectType {
-			fmt.Fprintf(gin.DefaultWriter, "unable to get groups from context")
+			log.Error(nil, "Unable to get the groups from the context")
 
 			groups = []string{}
 		}
 
 		cluster := ginCtx.Param("cluster")
 
-		fmt.Fprintf(gin.DefaultWriter, "patch for cluster: %s\n", cluster)
-
 		hubCluster := ginCtx.Query("hubCluster")
+		if hubCluster == "" {
+			var found bool
 
-		fmt.Fprintf(gin.DefaultWriter, "patch for hub cluster: %s\n", hubCluster)
-
-		if !isAuthorized(user, groups, authorizationURL, authorizationCABundle, dbConnectionPool, cluster, hubCluster) {
-			ginCtx.JSON(http.StatusForbidden, gin.H{"status": "the current user cannot patch the cluster"})
+			if hubCluster, found = getHubCluster(ginCtx, user, groups, authorizationURL, authorizationCABundle,
+				dbConnectionPool, cluster); !found {
+				return
+			}
 		}
 
-		var patches []patch
+		audit.AddAnnotation(ginCtx.Request.Context(), audit.HubClusterAnnotation, hubCluster)
 
-		err := ginCtx.BindJSON(&patches)
+		labelsToAdd, labelsToRemove, bodyResourceVersion, err := getLabelsFromRequest(ginCtx)
 		if err != nil {
-			fmt.Fprintf(gin.DefaultWriter, "failed to bind: %s\n", err.Error())
+			log.Error(err, "Failed to get the labels from the request")
 			return
 		}
 
-		labelsToAdd, labelsToRemove, err := getLabels(ginCtx, patches)
+		resourceVersion, err := getResourceVersionPrecondition(ginCtx.GetHeader("If-Match"), bodyResourceVersion)
 		if err != nil {
-			fmt.Fprintf(gin.DefaultWriter, "failed to get labels: %s\n", err.Error())
+			conflict(ginCtx, cluster, err)
 			return
 		}
 
-		fmt.Fprintf(gin.DefaultWriter, "labels to add: %v\n", labelsToAdd)
-		fmt.Fprintf(gin.DefaultWriter, "labels to remove: %v\n", labelsToRemove)
+		log.V(1).Info("Patching managed cluster labels", "cluster", cluster, "hubCluster", hubCluster,
+			"resourceVersion", resourceVersion, "labelsToAdd", labelsToAdd, "labelsToRemove", labelsToRemove)
 
-		retryAttempts := optimisticConcurrencyRetryAttempts
-
-		for retryAttempts > 0 {
-			err = updateLabels(cluster, hubCluster, labelsToAdd, labelsToRemove, dbConnectionPool)
-			if err == nil {
-				break
-			}
+		if !authorizePatch(ginCtx, user, groups, authorizationURL, authorizationCABundle, dbConnectionPool,
+			patchFallbackToRead, verbPatch, cluster, hubCluster, labelsToAdd, labelsToRemove) {
+			return
+		}
 
-			retryAttempts--
+		if err := admitLabels(ginCtx.Request.Context(), admissionController, user, groups, cluster, hubCluster,
+			labelsToAdd, labelsToRemove); err != nil {
+			admissionFailed(ginCtx, cluster, err)
+			return
 		}
 
-		if err != nil {
+		version, err := updateLabelsWithRetries(ginCtx.Request.Context(), user, cluster, hubCluster, resourceVersion,
+			labelsToAdd, labelsToRemove, nil, dbConnectionPool, retryAttempts)
+		if errors.Is(err, errResourceVersionConflict) {
+			conflict(ginCtx, cluster, err)
+			return
+		} else if err != nil {
 			ginCtx.String(http.StatusInternalServerError, "internal error")
-			fmt.Fprintf(gin.DefaultWriter, "error in updating managed cluster labels: %v\n", err)
+			log.Error(err, "Error in updating managed cluster labels")
+
+			return
 		}
+
+		returnPatchedCluster(ginCtx, cluster, hubCluster, version, labelsToAdd, labelsToRemove, dbConnectionPool)
 	}
 }
 
-func updateLabels(cluster, hubCluster string, labelsToAdd map[string]string, labelsToRemove map[string]struct{},
-	dbConnectionPool *pgxpool.Pool) error {
-	if len(labelsToAdd) == 0 && len(labelsToRemove) == 0 {
-		return nil
+// updateLabelsWithRetries updates the labels on behalf of the user and returns their new version, retrying the update
+// with exponential backoff on transient database errors (e.g. serialization failures and deadlocks).
+func updateLabelsWithRetries(ctx context.Context, user, cluster, hubCluster, resourceVersion string,
+	labelsToAdd map[string]string, labelsToRemove map[string]struct{}, snapshot *labelsSnapshot,
+	dbConnectionPool *pgxpool.Pool, retryAttempts int) (int64, error) {
+	backoff := initialUpdateRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		version, err := updateLabels(ctx, user, cluster, hubCluster, resourceVersion, labelsToAdd, labelsToRemove,
+			snapshot, dbConnectionPool)
+		if err == nil || !isTransient(err) || attempt >= retryAttempts {
+			return version, err
+		}
+
+		metrics.PatchRetried()
+
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("the update of the labels is canceled while retrying: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxUpdateRetryBackoff {
+			backoff = maxUpdateRetryBackoff
+		}
 	}
+}
+
+// isTransient returns true if the error of the transaction of a label update can go away on retry.
+func isTransient(err error) bool {
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		return pgError.Code == serializationFailureCode || pgError.Code == deadlockDetectedCode
+	}
+
+	return pgconn.SafeToRetry(err)
+}
+
+// getHubCluster returns the leaf hub of the cluster visible to the user, writes an error response if it is not found
+// or if the cluster name is ambiguous.
+func getHubCluster(ginCtx *gin.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool, cluster string) (string, bool) {
+	hubClusters, err := findHubClusters(ginCtx.Request.Context(), user, groups, authorizationURL,
+		authorizationCABundle, dbConnectionPool, cluster)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in querying managed clusters")
+
+		return "", false
+	}
+
+	switch len(hubClusters) {
+	case 0:
+		notFound(ginCtx, cluster)
+		return "", false
+	case 1:
+		return hubClusters[0], true
+	default:
+		ginCtx.JSON(http.StatusBadRequest, gin.H{"status": fmt.Sprintf(
+			"cluster %s exists in multiple hub clusters %v, specify the hubCluster query parameter", cluster,
+			hubClusters)})
+
+		return "", false
+	}
+}
 
-	rows, err := dbConnectionPool.Query(context.TODO(),
-		"SELECT labels, deleted_label_keys, version from spec.managed_clusters_labels WHERE managed_cluster_name = $1 AND leaf_hub_name = $2",
-		cluster, hubCluster)
+// findHubClusters returns the leaf hubs that have a managed cluster named cluster that is visible to the user.
+func findHubClusters(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool, cluster string) ([]string, error) {
+	rows, err := dbConnectionPool.Query(ctx,
+		"SELECT leaf_hub_name FROM status.managed_clusters WHERE payload -> 'metadata' ->> 'name' = $1 AND "+
+			authorization.FilterByAuthorization(ctx, user, groups, authorizationURL, authorizationCABundle,
+				authorization.ClustersQuery, authorization.ClusterUnknown), cluster)
 	if err != nil {
-		return fmt.Errorf("failed to read from managed_clusters_labels: %w", err)
+		return nil, fmt.Errorf("failed to query the leaf hubs of the managed cluster: %w", err)
 	}
 	defer rows.Close()
 
-	if !rows.Next() { // insert the labels
-		_, err := dbConnectionPool.Exec(context.TODO(),
-			`INSERT INTO spec.managed_clusters_labels (leaf_hub_name, managed_cluster_name, labels,
-			deleted_label_keys, version, updated_at) values($1, $2, $3::jsonb, $4::jsonb, 0, now())`,
-			hubCluster, cluster, labelsToAdd, getKeys(labelsToRemove))
-		if err != nil {
-			return fmt.Errorf("failed to insert into the managed_clusters_labels table: %w", err)
+	hubClusters := []string{}
+
+	for rows.Next() {
+		var hubCluster string
+
+		if err := rows.Scan(&hubCluster); err != nil {
+			logging.FromContext(ctx).Error(err, "Error in scanning a leaf hub name")
+			continue
 		}
 
-		return nil
+		hubClusters = append(hubClusters, hubCluster)
 	}
 
-	var (
-		currentLabelsToAdd         map[string]string
-		currentLabelsToRemoveSlice []string
-		version                    int64
-	)
+	return hubClusters, nil
+}
 
-	err = rows.Scan(&currentLabelsToAdd, &currentLabelsToRemoveSlice, &version)
+// returnPatchedCluster writes the managed cluster with the patched labels of version.
+func returnPatchedCluster(ginCtx *gin.Context, cluster, hubCluster string, version int64,
+	labelsToAdd map[string]string, labelsToRemove map[string]struct{}, dbConnectionPool *pgxpool.Pool) {
+	managedCluster, err := getPatchedCluster(ginCtx.Request.Context(), cluster, hubCluster, version, labelsToAdd,
+		labelsToRemove, dbConnectionPool)
 	if err != nil {
-		return fmt.Errorf("failed to scan a row: %w", err)
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in querying the patched managed cluster")
+		ginCtx.Status(http.StatusOK)
+
+		return
 	}
 
-	err = updateRow(cluster, hubCluster, labelsToAdd, currentLabelsToAdd, labelsToRemove, getMap(currentLabelsToRemoveSlice),
-		version, dbConnectionPool)
+	setETag(ginCtx, managedCluster.GetResourceVersion())
+	ginCtx.JSON(http.StatusOK, managedCluster)
+}
+
+// getPatchedCluster returns the managed cluster with the patched labels, and their version as its resourceVersion.
+// Note that the labels are applied to the managed cluster on its leaf hub asynchronously.
+func getPatchedCluster(ctx context.Context, cluster, hubCluster string, version int64, labelsToAdd map[string]string,
+	labelsToRemove map[string]struct{}, dbConnectionPool *pgxpool.Pool) (*clusterv1.ManagedCluster, error) {
+	managedCluster := &clusterv1.ManagedCluster{}
+
+	err := dbConnectionPool.QueryRow(ctx,
+		`SELECT payload FROM status.managed_clusters WHERE payload -> 'metadata' ->> 'name' = $1 AND
+		leaf_hub_name = $2`, cluster, hubCluster).Scan(managedCluster)
 	if err != nil {
-		return fmt.Errorf("failed to update managed_clusters_labels table: %w", err)
+		return nil, fmt.Errorf("failed to query the managed cluster: %w", err)
 	}
 
-	// assumimg there is a single row
-	if rows.Next() {
-		fmt.Fprintf(gin.DefaultWriter, "Warning: more than one row for cluster %s\n", cluster)
+	labels := managedCluster.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string, len(labelsToAdd))
 	}
 
-	return nil
-}
+	for key := range labelsToRemove {
+		delete(labels, key)
+	}
+
+	for key, value := range labelsToAdd {
+		labels[key] = value
+	}
 
-func updateRow(cluster, hubCluster string, labelsToAdd map[string]string, currentLabelsToAdd map[string]string,
-	labelsToRemove map[string]struct{}, currentLabelsToRemove map[string]struct{},
-	version int64, dbConnectionPool *pgxpool.Pool) error {
-	newLabelsToAdd := make(map[string]string)
-	newLabelsToRemove := make(map[string]struct{})
+	managedCluster.SetLabels(labels)
+	managedCluster.SetResourceVersion(strconv.FormatInt(version, 10))
 
-	for key := range currentLabelsToRemove {
-		if _, keyToBeAdded := labelsToAdd[key]; !keyToBeAdded {
-			newLabelsToRemove[key] = struct{}{}
-		}
+	return managedCluster, nil
+}
+
+// updateLabels updates the labels on behalf of the user in a transaction, if their version is resourceVersion (if not
+// empty), and returns their new version. The labels are merged into the row of the cluster in the database, so that
+// the concurrent updates wait for each other instead of failing. If snapshot is not nil, the labels and the deleted
+// label keys of the row are replaced by the snapshot instead, and labelsToAdd and labelsToRemove are only recorded as
+// the change in the history. The first update of the cluster also records version 0, the labels before any update.
+func updateLabels(ctx context.Context, user, cluster, hubCluster, resourceVersion string,
+	labelsToAdd map[string]string, labelsToRemove map[string]struct{}, snapshot *labelsSnapshot,
+	dbConnectionPool *pgxpool.Pool) (int64, error) {
+	if snapshot == nil && len(labelsToAdd) == 0 && len(labelsToRemove) == 0 {
+		return getLabelsVersion(ctx, cluster, hubCluster, resourceVersion, dbConnectionPool)
 	}
 
-	for key := range labelsToRemove {
-		newLabelsToRemove[key] = struct{}{}
+	// the merged (or replacing) labels, the removed (or replacing deleted) label keys and the added label keys
+	labels, labelKeys, addedLabelKeys := labelsToAdd, getKeys(labelsToRemove), getLabelKeys(labelsToAdd)
+	if snapshot != nil {
+		labels, labelKeys, addedLabelKeys = snapshot.labels, getKeys(snapshot.deletedLabelKeys), []string{}
 	}
 
-	for key, value := range currentLabelsToAdd {
-		if _, keyToBeRemoved := labelsToRemove[key]; !keyToBeRemoved {
-			newLabelsToAdd[key] = value
+	var version int64
+
+	// the labels and their history are updated together
+	err := dbConnectionPool.BeginFunc(ctx, func(tx pgx.Tx) error {
+		var (
+			updatedLabels    map[string]string
+			deletedLabelKeys []string
+			inserted         bool
+		)
+
+		// the labels to remove are removed from the labels and added to the deleted keys, the labels to add are
+		// added to the labels and removed from the deleted keys, or both are replaced by the snapshot. The row is
+		// inserted with version 1, so that the resourceVersion of its cluster (0 without labels in the spec, see
+		// managedClustersRelation) changes.
+		err := tx.QueryRow(ctx, `INSERT INTO spec.managed_clusters_labels AS existing (leaf_hub_name,
+			managed_cluster_name, labels, deleted_label_keys, version, updated_at)
+			VALUES ($1, $2, $3::jsonb, to_jsonb($4::text[]), 1, now())
+			ON CONFLICT (leaf_hub_name, managed_cluster_name) DO UPDATE SET
+			labels = CASE WHEN $7 THEN excluded.labels
+				ELSE (COALESCE(existing.labels, '{}'::jsonb) - $4::text[]) || $3::jsonb END,
+			deleted_label_keys = CASE WHEN $7 THEN excluded.deleted_label_keys
+				ELSE (SELECT COALESCE(jsonb_agg(DISTINCT key), '[]'::jsonb) FROM
+				jsonb_array_elements_text(COALESCE(existing.deleted_label_keys, '[]'::jsonb) || to_jsonb($4::text[]))
+				AS key WHERE key <> ALL($5::text[])) END,
+			version = existing.version + 1,
+			updated_at = now()
+			WHERE $6 = '' OR existing.version::text = $6
+			RETURNING labels, deleted_label_keys, version, xmax = 0`, hubCluster, cluster, labels, labelKeys,
+			addedLabelKeys, resourceVersion, snapshot != nil).Scan(&updatedLabels, &deletedLabelKeys, &version,
+			&inserted)
+		var pgError *pgconn.PgError
+
+		if errors.Is(err, pgx.ErrNoRows) { // the row of another version is not updated
+			return errResourceVersionConflict
+		} else if errors.As(err, &pgError) && pgError.Code == invalidColumnReferenceCode {
+			return fmt.Errorf("%w: %s", errLabelsUniqueIndexMissing, pgError.Message)
+		} else if err != nil {
+			return fmt.Errorf("failed to upsert into the managed_clusters_labels table: %w", err)
 		}
-	}
 
-	for key, value := range labelsToAdd {
-		newLabelsToAdd[key] = value
+		// the inserted row had no version, i.e. version 0
+		if inserted && resourceVersion != "" && resourceVersion != "0" {
+			return errResourceVersionConflict
+		}
+
+		if snapshot != nil && !snapshot.equals(updatedLabels, deletedLabelKeys) {
+			return errLabelsNotRestored
+		}
+
+		// version 0 has no labels and no deleted label keys in the spec, so that a revert to it restores the labels
+		// of the managed cluster
+		if inserted {
+			if err := insertLabelHistory(ctx, tx, user, cluster, hubCluster, 0, map[string]string{},
+				map[string]struct{}{}, map[string]string{}, map[string]struct{}{}); err != nil {
+				return err
+			}
+		}
+
+		return insertLabelHistory(ctx, tx, user, cluster, hubCluster, version, labelsToAdd, labelsToRemove,
+			updatedLabels, getMap(deletedLabelKeys))
+	})
+	if errors.Is(err, errResourceVersionConflict) {
+		return 0, errResourceVersionConflict
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to update the labels in a transaction: %w", err)
 	}
 
-	commandTag, err := dbConnectionPool.Exec(context.TODO(),
-		`UPDATE spec.managed_clusters_labels SET
-		labels = $1::jsonb,
-		deleted_label_keys = $2::jsonb,
-		version = version + 1,
-		updated_at = now()
-		WHERE managed_cluster_name=$3 AND leaf_hub_name=$4 AND version=$5`,
-		newLabelsToAdd, getKeys(newLabelsToRemove), cluster, hubCluster, version)
+	return version, nil
+}
+
+// getLabelsVersion returns the version of the labels of the cluster, errResourceVersionConflict if it is not
+// resourceVersion (if not empty).
+func getLabelsVersion(ctx context.Context, cluster, hubCluster, resourceVersion string,
+	dbConnectionPool *pgxpool.Pool) (int64, error) {
+	var version int64
+
+	err := dbConnectionPool.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM spec.managed_clusters_labels
+		WHERE managed_cluster_name = $1 AND leaf_hub_name = $2`, cluster, hubCluster).Scan(&version)
 	if err != nil {
-		return fmt.Errorf("failed to update a row: %w", err)
+		return 0, fmt.Errorf("failed to read from managed_clusters_labels: %w", err)
 	}
 
-	if commandTag.RowsAffected() == 0 {
-		return fmt.Errorf("failed to update a row: %w", errOptimisticConcurrencyWriteFailed)
+	if resourceVersion != "" && resourceVersion != strconv.FormatInt(version, 10) {
+		return 0, errResourceVersionConflict
 	}
 
-	return nil
+	return version, nil
 }
 
 func getMap(aSlice []string) map[string]struct{} {
@@ -198,6 +414,17 @@ func getMap(aSlice []string) map[string]struct{} {
 	return mapToReturn
 }
 
+// getLabelKeys returns the keys of the labels.
+func getLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+
+	for key := range labels {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
 // from https://stackoverflow.com/q/21362950
 func getKeys(aMap map[string]struct{}) []string {
 	keys := make([]string, len(aMap))
@@ -211,26 +438,182 @@ func getKeys(aMap map[string]struct{}) []string {
 	return keys
 }
 
-func isAuthorized(user string, groups []string, authorizationURL string, authorizationCABundle []byte,
-	dbConnectionPool *pgxpool.Pool, cluster string, hubCluster string) bool {
-	query := fmt.Sprintf(
-		"SELECT COUNT(payload) from status.managed_clusters WHERE payload -> 'metadata' ->> 'name' = '%s' AND 'leaf_hub_name' = '%s' AND %s",
-		cluster, hubCluster, filterByAuthorization(user, groups, authorizationURL, authorizationCABundle, gin.DefaultWriter))
+// isAuthorized returns true if the managed cluster of the hub cluster is visible to the user.
+func isAuthorized(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool, cluster string, hubCluster string) bool {
+	query := "SELECT COUNT(payload) from status.managed_clusters WHERE payload -> 'metadata' ->> 'name' = $1 AND " +
+		"leaf_hub_name = $2 AND " + authorization.FilterByAuthorization(ctx, user, groups, authorizationURL,
+		authorizationCABundle, authorization.ClustersQuery, authorization.ClusterUnknown)
 
 	var count int64
 
-	err := dbConnectionPool.QueryRow(context.TODO(), query).Scan(&count)
+	err := dbConnectionPool.QueryRow(ctx, query, cluster, hubCluster).Scan(&count)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "error in quering managed clusters: %v\n", err)
+		logging.FromContext(ctx).Error(err, "Error in querying managed clusters")
 		return false
 	}
 
 	return count > 0
 }
 
-func getLabels(ginCtx *gin.Context, patches []patch) (map[string]string, map[string]struct{}, error) {
+// isPatchAuthorized returns true if the user may change the labels of the managed cluster of the hub cluster: the
+// cluster must be visible to the user, and the write decision of the change must allow it. An undefined decision
+// allows the change if patchFallbackToRead is true, denies it otherwise.
+func isPatchAuthorized(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool, patchFallbackToRead bool,
+	verb, cluster, hubCluster string, labelsToAdd map[string]string, labelsToRemove map[string]struct{}) (bool, error) {
+	query := "SELECT payload from status.managed_clusters WHERE payload -> 'metadata' ->> 'name' = $1 AND " +
+		"leaf_hub_name = $2 AND " + authorization.FilterByAuthorization(ctx, user, groups, authorizationURL,
+		authorizationCABundle, authorization.ClustersQuery, authorization.ClusterUnknown)
+
+	var payload json.RawMessage
+
+	err := dbConnectionPool.QueryRow(ctx, query, cluster, hubCluster).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to query the managed cluster: %w", err)
+	}
+
+	labelKeysToRemove := getKeys(labelsToRemove)
+	sort.Strings(labelKeysToRemove)
+
+	var allowed *bool // nil if the document is undefined
+
+	if err := authorization.Decide(ctx, authorizationURL, authorizationCABundle, authorization.ClustersPatchDecision,
+		&patchAuthorizationInput{
+			User:           user,
+			Groups:         groups,
+			Verb:           verb,
+			Cluster:        payload,
+			HubCluster:     hubCluster,
+			LabelsToAdd:    labelsToAdd,
+			LabelsToRemove: labelKeysToRemove,
+		}, &allowed); err != nil {
+		return false, fmt.Errorf("failed to decide the change of the labels: %w", err)
+	}
+
+	if allowed == nil {
+		// the policies without the patch rule deny the changes, unless the fallback to the visibility is enabled
+		return patchFallbackToRead, nil
+	}
+
+	return *allowed, nil
+}
+
+// authorizePatch returns true if the user may change the labels of the managed cluster, writes an error response
+// otherwise.
+func authorizePatch(ginCtx *gin.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, dbConnectionPool *pgxpool.Pool, patchFallbackToRead bool,
+	verb, cluster, hubCluster string, labelsToAdd map[string]string, labelsToRemove map[string]struct{}) bool {
+	allowed, err := isPatchAuthorized(ginCtx.Request.Context(), user, groups, authorizationURL, authorizationCABundle,
+		dbConnectionPool, patchFallbackToRead, verb, cluster, hubCluster, labelsToAdd, labelsToRemove)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in authorizing the patch of managed cluster")
+
+		return false
+	}
+
+	if !allowed {
+		ginCtx.JSON(http.StatusForbidden, gin.H{"status": "the current user cannot patch the cluster"})
+		return false
+	}
+
+	return true
+}
+
+// getLabelsFromRequest returns the labels to add and to remove of a JSON patch (RFC 6902), or of a JSON merge patch
+// (RFC 7386) which kubectl uses for custom resources, and the resourceVersion the patch is conditioned on (empty if
+// none). Writes an error response if the patch is invalid or unsupported.
+func getLabelsFromRequest(ginCtx *gin.Context) (map[string]string, map[string]struct{}, string, error) {
+	switch ginCtx.ContentType() {
+	case contentTypeMergePatch, contentTypeStrategicMergePatch:
+		return getLabelsFromMergePatch(ginCtx)
+	default:
+		var patches []patch
+
+		if err := ginCtx.BindJSON(&patches); err != nil {
+			return nil, nil, "", fmt.Errorf("failed to bind: %w", err)
+		}
+
+		return getLabels(ginCtx, patches)
+	}
+}
+
+// getLabelsFromMergePatch returns the labels to add and to remove of a merge patch, e.g.
+// {"metadata":{"labels":{"a":"b","c":null}}}, and its metadata.resourceVersion. For labels, a strategic merge patch
+// is the same as a merge patch.
+func getLabelsFromMergePatch(ginCtx *gin.Context) (map[string]string, map[string]struct{}, string, error) {
+	var mergePatch map[string]json.RawMessage
+
+	if err := ginCtx.BindJSON(&mergePatch); err != nil {
+		return nil, nil, "", fmt.Errorf("failed to bind: %w", err)
+	}
+
+	var metadata map[string]json.RawMessage
+
+	for key, value := range mergePatch {
+		if key != "metadata" {
+			ginCtx.JSON(http.StatusNotImplemented, gin.H{"status": onlyPatchOfLabelsIsImplemented})
+
+			return nil, nil, "", errOnlyPatchOfLabelsIsImplemented
+		}
+
+		if err := json.Unmarshal(value, &metadata); err != nil {
+			ginCtx.JSON(http.StatusBadRequest, gin.H{"status": "invalid metadata in merge patch"})
+
+			return nil, nil, "", fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	var (
+		labels          map[string]*string
+		resourceVersion string
+	)
+
+	for key, value := range metadata {
+		switch key {
+		case "labels":
+			if err := json.Unmarshal(value, &labels); err != nil {
+				ginCtx.JSON(http.StatusBadRequest, gin.H{"status": "invalid labels in merge patch"})
+
+				return nil, nil, "", fmt.Errorf("failed to unmarshal labels: %w", err)
+			}
+		case "resourceVersion":
+			if err := json.Unmarshal(value, &resourceVersion); err != nil {
+				ginCtx.JSON(http.StatusBadRequest, gin.H{"status": "invalid resourceVersion in merge patch"})
+
+				return nil, nil, "", fmt.Errorf("failed to unmarshal resourceVersion: %w", err)
+			}
+		default:
+			ginCtx.JSON(http.StatusNotImplemented, gin.H{"status": onlyPatchOfLabelsIsImplemented})
+
+			return nil, nil, "", errOnlyPatchOfLabelsIsImplemented
+		}
+	}
+
+	labelsToAdd := make(map[string]string)
+	labelsToRemove := make(map[string]struct{})
+
+	for key, value := range labels {
+		if value == nil {
+			labelsToRemove[key] = struct{}{}
+			continue
+		}
+
+		labelsToAdd[key] = *value
+	}
+
+	return labelsToAdd, labelsToRemove, resourceVersion, nil
+}
+
+// getLabels returns the labels to add and to remove of a JSON patch, and the resourceVersion of its test operation of
+// /metadata/resourceVersion, if any.
+func getLabels(ginCtx *gin.Context, patches []patch) (map[string]string, map[string]struct{}, string, error) {
 	labelsToAdd := make(map[string]string)
 	labelsToRemove := make(map[string]struct{})
+	resourceVersion := ""
 
 	// from https://datatracker.ietf.org/doc/html/rfc6902:
 	// Evaluation of a JSON Patch document begins against a target JSON
@@ -241,12 +624,17 @@ func getLabels(ginCtx *gin.Context, patches []patch) (map[string]string, map[str
 	// successfully applied or until an error condition is encountered.
 
 	for _, aPatch := range patches {
+		if aPatch.Op == "test" && aPatch.Path == resourceVersionPath {
+			resourceVersion = aPatch.Value
+			continue
+		}
+
 		rawLabel := strings.TrimPrefix(aPatch.Path, "/metadata/labels/")
 
 		if rawLabel == aPatch.Path {
 			ginCtx.JSON(http.StatusNotImplemented, gin.H{"status": onlyPatchOfLabelsIsImplemented})
 
-			return nil, nil, errOnlyPatchOfLabelsIsImplemented
+			return nil, nil, "", errOnlyPatchOfLabelsIsImplemented
 		}
 
 		label := strings.Replace(rawLabel, "~1", "/", 1)
@@ -268,8 +656,88 @@ func getLabels(ginCtx *gin.Context, patches []patch) (map[string]string, map[str
 
 		ginCtx.JSON(http.StatusNotImplemented, gin.H{"status": onlyAddOrRemoveAreImplemented})
 
-		return nil, nil, errOnlyAddOrRemoveAreImplemented
+		return nil, nil, "", errOnlyAddOrRemoveAreImplemented
+	}
+
+	return labelsToAdd, labelsToRemove, resourceVersion, nil
+}
+
+// getResourceVersionPrecondition returns the resourceVersion of the If-Match header (an ETag, * matches any version)
+// or else of the body of a patch, empty if the patch is unconditional. Returns errResourceVersionConflict if they
+// differ.
+func getResourceVersionPrecondition(ifMatch string, bodyResourceVersion string) (string, error) {
+	resourceVersion := strings.TrimPrefix(strings.TrimSpace(ifMatch), "W/")
+	if unquoted, err := strconv.Unquote(resourceVersion); err == nil {
+		resourceVersion = unquoted
+	}
+
+	if resourceVersion == "*" {
+		resourceVersion = ""
+	}
+
+	switch {
+	case resourceVersion == "":
+		return bodyResourceVersion, nil
+	case bodyResourceVersion == "" || bodyResourceVersion == resourceVersion:
+		return resourceVersion, nil
+	default:
+		return "", fmt.Errorf("%w: the If-Match header and metadata.resourceVersion differ",
+			errResourceVersionConflict)
 	}
+}
+
+// admitLabels validates the change of the labels of the cluster by the user with the admission controller.
+func admitLabels(ctx context.Context, admissionController *admission.Controller, user string, groups []string,
+	cluster, hubCluster string, labelsToAdd map[string]string, labelsToRemove map[string]struct{}) error {
+	labelKeysToRemove := getKeys(labelsToRemove)
+	sort.Strings(labelKeysToRemove)
+
+	if err := admissionController.Validate(ctx, &admission.Request{
+		User:           user,
+		Groups:         groups,
+		Cluster:        cluster,
+		HubCluster:     hubCluster,
+		LabelsToAdd:    labelsToAdd,
+		LabelsToRemove: labelKeysToRemove,
+	}); err != nil {
+		return fmt.Errorf("the change of the labels is not admitted: %w", err)
+	}
+
+	return nil
+}
+
+// admissionFailed writes the Kubernetes Status of a change of the labels rejected by the admission (Invalid or
+// Forbidden), or an internal error if the change could not be validated.
+func admissionFailed(ginCtx *gin.Context, cluster string, err error) {
+	groupResource := clusterv1.GroupVersion.WithResource(managedClustersResource).GroupResource()
+
+	var status metav1.Status
+
+	switch {
+	case errors.Is(err, admission.ErrInvalid):
+		status = apierrors.NewGenericServerResponse(http.StatusUnprocessableEntity, "patch", groupResource, cluster,
+			err.Error(), 0, false).Status()
+	case errors.Is(err, admission.ErrDenied):
+		status = apierrors.NewForbidden(groupResource, cluster, err).Status()
+	default:
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in the admission of managed cluster labels")
+
+		return
+	}
+
+	status.Kind = "Status"
+	status.APIVersion = metav1.SchemeGroupVersion.Version
+
+	ginCtx.JSON(int(status.Code), status)
+}
+
+// conflict writes a Kubernetes Conflict Status for the managed cluster.
+func conflict(ginCtx *gin.Context, cluster string, err error) {
+	status := apierrors.NewConflict(clusterv1.GroupVersion.WithResource(managedClustersResource).GroupResource(),
+		cluster, err).Status()
+	status.Kind = "Status"
+	status.APIVersion = metav1.SchemeGroupVersion.Version
 
-	return labelsToAdd, labelsToRemove, nil
+	ginCtx.JSON(http.StatusConflict, status)
 }
@@ -0,0 +1,273 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// the tests of the label updates run against the PostgreSQL database of TEST_DATABASE_URL, and are skipped if it is not
+// set. The database should be a scratch database: the tests create the tables of the labels if they do not exist, and
+// drop and recreate the unique index of spec.managed_clusters_labels.
+const testDatabaseURLEnvironmentVariable = "TEST_DATABASE_URL"
+
+const (
+	testHubCluster         = "test-hub"
+	concurrentPatches      = 20
+	testPatchRetryAttempts = 10
+	testTimeout            = time.Minute
+)
+
+// the tables and the unique index of the label updates, see the database requirements in README.md.
+var testDatabaseSchema = []string{
+	`CREATE SCHEMA IF NOT EXISTS spec`,
+	`CREATE TABLE IF NOT EXISTS spec.managed_clusters_labels (
+		leaf_hub_name text NOT NULL,
+		managed_cluster_name text NOT NULL,
+		labels jsonb DEFAULT '{}'::jsonb NOT NULL,
+		deleted_label_keys jsonb DEFAULT '[]'::jsonb NOT NULL,
+		updated_at timestamp with time zone DEFAULT now() NOT NULL,
+		version bigint DEFAULT 0 NOT NULL)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS managed_clusters_labels_cluster_idx ON spec.managed_clusters_labels
+		(leaf_hub_name, managed_cluster_name)`,
+	`CREATE TABLE IF NOT EXISTS spec.managed_clusters_labels_history (
+		leaf_hub_name text NOT NULL,
+		managed_cluster_name text NOT NULL,
+		version bigint NOT NULL,
+		username text NOT NULL,
+		updated_at timestamp with time zone NOT NULL,
+		added_labels jsonb NOT NULL,
+		removed_label_keys jsonb NOT NULL,
+		labels jsonb NOT NULL,
+		deleted_label_keys jsonb NOT NULL,
+		PRIMARY KEY (leaf_hub_name, managed_cluster_name, version))`,
+}
+
+func TestUpdateLabelsConcurrently(t *testing.T) {
+	ctx, dbConnectionPool, cluster := setUpLabelsTest(t)
+
+	versions := make([]int64, concurrentPatches)
+	errs := make([]error, concurrentPatches)
+
+	var waitGroup sync.WaitGroup
+
+	for index := 0; index < concurrentPatches; index++ {
+		waitGroup.Add(1)
+
+		go func(index int) {
+			defer waitGroup.Done()
+
+			versions[index], errs[index] = updateLabelsWithRetries(ctx, "tester", cluster, testHubCluster, "",
+				map[string]string{fmt.Sprintf("key%d", index): "value"}, map[string]struct{}{}, nil,
+				dbConnectionPool, testPatchRetryAttempts)
+		}(index)
+	}
+
+	waitGroup.Wait()
+
+	for index, err := range errs {
+		if err != nil {
+			t.Fatalf("patch %d failed: %v", index, err)
+		}
+	}
+
+	// each patch makes exactly one new version
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	for index, version := range versions {
+		if version != int64(index+1) {
+			t.Fatalf("the versions of the patches are %v, expected 1 to %d", versions, concurrentPatches)
+		}
+	}
+
+	// no update is lost
+	var labels map[string]string
+
+	if err := dbConnectionPool.QueryRow(ctx, `SELECT labels FROM spec.managed_clusters_labels
+		WHERE managed_cluster_name = $1 AND leaf_hub_name = $2`, cluster, testHubCluster).Scan(&labels); err != nil {
+		t.Fatalf("failed to query the labels: %v", err)
+	}
+
+	for index := 0; index < concurrentPatches; index++ {
+		if _, found := labels[fmt.Sprintf("key%d", index)]; !found {
+			t.Errorf("the label of patch %d is lost, the labels are %v", index, labels)
+		}
+	}
+
+	// each version of the history adds one label to the labels of the previous version, from version 0 without labels
+	history, err := getLabelHistory(ctx, cluster, testHubCluster, dbConnectionPool)
+	if err != nil {
+		t.Fatalf("failed to query the label history: %v", err)
+	}
+
+	if len(history.Items) != concurrentPatches+1 {
+		t.Fatalf("the history has %d versions, expected %d", len(history.Items), concurrentPatches+1)
+	}
+
+	for _, change := range history.Items {
+		if int64(len(change.Labels)) != change.Version {
+			t.Errorf("version %d has the labels %v, expected %d labels", change.Version, change.Labels,
+				change.Version)
+		}
+	}
+}
+
+func TestUpdateLabelsWithStaleResourceVersion(t *testing.T) {
+	ctx, dbConnectionPool, cluster := setUpLabelsTest(t)
+
+	for _, value := range []string{"a", "b"} {
+		if _, err := updateLabels(ctx, "tester", cluster, testHubCluster, "", map[string]string{"key": value},
+			map[string]struct{}{}, nil, dbConnectionPool); err != nil {
+			t.Fatalf("failed to patch the labels: %v", err)
+		}
+	}
+
+	// version 1 is stale, the labels are at version 2
+	_, err := updateLabels(ctx, "tester", cluster, testHubCluster, "1", map[string]string{"key": "c"},
+		map[string]struct{}{}, nil, dbConnectionPool)
+	if !errors.Is(err, errResourceVersionConflict) {
+		t.Fatalf("the patch of a stale resourceVersion returned %v, expected a conflict", err)
+	}
+
+	version, err := updateLabels(ctx, "tester", cluster, testHubCluster, "2", map[string]string{"key": "d"},
+		map[string]struct{}{}, nil, dbConnectionPool)
+	if err != nil || version != 3 {
+		t.Fatalf("the patch of the current resourceVersion returned version %d and %v, expected version 3", version,
+			err)
+	}
+
+	var value string
+
+	if err := dbConnectionPool.QueryRow(ctx, `SELECT labels ->> 'key' FROM spec.managed_clusters_labels
+		WHERE managed_cluster_name = $1 AND leaf_hub_name = $2`, cluster, testHubCluster).Scan(&value); err != nil {
+		t.Fatalf("failed to query the labels: %v", err)
+	}
+
+	if value != "d" {
+		t.Fatalf("the label is %q, expected the value of the patch of the current resourceVersion", value)
+	}
+}
+
+func TestRevertLabelsToVersionZero(t *testing.T) {
+	ctx, dbConnectionPool, cluster := setUpLabelsTest(t)
+
+	for _, statement := range testManagedClustersSchema {
+		if _, err := dbConnectionPool.Exec(ctx, statement); err != nil {
+			t.Fatalf("failed to create the managed clusters table: %v", err)
+		}
+	}
+
+	if _, err := updateLabels(ctx, "tester", cluster, testHubCluster, "", map[string]string{"key": "value"},
+		map[string]struct{}{}, nil, dbConnectionPool); err != nil {
+		t.Fatalf("failed to patch the labels: %v", err)
+	}
+
+	snapshot, labelsToAdd, labelsToRemove, err := revertedLabels(ctx, cluster, testHubCluster, 0, dbConnectionPool)
+	if err != nil {
+		t.Fatalf("failed to get the labels of version 0: %v", err)
+	}
+
+	if _, found := labelsToRemove["key"]; len(labelsToAdd) != 0 || len(labelsToRemove) != 1 || !found {
+		t.Fatalf("the revert to version 0 adds %v and removes %v, expected to remove the patched label", labelsToAdd,
+			labelsToRemove)
+	}
+
+	version, err := updateLabels(ctx, "tester", cluster, testHubCluster, "1", labelsToAdd, labelsToRemove, snapshot,
+		dbConnectionPool)
+	if err != nil || version != 2 {
+		t.Fatalf("the revert to version 0 returned version %d and %v, expected version 2", version, err)
+	}
+
+	var labels map[string]string
+
+	if err := dbConnectionPool.QueryRow(ctx, `SELECT labels FROM spec.managed_clusters_labels
+		WHERE managed_cluster_name = $1 AND leaf_hub_name = $2`, cluster, testHubCluster).Scan(&labels); err != nil {
+		t.Fatalf("failed to query the labels: %v", err)
+	}
+
+	if len(labels) != 0 {
+		t.Fatalf("the labels are %v after the revert to version 0, expected none", labels)
+	}
+}
+
+func TestUpdateLabelsWithoutUniqueIndex(t *testing.T) {
+	ctx, dbConnectionPool, cluster := setUpLabelsTest(t)
+
+	if _, err := dbConnectionPool.Exec(ctx,
+		`DROP INDEX IF EXISTS spec.managed_clusters_labels_cluster_idx`); err != nil {
+		t.Fatalf("failed to drop the unique index: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if _, err := dbConnectionPool.Exec(context.Background(), testDatabaseSchema[2]); err != nil {
+			t.Errorf("failed to recreate the unique index: %v", err)
+		}
+	})
+
+	_, err := updateLabelsWithRetries(ctx, "tester", cluster, testHubCluster, "", map[string]string{"key": "value"},
+		map[string]struct{}{}, nil, dbConnectionPool, testPatchRetryAttempts)
+	if !errors.Is(err, errLabelsUniqueIndexMissing) {
+		t.Fatalf("the patch without the unique index returned %v, expected %v", err, errLabelsUniqueIndexMissing)
+	}
+}
+
+// connectTestDatabase connects to the test database and executes the statements of the schema of the test. Skips the
+// test if the test database is not set.
+func connectTestDatabase(t *testing.T, schema []string) (context.Context, *pgxpool.Pool) {
+	t.Helper()
+
+	databaseURL := os.Getenv(testDatabaseURLEnvironmentVariable)
+	if databaseURL == "" {
+		t.Skipf("%s is not set", testDatabaseURLEnvironmentVariable)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	t.Cleanup(cancel)
+
+	dbConnectionPool, err := pgxpool.Connect(ctx, databaseURL)
+	if err != nil {
+		t.Fatalf("failed to connect to the test database: %v", err)
+	}
+
+	t.Cleanup(dbConnectionPool.Close)
+
+	for _, statement := range schema {
+		if _, err := dbConnectionPool.Exec(ctx, statement); err != nil {
+			t.Fatalf("failed to create the tables of the test: %v", err)
+		}
+	}
+
+	return ctx, dbConnectionPool
+}
+
+// setUpLabelsTest connects to the test database, creates the tables of the labels and returns a cluster name of the
+// test, whose rows are deleted once the test completes. Skips the test if the test database is not set.
+func setUpLabelsTest(t *testing.T) (context.Context, *pgxpool.Pool, string) {
+	t.Helper()
+
+	ctx, dbConnectionPool := connectTestDatabase(t, testDatabaseSchema)
+
+	cluster := fmt.Sprintf("%s-%d", t.Name(), time.Now().UnixNano())
+
+	t.Cleanup(func() {
+		for _, table := range []string{"spec.managed_clusters_labels", "spec.managed_clusters_labels_history"} {
+			if _, err := dbConnectionPool.Exec(context.Background(), fmt.Sprintf(
+				"DELETE FROM %s WHERE managed_cluster_name = $1 AND leaf_hub_name = $2", table), cluster,
+				testHubCluster); err != nil {
+				t.Errorf("failed to delete the rows of the test from %s: %v", table, err)
+			}
+		}
+	})
+
+	return ctx, dbConnectionPool, cluster
+}
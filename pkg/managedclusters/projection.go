@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	fieldsQueryParameter = "fields"
+	fieldsSeparator      = ","
+	fieldPathSeparator   = "."
+
+	payloadExpression = "payload"
+)
+
+// the select expression for the metadata-only (PartialObjectMetadata) representation of the managed clusters.
+var partialObjectMetadataExpression = fmt.Sprintf(
+	"jsonb_build_object('apiVersion', '%s', 'kind', 'PartialObjectMetadata', 'metadata', payload -> 'metadata')",
+	metav1.SchemeGroupVersion.String())
+
+var errInvalidField = errors.New("invalid field, expected a dot-separated JSON path, e.g. metadata.labels")
+
+// fields that are always returned, so that the projected objects can be identified and patched with a precondition.
+var mandatoryFields = [][]string{{"apiVersion"}, {"kind"}, {"metadata", "name"}, {"metadata", "resourceVersion"}}
+
+// projectionNode is a node in the tree of the projected JSON paths.
+type projectionNode struct {
+	children map[string]*projectionNode
+	keys     []string // the keys of children, in the order of insertion
+	leaf     bool     // the whole subtree of the node is projected
+}
+
+func newProjectionNode() *projectionNode {
+	return &projectionNode{children: make(map[string]*projectionNode)}
+}
+
+func (node *projectionNode) add(path []string) {
+	if node.leaf {
+		return
+	}
+
+	if len(path) == 0 {
+		node.leaf = true
+		node.children = make(map[string]*projectionNode)
+		node.keys = nil
+
+		return
+	}
+
+	child, found := node.children[path[0]]
+	if !found {
+		child = newProjectionNode()
+		node.children[path[0]] = child
+		node.keys = append(node.keys, path[0])
+	}
+
+	child.add(path[1:])
+}
+
+// expression returns the SQL expression that builds the projection of the node, the paths and the keys are passed as
+// query arguments appended to args.
+func (node *projectionNode) expression(path []string, args []interface{}) (string, []interface{}) {
+	if node.leaf {
+		args = append(args, path)
+
+		return fmt.Sprintf("payload #> $%d::text[]", len(args)), args
+	}
+
+	parts := make([]string, 0, len(node.keys))
+
+	for _, key := range node.keys {
+		args = append(args, key)
+		keyIndex := len(args)
+
+		childPath := make([]string, len(path), len(path)+1)
+		copy(childPath, path)
+
+		var childExpression string
+
+		childExpression, args = node.children[key].expression(append(childPath, key), args)
+		parts = append(parts, fmt.Sprintf("$%d::text, %s", keyIndex, childExpression))
+	}
+
+	return "jsonb_build_object(" + strings.Join(parts, ", ") + ")", args
+}
+
+// parseFields returns the JSON paths of the fields query parameter. The parameter can be repeated and can contain
+// comma-separated paths, e.g. fields=metadata.labels,status.conditions.
+func parseFields(rawFields []string) ([][]string, error) {
+	fields := [][]string{}
+
+	for _, rawFieldList := range rawFields {
+		for _, rawField := range strings.Split(rawFieldList, fieldsSeparator) {
+			rawField = strings.TrimPrefix(strings.TrimSpace(rawField), fieldPathSeparator)
+			if rawField == "" {
+				continue
+			}
+
+			path := strings.Split(rawField, fieldPathSeparator)
+			for _, part := range path {
+				if part == "" {
+					return nil, fmt.Errorf("%w: %s", errInvalidField, rawField)
+				}
+			}
+
+			fields = append(fields, path)
+		}
+	}
+
+	return fields, nil
+}
+
+// projectionExpression returns the SQL expression that selects only the fields of the payload, with its arguments.
+func projectionExpression(fields [][]string) (string, []interface{}) {
+	root := newProjectionNode()
+
+	for _, field := range mandatoryFields {
+		root.add(field)
+	}
+
+	for _, field := range fields {
+		root.add(field)
+	}
+
+	expression, args := root.expression([]string{}, []interface{}{})
+
+	return "jsonb_strip_nulls(" + expression + ")", args
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package managedclusters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+)
+
+const (
+	groupByQueryParameter = "groupBy"
+
+	groupByLeafHub           = "leafHub"
+	groupByKubernetesVersion = "kubernetesVersion"
+	groupByVendor            = "vendor"
+	groupByLabelPrefix       = "label:"
+	groupByConditionPrefix   = "condition:"
+)
+
+var errUnknownGroupBy = errors.New("unknown groupBy, expected one of leafHub, kubernetesVersion, vendor, " +
+	"label:<key> or condition:<type>")
+
+type statsGroup struct {
+	Values map[string]*string `json:"values"`
+	Count  int64              `json:"count"`
+}
+
+type stats struct {
+	GroupBy []string      `json:"groupBy"`
+	Total   int64         `json:"total"`
+	Groups  []*statsGroup `json:"groups"`
+}
+
+// Stats middleware, returns the number of managed clusters grouped by the groupBy query parameters.
+func Stats(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		groupBy := ginCtx.QueryArray(groupByQueryParameter)
+
+		query, args, err := statsSQLQuery(ginCtx.Request.Context(), user, groups, authorizationURL,
+			authorizationCABundle, groupBy)
+		if err != nil {
+			ginCtx.JSON(http.StatusBadRequest, gin.H{"status": err.Error()})
+			return
+		}
+
+		log.V(1).Info("Stats query", "sql", query)
+
+		handleStatsRows(ginCtx, query, args, groupBy, dbConnectionPool)
+	}
+}
+
+func statsSQLQuery(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle, groupBy []string) (string, []interface{}, error) {
+	expressions := make([]string, 0, len(groupBy))
+	positions := make([]string, 0, len(groupBy))
+	args := []interface{}{}
+
+	for index, aGroupBy := range groupBy {
+		expression, arg, err := groupByExpression(aGroupBy, len(args)+1)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if arg != "" {
+			args = append(args, arg)
+		}
+
+		expressions = append(expressions, expression)
+		positions = append(positions, fmt.Sprint(index+1))
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("SELECT ")
+
+	for _, expression := range expressions {
+		sb.WriteString(expression)
+		sb.WriteString(", ")
+	}
+
+	sb.WriteString("COUNT(*) FROM status.managed_clusters WHERE TRUE AND ")
+	sb.WriteString(authorization.FilterByAuthorization(ctx, user, groups, authorizationURL, authorizationCABundle,
+		authorization.ClustersQuery, authorization.ClusterUnknown))
+
+	if len(positions) > 0 {
+		sb.WriteString(" GROUP BY " + strings.Join(positions, ", "))
+		sb.WriteString(" ORDER BY " + strings.Join(positions, ", "))
+	}
+
+	return sb.String(), args, nil
+}
+
+// groupByExpression returns the SQL expression for groupBy and the argument to bind as $argumentIndex, if any.
+func groupByExpression(groupBy string, argumentIndex int) (string, string, error) {
+	switch {
+	case groupBy == groupByLeafHub:
+		return "leaf_hub_name", "", nil
+	case groupBy == groupByKubernetesVersion:
+		return "payload -> 'status' -> 'version' ->> 'kubernetes'", "", nil
+	case groupBy == groupByVendor:
+		return "payload -> 'metadata' -> 'labels' ->> 'vendor'", "", nil
+	case strings.HasPrefix(groupBy, groupByLabelPrefix) && len(groupBy) > len(groupByLabelPrefix):
+		return fmt.Sprintf("payload -> 'metadata' -> 'labels' ->> $%d", argumentIndex),
+			strings.TrimPrefix(groupBy, groupByLabelPrefix), nil
+	case strings.HasPrefix(groupBy, groupByConditionPrefix) && len(groupBy) > len(groupByConditionPrefix):
+		return fmt.Sprintf(`(SELECT condition ->> 'status' FROM jsonb_array_elements(payload -> 'status' -> 'conditions')
+			AS condition WHERE condition ->> 'type' = $%d LIMIT 1)`, argumentIndex),
+			strings.TrimPrefix(groupBy, groupByConditionPrefix), nil
+	default:
+		return "", "", fmt.Errorf("%w: %s", errUnknownGroupBy, groupBy)
+	}
+}
+
+func handleStatsRows(ginCtx *gin.Context, query string, args []interface{}, groupBy []string,
+	dbConnectionPool *pgxpool.Pool) {
+	log := logging.FromContext(ginCtx.Request.Context())
+
+	rows, err := dbConnectionPool.Query(ginCtx.Request.Context(), query, args...)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		log.Error(err, "Error in querying managed clusters stats")
+
+		return
+	}
+	defer rows.Close()
+
+	result := &stats{GroupBy: groupBy, Groups: []*statsGroup{}}
+
+	for rows.Next() {
+		values := make([]*string, len(groupBy))
+		group := &statsGroup{Values: make(map[string]*string, len(groupBy))}
+
+		destinations := make([]interface{}, 0, len(groupBy)+1)
+		for index := range values {
+			destinations = append(destinations, &values[index])
+		}
+
+		destinations = append(destinations, &group.Count)
+
+		if err := rows.Scan(destinations...); err != nil {
+			log.Error(err, "Error in scanning managed clusters stats")
+			continue
+		}
+
+		for index, aGroupBy := range groupBy {
+			group.Values[aGroupBy] = values[index]
+		}
+
+		result.Total += group.Count
+		result.Groups = append(result.Groups, group)
+	}
+
+	ginCtx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package metrics contains the Prometheus metrics of the server, served on /metrics.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// Path - the path of the metrics.
+	Path = "/metrics"
+
+	namespace      = "hub_of_hubs_nonk8s_api"
+	unmatchedRoute = "<unmatched>"
+
+	// APIREST - the api label of the REST API.
+	APIREST = "rest"
+	// APIGRPC - the api label of the gRPC API.
+	APIGRPC = "grpc"
+	// APIGraphQL - the api label of the GraphQL API.
+	APIGraphQL = "graphql"
+)
+
+// the metrics of the server, they are registered in registry.
+var (
+	registry = prometheus.NewRegistry()
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "The number of HTTP requests, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "The latency of the HTTP requests, by method, route and status code. Watches last until closed.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+
+	opaCompileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "opa_compile_duration_seconds",
+		Help:      "The latency of the OPA partial evaluations (compile API), by query.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+
+	opaCompileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "opa_compile_errors_total",
+		Help:      "The number of failed OPA partial evaluations (compile API), by query.",
+	}, []string{"query"})
+
+	authenticationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "authentication_duration_seconds",
+		Help:      "The latency of the authentications by the cluster API, by result (success or failure).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"result"})
+
+	authenticationCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authentication_cache_requests_total",
+		Help:      "The number of lookups in the authentication cache, by result (hit or miss).",
+	}, []string{"result"})
+
+	activeWatches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_watches",
+		Help:      "The number of open watches of the managed clusters, by api (rest or grpc).",
+	}, []string{"api"})
+
+	patchRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "patch_retries_total",
+		Help:      "The number of retries of the updates of the labels of the managed clusters on transient database errors.",
+	})
+
+	listRows = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "list_rows",
+		Help:      "The number of managed clusters returned per list, by api (rest, grpc or graphql).",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10), //nolint:gomnd // 1 to 262144 rows
+	}, []string{"api"})
+)
+
+func init() {
+	registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		httpRequests, httpRequestDuration,
+		opaCompileDuration, opaCompileErrors,
+		authenticationDuration, authenticationCacheRequests,
+		activeWatches, patchRetries, listRows,
+	)
+}
+
+// Handler middleware, it serves the metrics in the Prometheus exposition format.
+func Handler() gin.HandlerFunc {
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+
+	return gin.WrapH(handler)
+}
+
+// Middleware counts the requests and observes their latency, by the route template (e.g. /managedclusters/:cluster)
+// so that the number of series does not depend on the names in the paths.
+func Middleware() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		start := time.Now()
+
+		ginCtx.Next()
+
+		route := ginCtx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		code := strconv.Itoa(ginCtx.Writer.Status())
+
+		httpRequests.WithLabelValues(ginCtx.Request.Method, route, code).Inc()
+		httpRequestDuration.WithLabelValues(ginCtx.Request.Method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDatabasePool registers the statistics of the database connection pool.
+func RegisterDatabasePool(dbConnectionPool *pgxpool.Pool) error {
+	if err := registry.Register(&poolCollector{dbConnectionPool: dbConnectionPool}); err != nil {
+		return err //nolint:wrapcheck // the error of the registry is descriptive
+	}
+
+	return nil
+}
+
+// ObserveOPACompile records an OPA partial evaluation of the query.
+func ObserveOPACompile(query string, duration time.Duration, err error) {
+	opaCompileDuration.WithLabelValues(query).Observe(duration.Seconds())
+
+	if err != nil {
+		opaCompileErrors.WithLabelValues(query).Inc()
+	}
+}
+
+// ObserveAuthentication records an authentication by the cluster API.
+func ObserveAuthentication(duration time.Duration, authenticated bool) {
+	result := "success"
+	if !authenticated {
+		result = "failure"
+	}
+
+	authenticationDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
+
+// ObserveAuthenticationCache records a lookup in the authentication cache.
+func ObserveAuthenticationCache(hit bool) {
+	result := "hit"
+	if !hit {
+		result = "miss"
+	}
+
+	authenticationCacheRequests.WithLabelValues(result).Inc()
+}
+
+// WatchStarted records an opened watch of the api, the returned function records its closing.
+func WatchStarted(api string) func() {
+	activeWatches.WithLabelValues(api).Inc()
+
+	return func() {
+		activeWatches.WithLabelValues(api).Dec()
+	}
+}
+
+// PatchRetried records a retry of an update of labels on a transient database error.
+func PatchRetried() {
+	patchRetries.Inc()
+}
+
+// ObserveListRows records the number of managed clusters returned by a list of the api.
+func ObserveListRows(api string, rows int) {
+	listRows.WithLabelValues(api).Observe(float64(rows))
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package metrics
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// the descriptions of the statistics of the database connection pool.
+var (
+	poolAcquiredConnectionsDesc = prometheus.NewDesc(namespace+"_db_pool_acquired_connections",
+		"The number of connections of the database pool in use.", nil, nil)
+	poolIdleConnectionsDesc = prometheus.NewDesc(namespace+"_db_pool_idle_connections",
+		"The number of idle connections of the database pool.", nil, nil)
+	poolConstructingConnectionsDesc = prometheus.NewDesc(namespace+"_db_pool_constructing_connections",
+		"The number of connections of the database pool being established.", nil, nil)
+	poolTotalConnectionsDesc = prometheus.NewDesc(namespace+"_db_pool_total_connections",
+		"The number of connections of the database pool.", nil, nil)
+	poolMaxConnectionsDesc = prometheus.NewDesc(namespace+"_db_pool_max_connections",
+		"The maximum size of the database pool.", nil, nil)
+	poolAcquiresDesc = prometheus.NewDesc(namespace+"_db_pool_acquires_total",
+		"The number of successful acquisitions of connections from the database pool.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc(namespace+"_db_pool_acquire_duration_seconds_total",
+		"The total time spent acquiring connections from the database pool.", nil, nil)
+	poolCanceledAcquiresDesc = prometheus.NewDesc(namespace+"_db_pool_canceled_acquires_total",
+		"The number of acquisitions of connections from the database pool canceled by their context.", nil, nil)
+	poolEmptyAcquiresDesc = prometheus.NewDesc(namespace+"_db_pool_empty_acquires_total",
+		"The number of acquisitions of connections that waited because the database pool was empty.", nil, nil)
+)
+
+// poolCollector collects the statistics of a database connection pool when the metrics are scraped.
+type poolCollector struct {
+	dbConnectionPool *pgxpool.Pool
+}
+
+// Describe sends the descriptions of the statistics, see prometheus.Collector.
+func (collector *poolCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- poolAcquiredConnectionsDesc
+	descs <- poolIdleConnectionsDesc
+	descs <- poolConstructingConnectionsDesc
+	descs <- poolTotalConnectionsDesc
+	descs <- poolMaxConnectionsDesc
+	descs <- poolAcquiresDesc
+	descs <- poolAcquireDurationDesc
+	descs <- poolCanceledAcquiresDesc
+	descs <- poolEmptyAcquiresDesc
+}
+
+// Collect sends the current statistics, see prometheus.Collector.
+func (collector *poolCollector) Collect(metrics chan<- prometheus.Metric) {
+	stat := collector.dbConnectionPool.Stat()
+
+	metrics <- prometheus.MustNewConstMetric(poolAcquiredConnectionsDesc, prometheus.GaugeValue,
+		float64(stat.AcquiredConns()))
+	metrics <- prometheus.MustNewConstMetric(poolIdleConnectionsDesc, prometheus.GaugeValue,
+		float64(stat.IdleConns()))
+	metrics <- prometheus.MustNewConstMetric(poolConstructingConnectionsDesc, prometheus.GaugeValue,
+		float64(stat.ConstructingConns()))
+	metrics <- prometheus.MustNewConstMetric(poolTotalConnectionsDesc, prometheus.GaugeValue,
+		float64(stat.TotalConns()))
+	metrics <- prometheus.MustNewConstMetric(poolMaxConnectionsDesc, prometheus.GaugeValue,
+		float64(stat.MaxConns()))
+	metrics <- prometheus.MustNewConstMetric(poolAcquiresDesc, prometheus.CounterValue,
+		float64(stat.AcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue,
+		stat.AcquireDuration().Seconds())
+	metrics <- prometheus.MustNewConstMetric(poolCanceledAcquiresDesc, prometheus.CounterValue,
+		float64(stat.CanceledAcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(poolEmptyAcquiresDesc, prometheus.CounterValue,
+		float64(stat.EmptyAcquireCount()))
+}
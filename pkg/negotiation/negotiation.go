@@ -0,0 +1,270 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package negotiation
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// MediaTypeJSON - the JSON media type.
+	MediaTypeJSON = "application/json"
+	// MediaTypeYAML - the YAML media type.
+	MediaTypeYAML = "application/yaml"
+	// MediaTypeProtobuf - the Kubernetes protobuf media type.
+	MediaTypeProtobuf = "application/vnd.kubernetes.protobuf"
+
+	// AsTable - the as parameter value for Table responses.
+	AsTable = "Table"
+	// AsPartialObjectMetadata - the as parameter value for metadata-only objects.
+	AsPartialObjectMetadata = "PartialObjectMetadata"
+	// AsPartialObjectMetadataList - the as parameter value for lists of metadata-only objects.
+	AsPartialObjectMetadataList = "PartialObjectMetadataList"
+
+	asParameter      = "as"
+	versionParameter = "v"
+	groupParameter   = "g"
+	qualityParameter = "q"
+	charsetParameter = "charset"
+
+	wildcard = "*"
+)
+
+// specificity of a media range: */*, type/*, type/subtype, type/subtype with parameters.
+const (
+	specificityAny = iota
+	specificityType
+	specificitySubType
+	specificityParameters
+)
+
+// MediaType is a media type offered by the server, or a media range of the Accept header.
+type MediaType struct {
+	Type       string
+	SubType    string
+	Parameters map[string]string
+	quality    float64
+	position   int // the position of the media range in the Accept header
+}
+
+// NewMediaType returns the media type mediaType (e.g. application/json), represented as the Kubernetes kind as
+// of the meta.k8s.io/v1 API group, if as is not empty.
+func NewMediaType(mediaType string, as string) MediaType {
+	theType, subType := splitType(mediaType)
+	offer := MediaType{Type: theType, SubType: subType, Parameters: map[string]string{}, quality: 1}
+
+	if as != "" {
+		offer.Parameters[asParameter] = as
+		offer.Parameters[versionParameter] = metav1.SchemeGroupVersion.Version
+		offer.Parameters[groupParameter] = metav1.GroupName
+	}
+
+	return offer
+}
+
+// Offers returns the media types of mediaTypes, each represented as each of the kinds of as ("" for the object
+// itself).
+func Offers(mediaTypes []string, as ...string) []MediaType {
+	offers := make([]MediaType, 0, len(mediaTypes)*len(as))
+
+	for _, anAs := range as {
+		for _, mediaType := range mediaTypes {
+			offers = append(offers, NewMediaType(mediaType, anAs))
+		}
+	}
+
+	return offers
+}
+
+// MIMEType returns type/subtype, without the parameters.
+func (mediaType MediaType) MIMEType() string {
+	return mediaType.Type + "/" + mediaType.SubType
+}
+
+// As returns the kind the response should be represented as, or an empty string for the object itself.
+func (mediaType MediaType) As() string {
+	return mediaType.Parameters[asParameter]
+}
+
+// String returns the media type with its parameters, e.g. application/json;as=Table;v=v1;g=meta.k8s.io.
+func (mediaType MediaType) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(mediaType.MIMEType())
+
+	for _, parameter := range []string{asParameter, versionParameter, groupParameter} {
+		if value, found := mediaType.Parameters[parameter]; found {
+			sb.WriteString(";" + parameter + "=" + value)
+		}
+	}
+
+	return sb.String()
+}
+
+// ParseAccept parses the media ranges of an Accept header, see
+// https://datatracker.ietf.org/doc/html/rfc7231#section-5.3.2. Malformed media ranges are skipped.
+func ParseAccept(header string) []MediaType {
+	mediaRanges := []MediaType{}
+
+	for _, rawMediaRange := range strings.Split(header, ",") {
+		parts := strings.Split(rawMediaRange, ";")
+
+		theType, subType := splitType(strings.TrimSpace(parts[0]))
+		if theType == "" || subType == "" || (theType == wildcard && subType != wildcard) {
+			continue
+		}
+
+		mediaRange := MediaType{
+			Type: theType, SubType: subType, Parameters: map[string]string{}, quality: 1,
+			position: len(mediaRanges),
+		}
+
+		for _, rawParameter := range parts[1:] {
+			key, value, found := cut(strings.TrimSpace(rawParameter), "=")
+			if !found {
+				continue
+			}
+
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+
+			if key == qualityParameter {
+				quality, err := strconv.ParseFloat(value, 64)
+				if err != nil || quality < 0 || quality > 1 {
+					quality = 0
+				}
+
+				mediaRange.quality = quality
+
+				break // the parameters after q are accept-ext
+			}
+
+			mediaRange.Parameters[key] = value
+		}
+
+		mediaRanges = append(mediaRanges, mediaRange)
+	}
+
+	return mediaRanges
+}
+
+// Negotiate returns the offer that is the most preferred by the Accept header. An offer is matched by the most specific
+// media range that matches it, and gets the quality of that range. Among the offers with the same quality, the one
+// matched by the earliest media range in the header is chosen (like Kubernetes clients list their preferences), and
+// then the first one in offers. If the header is empty, the first offer is returned. Returns false if no offer is
+// acceptable.
+func Negotiate(header string, offers []MediaType) (MediaType, bool) {
+	if len(offers) == 0 {
+		return MediaType{}, false
+	}
+
+	if strings.TrimSpace(header) == "" {
+		return offers[0], true
+	}
+
+	mediaRanges := ParseAccept(header)
+
+	// sort stably by specificity, so that the first matching media range is the most specific one
+	sort.SliceStable(mediaRanges, func(i, j int) bool {
+		return mediaRanges[i].specificity() > mediaRanges[j].specificity()
+	})
+
+	var (
+		best         MediaType
+		bestQuality  float64
+		bestPosition int
+	)
+
+	for _, offer := range offers {
+		for _, mediaRange := range mediaRanges {
+			if !mediaRange.matches(offer) {
+				continue
+			}
+
+			if mediaRange.quality > bestQuality ||
+				(mediaRange.quality == bestQuality && mediaRange.position < bestPosition) {
+				best = offer
+				bestQuality = mediaRange.quality
+				bestPosition = mediaRange.position
+			}
+
+			break
+		}
+	}
+
+	return best, bestQuality > 0
+}
+
+func (mediaType MediaType) specificity() int {
+	switch {
+	case mediaType.Type == wildcard:
+		return specificityAny
+	case mediaType.SubType == wildcard:
+		return specificityType
+	case len(mediaType.significantParameters()) > 0:
+		return specificityParameters
+	default:
+		return specificitySubType
+	}
+}
+
+// matches returns true if the media range matches the offer. Like in Kubernetes, the parameters of the range (except
+// charset) must be equal to the parameters of the offer, so that a Table, for example, is only returned if it was
+// requested explicitly.
+func (mediaType MediaType) matches(offer MediaType) bool {
+	if mediaType.Type != wildcard && !strings.EqualFold(mediaType.Type, offer.Type) {
+		return false
+	}
+
+	if mediaType.SubType != wildcard && !strings.EqualFold(mediaType.SubType, offer.SubType) {
+		return false
+	}
+
+	parameters := mediaType.significantParameters()
+	if len(parameters) != len(offer.Parameters) {
+		return false
+	}
+
+	for key, value := range parameters {
+		if offer.Parameters[key] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (mediaType MediaType) significantParameters() map[string]string {
+	parameters := make(map[string]string, len(mediaType.Parameters))
+
+	for key, value := range mediaType.Parameters {
+		if key != charsetParameter {
+			parameters[key] = value
+		}
+	}
+
+	return parameters
+}
+
+func splitType(mediaType string) (string, string) {
+	theType, subType, found := cut(strings.ToLower(mediaType), "/")
+	if !found {
+		return "", ""
+	}
+
+	return strings.TrimSpace(theType), strings.TrimSpace(subType)
+}
+
+// cut slices s around the first instance of sep, like strings.Cut of go 1.18.
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
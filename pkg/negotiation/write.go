@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package negotiation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer/protobuf"
+	"sigs.k8s.io/yaml"
+)
+
+var errNotRuntimeObject = errors.New("only Kubernetes objects can be serialized as protobuf")
+
+// Write writes the object as the response with the status code, serialized according to the media type.
+func Write(ginCtx *gin.Context, status int, mediaType MediaType, object interface{}) {
+	data, err := serialize(mediaType, object)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in serializing the response",
+			"mediaType", mediaType.String())
+
+		return
+	}
+
+	ginCtx.Data(status, mediaType.String(), data)
+}
+
+// NotAcceptable writes the 406 Not Acceptable response, listing the offered media types.
+func NotAcceptable(ginCtx *gin.Context, offers []MediaType) {
+	offered := make([]string, 0, len(offers))
+	for _, offer := range offers {
+		offered = append(offered, offer.String())
+	}
+
+	ginCtx.JSON(http.StatusNotAcceptable, gin.H{
+		"status":  "none of the media types of the Accept header can be returned",
+		"offered": offered,
+	})
+}
+
+func serialize(mediaType MediaType, object interface{}) ([]byte, error) {
+	switch mediaType.MIMEType() {
+	case MediaTypeProtobuf:
+		runtimeObject, ok := object.(runtime.Object)
+		if !ok {
+			return nil, fmt.Errorf("%w: received %T", errNotRuntimeObject, object)
+		}
+
+		var buffer bytes.Buffer
+
+		if err := protobuf.NewSerializer(nil, nil).Encode(runtimeObject, &buffer); err != nil {
+			return nil, fmt.Errorf("failed to encode protobuf: %w", err)
+		}
+
+		return buffer.Bytes(), nil
+	case MediaTypeYAML:
+		data, err := yaml.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal yaml: %w", err)
+		}
+
+		return data, nil
+	default:
+		data, err := json.Marshal(object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal json: %w", err)
+		}
+
+		return data, nil
+	}
+}
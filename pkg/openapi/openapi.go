@@ -0,0 +1,229 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/discovery"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/negotiation"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	// V2Path - the path of the OpenAPI v2 (swagger) document, used by kubectl explain.
+	V2Path = "/openapi/v2"
+	// V3Path - the path of the OpenAPI v3 document.
+	V3Path = "/openapi/v3"
+
+	openAPIVersion = "3.0.0"
+	title          = "Hub-of-Hubs non-Kubernetes API"
+	version        = "v1"
+
+	v3RefPrefix = "#/components/schemas/"
+
+	// the tag and the operation ID suffix of the operations on the Kubernetes paths of the managed clusters.
+	clusterGroupVersionTag    = "cluster.open-cluster-management.io/v1"
+	clusterGroupVersionSuffix = "ClusterV1"
+)
+
+type schema map[string]interface{}
+
+type info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type server struct {
+	URL string `json:"url"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      schema `json:"schema"`
+}
+
+type mediaTypeObject struct {
+	Schema schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                       `json:"required,omitempty"`
+	Content  map[string]mediaTypeObject `json:"content"`
+}
+
+type response struct {
+	Description string                     `json:"description"`
+	Content     map[string]mediaTypeObject `json:"content,omitempty"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type components struct {
+	Schemas map[string]schema `json:"schemas"`
+}
+
+type document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       info                             `json:"info"`
+	Servers    []server                         `json:"servers"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+// V3 middleware, serves the OpenAPI v3 document of the routes. The document is built on the first request, so that
+// it describes all the routes registered by then. managedClusterSchema is the schema of the ManagedCluster CRD, nil if
+// it is not available.
+func V3(routes func() gin.RoutesInfo, basePath string,
+	managedClusterSchema *apiextensionsv1.JSONSchemaProps) gin.HandlerFunc {
+	var (
+		once sync.Once
+		data []byte
+		err  error
+	)
+
+	return func(ginCtx *gin.Context) {
+		once.Do(func() {
+			data, err = json.Marshal(newDocument(routes(), basePath, managedClusterSchema))
+		})
+
+		if err != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in building the OpenAPI v3 document")
+
+			return
+		}
+
+		ginCtx.Data(http.StatusOK, negotiation.MediaTypeJSON, data)
+	}
+}
+
+// newDocument returns the OpenAPI v3 document that describes the routes.
+func newDocument(routes gin.RoutesInfo, basePath string,
+	managedClusterSchema *apiextensionsv1.JSONSchemaProps) *document {
+	basePath = strings.TrimSuffix(basePath, "/")
+
+	serverURL := basePath
+	if serverURL == "" {
+		serverURL = "/"
+	}
+
+	theDocument := &document{
+		OpenAPI:    openAPIVersion,
+		Info:       info{Title: title, Version: version},
+		Servers:    []server{{URL: serverURL}},
+		Paths:      map[string]map[string]*operation{},
+		Components: components{Schemas: schemas(managedClusterSchema)},
+	}
+
+	// sort the routes, so that the document does not depend on the order of registration
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+
+	for _, route := range routes {
+		// the routes outside the base path, e.g. the health checks of the probes, are not part of the API
+		if basePath != "" && route.Path != basePath && !strings.HasPrefix(route.Path, basePath+"/") {
+			continue
+		}
+
+		path, pathParameters := convertPath(strings.TrimPrefix(route.Path, basePath))
+		if path == "" {
+			path = "/"
+		}
+
+		if _, found := theDocument.Paths[path]; !found {
+			theDocument.Paths[path] = map[string]*operation{}
+		}
+
+		theDocument.Paths[path][strings.ToLower(route.Method)] = newOperation(route, path, pathParameters)
+	}
+
+	return theDocument
+}
+
+// newOperation returns the operation of the route, described by the operations of this package. The routes that are
+// not described get a minimal operation, so that all the served routes appear in the document.
+func newOperation(route gin.RouteInfo, path string, pathParameters []string) *operation {
+	key := route.Method + " " + path
+
+	if spec, found := operations[key]; found {
+		return spec
+	}
+
+	if strings.HasPrefix(path, discovery.ClusterGroupVersionPath+"/") {
+		if spec, found := operations[route.Method+" "+strings.TrimPrefix(path, discovery.ClusterGroupVersionPath)]; found {
+			aliasedOperation := *spec
+			aliasedOperation.OperationID += clusterGroupVersionSuffix
+			aliasedOperation.Tags = []string{clusterGroupVersionTag}
+
+			return &aliasedOperation
+		}
+	}
+
+	genericOperation := &operation{
+		OperationID: operationID(route.Method, path),
+		Summary:     route.Handler,
+		Responses:   map[string]*response{"200": {Description: "OK"}},
+	}
+
+	for _, pathParameter := range pathParameters {
+		genericOperation.Parameters = append(genericOperation.Parameters, &parameter{
+			Name: pathParameter, In: "path", Required: true, Schema: schema{"type": "string"},
+		})
+	}
+
+	return genericOperation
+}
+
+// convertPath converts the gin path (e.g. /managedclusters/:cluster) to an OpenAPI path (/managedclusters/{cluster})
+// and returns the names of its parameters.
+func convertPath(ginPath string) (string, []string) {
+	segments := strings.Split(ginPath, "/")
+	parameters := []string{}
+
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			parameters = append(parameters, segment[1:])
+			segments[index] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/"), parameters
+}
+
+// operationID returns an operation ID from the method and the path, e.g. getOpenapiV3 for GET /openapi/v3.
+func operationID(method, path string) string {
+	var sb strings.Builder
+
+	sb.WriteString(strings.ToLower(method))
+
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-'
+	}) {
+		sb.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+
+	return sb.String()
+}
+
+func ref(name string) schema {
+	return schema{"$ref": v3RefPrefix + name}
+}
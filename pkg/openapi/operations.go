@@ -0,0 +1,636 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package openapi
+
+import (
+	"encoding/json"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/discovery"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/health"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/metrics"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/negotiation"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+// the names of the schemas in the components of the document.
+const (
+	managedClusterSchemaName           = "io.open-cluster-management.cluster.v1.ManagedCluster"
+	managedClusterListSchemaName       = "io.open-cluster-management.cluster.v1.ManagedClusterList"
+	statusSchemaName                   = "io.k8s.apimachinery.pkg.apis.meta.v1.Status"
+	objectMetaSchemaName               = "io.k8s.apimachinery.pkg.apis.meta.v1.ObjectMeta"
+	listMetaSchemaName                 = "io.k8s.apimachinery.pkg.apis.meta.v1.ListMeta"
+	jsonPatchSchemaName                = "JSONPatch"
+	labelsMergePatchSchemaName         = "LabelsMergePatch"
+	managedClusterStatsSchemaName      = "ManagedClusterStats"
+	labelHistorySchemaName             = "LabelHistory"
+	authorizationExplanationSchemaName = "AuthorizationExplanation"
+	policyComplianceSchemaName         = "PolicyCompliance"
+	policyClusterComplianceSchemaName  = "PolicyClusterCompliance"
+	errorSchemaName                    = "Error"
+	discoveryDocumentSchemaName        = "DiscoveryDocument"
+	graphQLRequestSchemaName           = "GraphQLRequest"
+	graphQLResponseSchemaName          = "GraphQLResponse"
+
+	contentTypeJSONPatch           = "application/json-patch+json"
+	contentTypeMergePatch          = "application/merge-patch+json"
+	contentTypeStrategicMergePatch = "application/strategic-merge-patch+json"
+
+	managedClustersTag = "managedclusters"
+	policiesTag        = "policies"
+	discoveryTag       = "discovery"
+	graphQLTag         = "graphql"
+	healthTag          = "health"
+	metricsTag         = "metrics"
+)
+
+// the parameters shared by the operations.
+var (
+	clusterParameter = &parameter{
+		Name: "cluster", In: "path", Required: true, Description: "the name of the managed cluster",
+		Schema: schema{"type": "string"},
+	}
+	hubClusterParameter = &parameter{
+		Name: "hubCluster", In: "query",
+		Description: "the hub cluster of the managed cluster, required if clusters with the same name exist in " +
+			"multiple hub clusters",
+		Schema: schema{"type": "string"},
+	}
+	ifMatchParameter = &parameter{
+		Name: "If-Match", In: "header",
+		Description: "the ETag (the resourceVersion) of the managed cluster, the labels are changed only if they " +
+			"did not change since this version",
+		Schema: schema{"type": "string"},
+	}
+	fieldsParameter = &parameter{
+		Name: "fields", In: "query",
+		Description: "the dot-separated JSON paths of the fields to return, e.g. metadata.labels. " +
+			"apiVersion, kind, metadata.name and metadata.resourceVersion are always returned",
+		Schema: schema{"type": "array", "items": schema{"type": "string"}},
+	}
+)
+
+// the operations served by this server, by method and path relative to the base path. The operations of the
+// Kubernetes paths of the managed clusters (under /apis/cluster.open-cluster-management.io/v1) are the same as the
+// operations of /managedclusters.
+var operations = map[string]*operation{
+	"GET /managedclusters": {
+		OperationID: "listManagedClusters",
+		Summary: "list or watch the managed clusters the user is authorized to view. Tables and " +
+			"PartialObjectMetadataList are returned if requested in the Accept header",
+		Tags: []string{managedClustersTag},
+		Parameters: []*parameter{
+			{
+				Name: "search", In: "query",
+				Description: "free text to search in the names, the labels, the claims and the URLs of the clusters",
+				Schema:      schema{"type": "string"},
+			},
+			fieldsParameter,
+			{
+				Name: "watch", In: "query", Description: "watch the changes of the managed clusters",
+				Schema: schema{"type": "boolean"},
+			},
+			{
+				Name: "asArray", In: "query", Description: "return the items as a JSON array instead of a list",
+				Schema: schema{"type": "boolean"},
+			},
+		},
+		Responses: map[string]*response{
+			"200": kubernetesResponse("the managed clusters", ref(managedClusterListSchemaName)),
+			"400": errorResponse("invalid fields"),
+			"406": errorResponse("none of the media types of the Accept header is supported"),
+		},
+	},
+	"GET /managedclusterstats": {
+		OperationID: "getManagedClustersStats",
+		Summary:     "count the managed clusters the user is authorized to view, grouped by the groupBy parameters",
+		Tags:        []string{managedClustersTag},
+		Parameters: []*parameter{
+			{
+				Name: "groupBy", In: "query",
+				Description: "leafHub, kubernetesVersion, vendor, label:<key> or condition:<type>",
+				Schema:      schema{"type": "array", "items": schema{"type": "string"}},
+			},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the number of managed clusters per group", ref(managedClusterStatsSchemaName)),
+			"400": errorResponse("unknown groupBy"),
+		},
+	},
+	"GET /authorization/managedclusters": {
+		OperationID: "explainManagedClustersAuthorization",
+		Summary: "explain the managed clusters visible to the user, or to another user and groups for the members of " +
+			"the admin groups",
+		Tags: []string{managedClustersTag},
+		Parameters: []*parameter{
+			{
+				Name: "user", In: "query", Description: "the user to explain, the current user by default",
+				Schema: schema{"type": "string"},
+			},
+			{
+				Name: "group", In: "query", Description: "the groups of the user to explain",
+				Schema: schema{"type": "array", "items": schema{"type": "string"}},
+			},
+			{
+				Name: "decisions", In: "query",
+				Description: "return whether each managed cluster is visible, and why. Only the members of the admin " +
+					"groups get the denied managed clusters, the other users get their number",
+				Schema: schema{"type": "boolean"},
+			},
+			{
+				Name: "cluster", In: "query", Description: "return only the decisions of the managed clusters of this name",
+				Schema: schema{"type": "string"},
+			},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the explanation of the authorization", ref(authorizationExplanationSchemaName)),
+			"400": errorResponse("invalid decisions"),
+			"403": errorResponse("the user is not a member of the admin groups, and explains another user"),
+		},
+	},
+	"GET /managedclusters/{cluster}": {
+		OperationID: "getManagedCluster",
+		Summary: "get a managed cluster. A Table or PartialObjectMetadata is returned if requested in the Accept " +
+			"header",
+		Tags:       []string{managedClustersTag},
+		Parameters: []*parameter{clusterParameter, hubClusterParameter, fieldsParameter},
+		Responses: map[string]*response{
+			"200": kubernetesResponse("the managed cluster", ref(managedClusterSchemaName)),
+			"400": errorResponse("invalid fields, or the hub cluster is ambiguous"),
+			"404": kubernetesResponse("the managed cluster is not found", ref(statusSchemaName)),
+			"406": errorResponse("none of the media types of the Accept header is supported"),
+		},
+	},
+	"PATCH /managedclusters/{cluster}": {
+		OperationID: "patchManagedClusterLabels",
+		Summary: "add or remove labels of a managed cluster, only the labels can be patched. The patch is " +
+			"conditioned on the resourceVersion of the If-Match header or of metadata.resourceVersion, if any",
+		Tags:       []string{managedClustersTag},
+		Parameters: []*parameter{clusterParameter, hubClusterParameter, ifMatchParameter},
+		RequestBody: &requestBody{
+			Required: true,
+			Content: map[string]mediaTypeObject{
+				contentTypeJSONPatch:           {Schema: ref(jsonPatchSchemaName)},
+				contentTypeMergePatch:          {Schema: ref(labelsMergePatchSchemaName)},
+				contentTypeStrategicMergePatch: {Schema: ref(labelsMergePatchSchemaName)},
+			},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the patched managed cluster", ref(managedClusterSchemaName)),
+			"400": errorResponse("invalid patch, or the hub cluster is ambiguous"),
+			"403": errorResponse("the user is not authorized to patch the managed cluster, or the admission denied the change"),
+			"404": kubernetesResponse("the managed cluster is not found", ref(statusSchemaName)),
+			"409": kubernetesResponse("the labels changed since the resourceVersion of the precondition",
+				ref(statusSchemaName)),
+			"422": kubernetesResponse("the labels are invalid", ref(statusSchemaName)),
+			"501": errorResponse("the patch changes fields other than the labels"),
+		},
+	},
+	"GET /managedclusters/{cluster}/labelhistory": {
+		OperationID: "getManagedClusterLabelHistory",
+		Summary:     "get the history of the label changes of a managed cluster, the last change first",
+		Tags:        []string{managedClustersTag},
+		Parameters:  []*parameter{clusterParameter, hubClusterParameter},
+		Responses: map[string]*response{
+			"200": jsonResponse("the label changes of the managed cluster", ref(labelHistorySchemaName)),
+			"400": errorResponse("the hub cluster is ambiguous"),
+			"404": kubernetesResponse("the managed cluster is not found", ref(statusSchemaName)),
+		},
+	},
+	"POST /managedclusters/{cluster}/labelhistory/{version}/revert": {
+		OperationID: "revertManagedClusterLabels",
+		Summary:     "revert the labels of a managed cluster to a version of the label history",
+		Tags:        []string{managedClustersTag},
+		Parameters: []*parameter{
+			clusterParameter,
+			{
+				Name: "version", In: "path", Required: true,
+				Description: "the version of the labels to revert to, 0 for the labels before any change",
+				Schema:      schema{"type": "integer", "format": "int64"},
+			},
+			hubClusterParameter,
+			ifMatchParameter,
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the patched managed cluster", ref(managedClusterSchemaName)),
+			"400": errorResponse("invalid version, or the hub cluster is ambiguous"),
+			"403": errorResponse("the user is not authorized to patch the managed cluster, or the admission denied the change"),
+			"404": errorResponse("the managed cluster or the version of the labels is not found"),
+			"409": kubernetesResponse("the labels changed since the resourceVersion of the precondition",
+				ref(statusSchemaName)),
+			"422": kubernetesResponse("the labels are invalid", ref(statusSchemaName)),
+		},
+	},
+	"GET /policies": {
+		OperationID: "listPolicies",
+		Summary:     "list the policies the user is authorized to view, with the compliance of the visible clusters",
+		Tags:        []string{policiesTag},
+		Parameters: []*parameter{
+			{
+				Name: "standard", In: "query", Description: "return only the policies of the standard",
+				Schema: schema{"type": "string"},
+			},
+			{
+				Name: "category", In: "query", Description: "return only the policies of the category",
+				Schema: schema{"type": "string"},
+			},
+			{
+				Name: "control", In: "query", Description: "return only the policies of the control",
+				Schema: schema{"type": "string"},
+			},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the policies", schema{"type": "array", "items": ref(policyComplianceSchemaName)}),
+		},
+	},
+	"GET /policies/{policy}/status": {
+		OperationID: "getPolicyStatus",
+		Summary:     "list the compliance of each cluster of a policy visible to the user",
+		Tags:        []string{policiesTag},
+		Parameters: []*parameter{
+			{
+				Name: "policy", In: "path", Required: true, Description: "the ID of the policy",
+				Schema: schema{"type": "string"},
+			},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the compliance of the clusters",
+				schema{"type": "array", "items": ref(policyClusterComplianceSchemaName)}),
+			"404": errorResponse("the policy is not found"),
+		},
+	},
+	"GET /graphql": {
+		OperationID: "getGraphQL",
+		Summary:     "run a GraphQL query of the managed clusters",
+		Tags:        []string{graphQLTag},
+		Parameters: []*parameter{
+			{Name: "query", In: "query", Required: true, Description: "the GraphQL query", Schema: schema{"type": "string"}},
+			{Name: "operationName", In: "query", Description: "the operation to run", Schema: schema{"type": "string"}},
+			{
+				Name: "variables", In: "query", Description: "the variables of the query, as a JSON object",
+				Schema: schema{"type": "string"},
+			},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the result of the query", ref(graphQLResponseSchemaName)),
+			"400": errorResponse("the GraphQL request is invalid"),
+		},
+	},
+	"POST /graphql": {
+		OperationID: "postGraphQL",
+		Summary:     "run a GraphQL query of the managed clusters",
+		Tags:        []string{graphQLTag},
+		RequestBody: &requestBody{
+			Required: true,
+			Content:  map[string]mediaTypeObject{negotiation.MediaTypeJSON: {Schema: ref(graphQLRequestSchemaName)}},
+		},
+		Responses: map[string]*response{
+			"200": jsonResponse("the result of the query", ref(graphQLResponseSchemaName)),
+			"400": errorResponse("the GraphQL request is invalid"),
+		},
+	},
+	"GET " + discovery.APIPath:  discoveryOperation("getCoreAPIVersions", "APIVersions"),
+	"GET " + discovery.APIsPath: discoveryOperation("getAPIGroupList", "APIGroupList"),
+	"GET " + discovery.ClusterGroupPath: discoveryOperation("getClusterAPIGroup",
+		"APIGroup of cluster.open-cluster-management.io"),
+	"GET " + discovery.ClusterGroupVersionPath: discoveryOperation("getClusterV1APIResources",
+		"APIResourceList of cluster.open-cluster-management.io/v1"),
+	"GET " + V2Path: {
+		OperationID: "getOpenAPIV2",
+		Summary:     "the OpenAPI v2 document of this server, as JSON or protobuf",
+		Tags:        []string{discoveryTag},
+		Responses:   map[string]*response{"200": jsonResponse("the OpenAPI v2 document", schema{"type": "object"})},
+	},
+	"GET " + V3Path: {
+		OperationID: "getOpenAPIV3",
+		Summary:     "the OpenAPI v3 document of this server",
+		Tags:        []string{discoveryTag},
+		Responses:   map[string]*response{"200": jsonResponse("the OpenAPI v3 document", schema{"type": "object"})},
+	},
+	"GET " + health.LivezPath:   healthOperation("getLivez", "the liveness checks"),
+	"GET " + health.ReadyzPath:  healthOperation("getReadyz", "the readiness checks"),
+	"GET " + health.HealthzPath: healthOperation("getHealthz", "all the health checks"),
+	"GET " + metrics.Path: {
+		OperationID: "getMetrics",
+		Summary:     "the Prometheus metrics of this server, not authenticated",
+		Tags:        []string{metricsTag},
+		Responses: map[string]*response{
+			"200": {
+				Description: "the metrics in the Prometheus text exposition format",
+				Content:     map[string]mediaTypeObject{"text/plain": {Schema: schema{"type": "string"}}},
+			},
+		},
+	},
+}
+
+// healthOperation returns the operation of health checks, the health checks are not authenticated.
+func healthOperation(operationID, summary string) *operation {
+	textResponse := func(description string) *response {
+		return &response{
+			Description: description,
+			Content:     map[string]mediaTypeObject{"text/plain": {Schema: schema{"type": "string"}}},
+		}
+	}
+
+	return &operation{
+		OperationID: operationID,
+		Summary:     summary + ". The result of each check is listed if a check fails or with the verbose parameter",
+		Tags:        []string{healthTag},
+		Parameters: []*parameter{
+			{Name: "verbose", In: "query", Description: "list the result of each check", Schema: schema{"type": "boolean"}},
+			{
+				Name: "exclude", In: "query", Description: "the names of the checks to skip",
+				Schema: schema{"type": "array", "items": schema{"type": "string"}},
+			},
+		},
+		Responses: map[string]*response{
+			"200": textResponse("the checks passed"),
+			"500": textResponse("a check failed"),
+		},
+	}
+}
+
+func discoveryOperation(operationID, kind string) *operation {
+	return &operation{
+		OperationID: operationID,
+		Summary:     "the Kubernetes discovery document " + kind,
+		Tags:        []string{discoveryTag},
+		Responses: map[string]*response{
+			"200": jsonResponse("the discovery document", ref(discoveryDocumentSchemaName)),
+		},
+	}
+}
+
+func jsonResponse(description string, responseSchema schema) *response {
+	return &response{
+		Description: description,
+		Content:     map[string]mediaTypeObject{negotiation.MediaTypeJSON: {Schema: responseSchema}},
+	}
+}
+
+func kubernetesResponse(description string, responseSchema schema) *response {
+	return &response{
+		Description: description,
+		Content: map[string]mediaTypeObject{
+			negotiation.MediaTypeJSON: {Schema: responseSchema},
+			negotiation.MediaTypeYAML: {Schema: responseSchema},
+		},
+	}
+}
+
+func errorResponse(description string) *response {
+	return jsonResponse(description, ref(errorSchemaName))
+}
+
+// schemas returns the schemas of the components of the document.
+func schemas(managedClusterSchema *apiextensionsv1.JSONSchemaProps) map[string]schema {
+	stringSchema := schema{"type": "string"}
+	stringArraySchema := schema{"type": "array", "items": stringSchema}
+	countSchema := schema{"type": "integer", "format": "int64"}
+	complianceCountsSchema := schema{
+		"type": "object",
+		"properties": schema{
+			"compliant": countSchema, "noncompliant": countSchema, "unknown": countSchema,
+		},
+	}
+
+	return map[string]schema{
+		managedClusterSchemaName: convertManagedClusterSchema(managedClusterSchema),
+		managedClusterListSchemaName: {
+			"type":        "object",
+			"description": "ManagedClusterList is a list of managed clusters",
+			"properties": schema{
+				"apiVersion": stringSchema,
+				"kind":       stringSchema,
+				"metadata":   ref(listMetaSchemaName),
+				"items":      schema{"type": "array", "items": ref(managedClusterSchemaName)},
+			},
+			"required": []string{"items"},
+			"x-kubernetes-group-version-kind": []schema{
+				{
+					"group": clusterv1.GroupVersion.Group, "version": clusterv1.GroupVersion.Version,
+					"kind": "ManagedClusterList",
+				},
+			},
+		},
+		statusSchemaName: {
+			"type":        "object",
+			"description": "Status is a return value for calls that don't return other objects",
+			"properties": schema{
+				"apiVersion": stringSchema,
+				"kind":       stringSchema,
+				"status":     stringSchema,
+				"message":    stringSchema,
+				"reason":     stringSchema,
+				"code":       schema{"type": "integer", "format": "int32"},
+				"details":    schema{"type": "object"},
+			},
+		},
+		objectMetaSchemaName: {
+			"type":        "object",
+			"description": "ObjectMeta is the metadata of a Kubernetes object",
+			"properties": schema{
+				"name":              stringSchema,
+				"namespace":         stringSchema,
+				"uid":               stringSchema,
+				"resourceVersion":   stringSchema,
+				"creationTimestamp": schema{"type": "string", "format": "date-time"},
+				"labels":            schema{"type": "object", "additionalProperties": stringSchema},
+				"annotations":       schema{"type": "object", "additionalProperties": stringSchema},
+			},
+		},
+		listMetaSchemaName: {
+			"type":        "object",
+			"description": "ListMeta is the metadata of a Kubernetes list",
+			"properties": schema{
+				"resourceVersion": stringSchema,
+				"continue":        stringSchema,
+			},
+		},
+		jsonPatchSchemaName: {
+			"type": "array",
+			"description": "a JSON patch (RFC 6902) of the labels, e.g. " +
+				"[{\"op\": \"add\", \"path\": \"/metadata/labels/a\"}], with an optional test of /metadata/resourceVersion",
+			"items": schema{
+				"type": "object",
+				"properties": schema{
+					"op":    schema{"type": "string", "enum": []string{"add", "remove", "test"}},
+					"path":  schema{"type": "string", "pattern": "^/metadata/(labels/|resourceVersion$)"},
+					"value": stringSchema,
+				},
+				"required": []string{"op", "path"},
+			},
+		},
+		labelsMergePatchSchemaName: {
+			"type":        "object",
+			"description": "a merge patch (RFC 7386) of the labels, null values remove labels",
+			"properties": schema{
+				"metadata": schema{
+					"type": "object",
+					"properties": schema{
+						"labels": schema{
+							"type": "object", "additionalProperties": schema{"type": "string", "nullable": true},
+						},
+						"resourceVersion": stringSchema,
+					},
+				},
+			},
+		},
+		managedClusterStatsSchemaName: {
+			"type": "object",
+			"properties": schema{
+				"groupBy": stringArraySchema,
+				"total":   countSchema,
+				"groups": schema{
+					"type": "array",
+					"items": schema{
+						"type": "object",
+						"properties": schema{
+							"values": schema{
+								"type": "object", "additionalProperties": schema{"type": "string", "nullable": true},
+							},
+							"count": countSchema,
+						},
+					},
+				},
+			},
+		},
+		labelHistorySchemaName: {
+			"type": "object",
+			"properties": schema{
+				"cluster":    stringSchema,
+				"hubCluster": stringSchema,
+				"items": schema{
+					"type": "array",
+					"items": schema{
+						"type": "object",
+						"properties": schema{
+							"version":          countSchema,
+							"user":             stringSchema,
+							"timestamp":        schema{"type": "string", "format": "date-time"},
+							"addedLabels":      schema{"type": "object", "additionalProperties": stringSchema},
+							"removedLabelKeys": stringArraySchema,
+							"labels":           schema{"type": "object", "additionalProperties": stringSchema},
+							"deletedLabelKeys": stringArraySchema,
+						},
+					},
+				},
+			},
+		},
+		authorizationExplanationSchemaName: {
+			"type": "object",
+			"properties": schema{
+				"user":            stringSchema,
+				"groups":          stringArraySchema,
+				"query":           stringSchema,
+				"unknown":         stringSchema,
+				"compileResponse": schema{"type": "object", "description": "the response of the OPA compile API"},
+				"error":           stringSchema,
+				"predicates":      stringArraySchema,
+				"filter":          stringSchema,
+				"untranslatedTerms": schema{
+					"type": "array",
+					"items": schema{
+						"type":       "object",
+						"properties": schema{"term": schema{}, "error": stringSchema},
+					},
+				},
+				"decisions": schema{
+					"type": "array",
+					"items": schema{
+						"type": "object",
+						"properties": schema{
+							"cluster":    stringSchema,
+							"hubCluster": stringSchema,
+							"allowed":    schema{"type": "boolean"},
+							"reason":     stringSchema,
+						},
+					},
+				},
+				"deniedClusters": schema{
+					"type":        "integer",
+					"description": "the number of the denied managed clusters, for the users outside the admin groups",
+				},
+			},
+		},
+		policyComplianceSchemaName: {
+			"type": "object",
+			"properties": schema{
+				"id":         stringSchema,
+				"metadata":   ref(objectMetaSchemaName),
+				"standards":  stringArraySchema,
+				"categories": stringArraySchema,
+				"controls":   stringArraySchema,
+				"compliance": complianceCountsSchema,
+				"leafHubs": schema{
+					"type": "array",
+					"items": schema{
+						"type": "object",
+						"properties": schema{
+							"leafHubName": stringSchema, "compliant": countSchema, "noncompliant": countSchema,
+							"unknown": countSchema,
+						},
+					},
+				},
+			},
+		},
+		policyClusterComplianceSchemaName: {
+			"type": "object",
+			"properties": schema{
+				"clusterName": stringSchema,
+				"leafHubName": stringSchema,
+				"compliance":  schema{"type": "string", "enum": []string{"compliant", "non_compliant", "unknown"}},
+			},
+		},
+		errorSchemaName: {
+			"type":       "object",
+			"properties": schema{"status": stringSchema},
+		},
+		discoveryDocumentSchemaName: {
+			"type":        "object",
+			"description": "a Kubernetes API discovery document",
+			"properties":  schema{"apiVersion": stringSchema, "kind": stringSchema},
+		},
+		graphQLRequestSchemaName: {
+			"type": "object",
+			"properties": schema{
+				"query":         stringSchema,
+				"operationName": stringSchema,
+				"variables":     schema{"type": "object"},
+			},
+			"required": []string{"query"},
+		},
+		graphQLResponseSchemaName: {
+			"type": "object",
+			"properties": schema{
+				"data": schema{"type": "object"},
+				"errors": schema{
+					"type":  "array",
+					"items": schema{"type": "object", "properties": schema{"message": stringSchema}},
+				},
+			},
+		},
+	}
+}
+
+// convertManagedClusterSchema returns the schema of the ManagedCluster CRD, with the group, version and kind extension
+// that kubectl explain uses to find it.
+func convertManagedClusterSchema(managedClusterSchema *apiextensionsv1.JSONSchemaProps) schema {
+	converted := schema{"type": "object", "description": "ManagedCluster represents a managed cluster"}
+
+	if managedClusterSchema != nil {
+		if data, err := json.Marshal(managedClusterSchema); err != nil {
+			logging.Default().Error(err, "Error in marshaling the ManagedCluster schema")
+		} else if err := json.Unmarshal(data, &converted); err != nil {
+			logging.Default().Error(err, "Error in unmarshaling the ManagedCluster schema")
+		}
+	}
+
+	converted["x-kubernetes-group-version-kind"] = []schema{
+		{"group": clusterv1.GroupVersion.Group, "version": clusterv1.GroupVersion.Version, "kind": "ManagedCluster"},
+	}
+
+	return converted
+}
@@ -0,0 +1,284 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/protobuf/proto"
+	"github.com/googleapis/gnostic/compiler"
+	openapiv2 "github.com/googleapis/gnostic/openapiv2"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/negotiation"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
+
+const (
+	swaggerVersion = "2.0"
+	v2RefPrefix    = "#/definitions/"
+
+	// the media type of the protobuf encoding of OpenAPI v2 documents, requested by kubectl.
+	mediaTypeProtobufV2 = "application/com.github.proto-openapi.spec.v2@v1.0+protobuf"
+)
+
+// the keywords of the OpenAPI v3 schemas that are also keywords of the OpenAPI v2 schemas. The others (e.g. nullable,
+// oneOf) are dropped from the v2 document, like Kubernetes does for the CRD schemas.
+var v2SchemaKeywords = map[string]bool{
+	"$ref": true, "format": true, "title": true, "description": true, "default": true, "multipleOf": true,
+	"maximum": true, "exclusiveMaximum": true, "minimum": true, "exclusiveMinimum": true, "maxLength": true,
+	"minLength": true, "pattern": true, "maxItems": true, "minItems": true, "uniqueItems": true,
+	"maxProperties": true, "minProperties": true, "required": true, "enum": true, "type": true, "readOnly": true,
+	"example": true, "properties": true, "items": true, "additionalProperties": true, "allOf": true,
+}
+
+// the media types of the v2 document.
+var v2Offers = []negotiation.MediaType{
+	negotiation.NewMediaType(negotiation.MediaTypeJSON, ""),
+	negotiation.NewMediaType(mediaTypeProtobufV2, ""),
+}
+
+type parameterV2 struct {
+	Name             string `json:"name"`
+	In               string `json:"in"`
+	Description      string `json:"description,omitempty"`
+	Required         bool   `json:"required,omitempty"`
+	Type             string `json:"type,omitempty"`
+	Items            schema `json:"items,omitempty"`
+	CollectionFormat string `json:"collectionFormat,omitempty"`
+	Schema           schema `json:"schema,omitempty"`
+}
+
+type responseV2 struct {
+	Description string `json:"description"`
+	Schema      schema `json:"schema,omitempty"`
+}
+
+type operationV2 struct {
+	OperationID string                 `json:"operationId"`
+	Summary     string                 `json:"summary,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Consumes    []string               `json:"consumes,omitempty"`
+	Produces    []string               `json:"produces,omitempty"`
+	Parameters  []*parameterV2         `json:"parameters,omitempty"`
+	Responses   map[string]*responseV2 `json:"responses"`
+}
+
+type swagger struct {
+	Swagger     string                             `json:"swagger"`
+	Info        info                               `json:"info"`
+	BasePath    string                             `json:"basePath"`
+	Paths       map[string]map[string]*operationV2 `json:"paths"`
+	Definitions map[string]schema                  `json:"definitions"`
+}
+
+// V2 middleware, serves the OpenAPI v2 document of the routes, as JSON or as protobuf (for kubectl explain). The
+// document is built on the first request, see V3.
+func V2(routes func() gin.RoutesInfo, basePath string,
+	managedClusterSchema *apiextensionsv1.JSONSchemaProps) gin.HandlerFunc {
+	var (
+		once         sync.Once
+		data         []byte
+		protobufData []byte
+		err          error
+	)
+
+	return func(ginCtx *gin.Context) {
+		once.Do(func() {
+			data, protobufData, err = marshalV2(toV2(newDocument(routes(), basePath, managedClusterSchema)))
+		})
+
+		if err != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			logging.FromContext(ginCtx.Request.Context()).Error(err, "Error in building the OpenAPI v2 document")
+
+			return
+		}
+
+		mediaType, acceptable := negotiation.Negotiate(ginCtx.GetHeader("Accept"), v2Offers)
+		if !acceptable {
+			negotiation.NotAcceptable(ginCtx, v2Offers)
+			return
+		}
+
+		if mediaType.MIMEType() == mediaTypeProtobufV2 {
+			ginCtx.Data(http.StatusOK, mediaTypeProtobufV2, protobufData)
+			return
+		}
+
+		ginCtx.Data(http.StatusOK, negotiation.MediaTypeJSON, data)
+	}
+}
+
+// marshalV2 returns the JSON and the protobuf encodings of the v2 document.
+func marshalV2(theSwagger *swagger) ([]byte, []byte, error) {
+	data, err := json.Marshal(theSwagger)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal the document as JSON: %w", err)
+	}
+
+	// the protobuf encoding is generated from the JSON encoding, like in k8s.io/kube-openapi
+	documentInfo, err := compiler.ReadInfoFromBytes("", data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the document: %w", err)
+	}
+
+	document, err := openapiv2.NewDocument(documentInfo, compiler.NewContext("$root", nil))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the document: %w", err)
+	}
+
+	protobufData, err := proto.Marshal(document)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal the document as protobuf: %w", err)
+	}
+
+	return data, protobufData, nil
+}
+
+// toV2 converts the OpenAPI v3 document to an OpenAPI v2 document.
+func toV2(theDocument *document) *swagger {
+	basePath := theDocument.Servers[0].URL
+
+	theSwagger := &swagger{
+		Swagger:     swaggerVersion,
+		Info:        theDocument.Info,
+		BasePath:    basePath,
+		Paths:       make(map[string]map[string]*operationV2, len(theDocument.Paths)),
+		Definitions: make(map[string]schema, len(theDocument.Components.Schemas)),
+	}
+
+	for name, aSchema := range theDocument.Components.Schemas {
+		theSwagger.Definitions[name] = toV2Schema(aSchema)
+	}
+
+	for path, pathOperations := range theDocument.Paths {
+		theSwagger.Paths[path] = make(map[string]*operationV2, len(pathOperations))
+
+		for method, anOperation := range pathOperations {
+			theSwagger.Paths[path][method] = toV2Operation(anOperation)
+		}
+	}
+
+	return theSwagger
+}
+
+func toV2Operation(anOperation *operation) *operationV2 {
+	converted := &operationV2{
+		OperationID: anOperation.OperationID,
+		Summary:     anOperation.Summary,
+		Tags:        anOperation.Tags,
+		Parameters:  make([]*parameterV2, 0, len(anOperation.Parameters)+1),
+		Responses:   make(map[string]*responseV2, len(anOperation.Responses)),
+	}
+
+	for _, aParameter := range anOperation.Parameters {
+		convertedParameter := &parameterV2{
+			Name: aParameter.Name, In: aParameter.In, Description: aParameter.Description,
+			Required: aParameter.Required,
+		}
+
+		convertedParameter.Type, _ = aParameter.Schema["type"].(string)
+		if items, found := aParameter.Schema["items"].(schema); found {
+			convertedParameter.Items = toV2Schema(items)
+			convertedParameter.CollectionFormat = "multi"
+		}
+
+		converted.Parameters = append(converted.Parameters, convertedParameter)
+	}
+
+	if anOperation.RequestBody != nil {
+		converted.Consumes = sortedKeys(anOperation.RequestBody.Content)
+		converted.Parameters = append(converted.Parameters, &parameterV2{
+			Name: "body", In: "body", Required: anOperation.RequestBody.Required,
+			Schema: toV2Schema(anOperation.RequestBody.Content[converted.Consumes[0]].Schema),
+		})
+	}
+
+	produces := map[string]mediaTypeObject{}
+
+	for code, aResponse := range anOperation.Responses {
+		convertedResponse := &responseV2{Description: aResponse.Description}
+
+		// OpenAPI v2 has a single schema per response, all the media types of the responses have the same schema
+		for mediaType, content := range aResponse.Content {
+			convertedResponse.Schema = toV2Schema(content.Schema)
+			produces[mediaType] = content
+		}
+
+		converted.Responses[code] = convertedResponse
+	}
+
+	converted.Produces = sortedKeys(produces)
+
+	return converted
+}
+
+// toV2Schema converts an OpenAPI v3 schema to an OpenAPI v2 schema: the references point to the definitions and the
+// keywords that OpenAPI v2 does not support are dropped. The vendor extensions (x-) are kept.
+func toV2Schema(aSchema map[string]interface{}) schema {
+	converted := make(schema, len(aSchema))
+
+	for keyword, value := range aSchema {
+		switch {
+		case keyword == "$ref":
+			reference, _ := value.(string)
+			converted[keyword] = v2RefPrefix + strings.TrimPrefix(reference, v3RefPrefix)
+		case keyword == "properties":
+			properties := schema{}
+
+			for name, property := range toMap(value) {
+				properties[name] = toV2Schema(toMap(property))
+			}
+
+			converted[keyword] = properties
+		case keyword == "items" || keyword == "additionalProperties":
+			if subSchema := toMap(value); subSchema != nil {
+				converted[keyword] = toV2Schema(subSchema)
+			} else {
+				converted[keyword] = value // e.g. additionalProperties: false
+			}
+		case keyword == "allOf":
+			subSchemas, _ := value.([]interface{})
+			convertedSubSchemas := make([]schema, 0, len(subSchemas))
+
+			for _, subSchema := range subSchemas {
+				convertedSubSchemas = append(convertedSubSchemas, toV2Schema(toMap(subSchema)))
+			}
+
+			converted[keyword] = convertedSubSchemas
+		case v2SchemaKeywords[keyword] || strings.HasPrefix(keyword, "x-"):
+			converted[keyword] = value
+		}
+	}
+
+	return converted
+}
+
+func toMap(value interface{}) map[string]interface{} {
+	switch typedValue := value.(type) {
+	case schema:
+		return typedValue
+	case map[string]interface{}:
+		return typedValue
+	default:
+		return nil
+	}
+}
+
+func sortedKeys(contents map[string]mediaTypeObject) []string {
+	keys := make([]string, 0, len(contents))
+	for key := range contents {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
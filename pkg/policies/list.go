@@ -0,0 +1,233 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package policies
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	standardsAnnotation  = "policy.open-cluster-management.io/standards"
+	categoriesAnnotation = "policy.open-cluster-management.io/categories"
+	controlsAnnotation   = "policy.open-cluster-management.io/controls"
+
+	standardQueryParameter = "standard"
+	categoryQueryParameter = "category"
+	controlQueryParameter  = "control"
+
+	complianceCompliant    = "compliant"
+	complianceNonCompliant = "non_compliant"
+)
+
+// complianceCounts holds the number of clusters per compliance state.
+type complianceCounts struct {
+	Compliant    int64 `json:"compliant"`
+	NonCompliant int64 `json:"noncompliant"`
+	Unknown      int64 `json:"unknown"`
+}
+
+func (counts *complianceCounts) add(compliance string, count int64) {
+	switch compliance {
+	case complianceCompliant:
+		counts.Compliant += count
+	case complianceNonCompliant:
+		counts.NonCompliant += count
+	default:
+		counts.Unknown += count
+	}
+}
+
+type leafHubCompliance struct {
+	LeafHubName string `json:"leafHubName"`
+	complianceCounts
+}
+
+type policyCompliance struct {
+	ID         string               `json:"id"`
+	Metadata   metav1.ObjectMeta    `json:"metadata"`
+	Standards  []string             `json:"standards"`
+	Categories []string             `json:"categories"`
+	Controls   []string             `json:"controls"`
+	Compliance complianceCounts     `json:"compliance"`
+	LeafHubs   []*leafHubCompliance `json:"leafHubs"`
+}
+
+// List middleware.
+func List(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		query, args := sqlQuery(ginCtx, user, groups, authorizationURL, authorizationCABundle)
+		log.V(1).Info("Query", "sql", query)
+
+		handleRows(ginCtx, query, args, dbConnectionPool)
+	}
+}
+
+func sqlQuery(ginCtx *gin.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle) (string, []interface{}) {
+	var (
+		sb   strings.Builder
+		args []interface{}
+	)
+
+	// the compliance of the managed clusters the user cannot view is not counted
+	sb.WriteString(fmt.Sprintf(`SELECT p.id::text, p.payload, COALESCE(c.leaf_hub_name, ''),
+		COALESCE(c.compliance::text, ''), COUNT(c.cluster_name) FROM spec.policies p LEFT JOIN %s c ON p.id = c.id
+		WHERE p.deleted = FALSE`, visibleComplianceRelation(ginCtx.Request.Context(), user, groups, authorizationURL,
+		authorizationCABundle)))
+
+	for _, filter := range []struct{ queryParameter, annotation string }{
+		{standardQueryParameter, standardsAnnotation},
+		{categoryQueryParameter, categoriesAnnotation},
+		{controlQueryParameter, controlsAnnotation},
+	} {
+		value, found := ginCtx.GetQuery(filter.queryParameter)
+		if !found {
+			continue
+		}
+
+		args = append(args, filter.annotation, value)
+		sb.WriteString(fmt.Sprintf(
+			" AND $%d = ANY(regexp_split_to_array(p.payload -> 'metadata' -> 'annotations' ->> $%d, '\\s*,\\s*'))",
+			len(args), len(args)-1))
+	}
+
+	sb.WriteString(" AND ")
+	sb.WriteString(authorization.FilterByAuthorization(ginCtx.Request.Context(), user, groups, authorizationURL,
+		authorizationCABundle, authorization.PoliciesQuery, authorization.PolicyUnknown))
+	sb.WriteString(` GROUP BY p.id, p.payload, c.leaf_hub_name, c.compliance
+		ORDER BY p.payload -> 'metadata' ->> 'namespace', p.payload -> 'metadata' ->> 'name', c.leaf_hub_name`)
+
+	return sb.String(), args
+}
+
+// visibleComplianceRelation returns the relation of the compliance of the managed clusters visible to the user.
+func visibleComplianceRelation(ctx context.Context, user string, groups []string, authorizationURL string,
+	authorizationCABundle *certificates.CABundle) string {
+	return fmt.Sprintf(`(SELECT policy_compliance.* FROM status.compliance AS policy_compliance WHERE EXISTS
+		(SELECT 1 FROM status.managed_clusters AS cluster WHERE cluster.leaf_hub_name = policy_compliance.leaf_hub_name
+		AND cluster.payload -> 'metadata' ->> 'name' = policy_compliance.cluster_name AND %s))`,
+		authorization.FilterByAuthorization(ctx, user, groups, authorizationURL, authorizationCABundle,
+			authorization.ClustersQuery, authorization.ClusterUnknown))
+}
+
+func handleRows(ginCtx *gin.Context, query string, args []interface{}, dbConnectionPool *pgxpool.Pool) {
+	log := logging.FromContext(ginCtx.Request.Context())
+
+	rows, err := dbConnectionPool.Query(ginCtx.Request.Context(), query, args...)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		log.Error(err, "Error in querying policies")
+
+		return
+	}
+	defer rows.Close()
+
+	policies := []*policyCompliance{}
+	policiesByID := make(map[string]*policyCompliance)
+	leafHubsByPolicyID := make(map[string]map[string]*leafHubCompliance)
+
+	for rows.Next() {
+		var (
+			id, leafHubName, compliance string
+			payload                     json.RawMessage
+			count                       int64
+		)
+
+		if err := rows.Scan(&id, &payload, &leafHubName, &compliance, &count); err != nil {
+			log.Error(err, "Error in scanning a policy")
+			continue
+		}
+
+		policy, found := policiesByID[id]
+		if !found {
+			policy, err = newPolicyCompliance(id, payload)
+			if err != nil {
+				log.Error(err, "Error in parsing a policy")
+				continue
+			}
+
+			policiesByID[id] = policy
+			leafHubsByPolicyID[id] = make(map[string]*leafHubCompliance)
+			policies = append(policies, policy)
+		}
+
+		if leafHubName == "" { // a policy without compliance status
+			continue
+		}
+
+		leafHub, found := leafHubsByPolicyID[id][leafHubName]
+		if !found {
+			leafHub = &leafHubCompliance{LeafHubName: leafHubName}
+			leafHubsByPolicyID[id][leafHubName] = leafHub
+			policy.LeafHubs = append(policy.LeafHubs, leafHub)
+		}
+
+		leafHub.add(compliance, count)
+		policy.Compliance.add(compliance, count)
+	}
+
+	ginCtx.JSON(http.StatusOK, policies)
+}
+
+func newPolicyCompliance(id string, payload json.RawMessage) (*policyCompliance, error) {
+	var object struct {
+		Metadata metav1.ObjectMeta `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(payload, &object); err != nil {
+		return nil, fmt.Errorf("failed to unmarshall policy %s: %w", id, err)
+	}
+
+	annotations := object.Metadata.GetAnnotations()
+
+	return &policyCompliance{
+		ID:         id,
+		Metadata:   object.Metadata,
+		Standards:  splitAnnotation(annotations[standardsAnnotation]),
+		Categories: splitAnnotation(annotations[categoriesAnnotation]),
+		Controls:   splitAnnotation(annotations[controlsAnnotation]),
+		LeafHubs:   []*leafHubCompliance{},
+	}, nil
+}
+
+func splitAnnotation(annotation string) []string {
+	values := []string{}
+
+	for _, value := range strings.Split(annotation, ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			values = append(values, trimmed)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package policies
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authentication"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/authorization"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/certificates"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+)
+
+type clusterCompliance struct {
+	ClusterName string `json:"clusterName"`
+	LeafHubName string `json:"leafHubName"`
+	Compliance  string `json:"compliance"`
+}
+
+// Status middleware, returns the compliance status of a policy per managed cluster visible to the user.
+func Status(authorizationURL string, authorizationCABundle *certificates.CABundle,
+	dbConnectionPool *pgxpool.Pool) gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		log := logging.FromContext(ginCtx.Request.Context())
+
+		user, isCorrectType := ginCtx.MustGet(authentication.UserKey).(string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the user from the context")
+
+			user = "Unknown"
+		}
+
+		groups, isCorrectType := ginCtx.MustGet(authentication.GroupsKey).([]string)
+		if !isCorrectType {
+			log.Error(nil, "Unable to get the groups from the context")
+
+			groups = []string{}
+		}
+
+		policyID := ginCtx.Param("policy")
+		filter := authorization.FilterByAuthorization(ginCtx.Request.Context(), user, groups, authorizationURL,
+			authorizationCABundle, authorization.PoliciesQuery, authorization.PolicyUnknown)
+
+		var count int64
+
+		err := dbConnectionPool.QueryRow(ginCtx.Request.Context(),
+			"SELECT COUNT(payload) FROM spec.policies WHERE id::text = $1 AND deleted = FALSE AND "+filter,
+			policyID).Scan(&count)
+		if err != nil {
+			ginCtx.String(http.StatusInternalServerError, "internal error")
+			log.Error(err, "Error in querying policies")
+
+			return
+		}
+
+		if count == 0 {
+			ginCtx.JSON(http.StatusNotFound, gin.H{"status": "policy not found"})
+			return
+		}
+
+		handleStatusRows(ginCtx, policyID, visibleComplianceRelation(ginCtx.Request.Context(), user, groups,
+			authorizationURL, authorizationCABundle), dbConnectionPool)
+	}
+}
+
+func handleStatusRows(ginCtx *gin.Context, policyID string, complianceRelation string,
+	dbConnectionPool *pgxpool.Pool) {
+	log := logging.FromContext(ginCtx.Request.Context())
+
+	query := fmt.Sprintf(`SELECT cluster_name, leaf_hub_name, compliance::text FROM %s AS policy_compliance
+		WHERE id::text = $1 ORDER BY leaf_hub_name, cluster_name`, complianceRelation)
+	log.V(1).Info("Status query", "sql", query)
+
+	rows, err := dbConnectionPool.Query(ginCtx.Request.Context(), query, policyID)
+	if err != nil {
+		ginCtx.String(http.StatusInternalServerError, "internal error")
+		log.Error(err, "Error in querying compliance")
+
+		return
+	}
+	defer rows.Close()
+
+	clusters := []*clusterCompliance{}
+
+	for rows.Next() {
+		cluster := &clusterCompliance{}
+
+		if err := rows.Scan(&cluster.ClusterName, &cluster.LeafHubName, &cluster.Compliance); err != nil {
+			log.Error(err, "Error in scanning a compliance status")
+			continue
+		}
+
+		clusters = append(clusters, cluster)
+	}
+
+	ginCtx.JSON(http.StatusOK, clusters)
+}
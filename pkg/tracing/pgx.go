@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const rowCountKey = attribute.Key("db.row_count")
+
+// queryLogger is a pgx.Logger that records the queries and the commands executed in a traced context as spans. pgx
+// logs a query when its rows are closed, so the span of a query lasts until its rows are read.
+type queryLogger struct{}
+
+// NewQueryLogger returns a pgx.Logger that traces the queries, to be set as pgx.ConnConfig.Logger.
+func NewQueryLogger() pgx.Logger {
+	return queryLogger{}
+}
+
+// Log records the Query and Exec messages as spans, see pgx.Logger.
+func (queryLogger) Log(ctx context.Context, _ pgx.LogLevel, msg string, data map[string]interface{}) {
+	if msg != "Query" && msg != "Exec" {
+		return
+	}
+
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	// the messages are logged after the execution, the failed executions are logged without their duration
+	end := time.Now()
+	start := end
+
+	if duration, ok := data["time"].(time.Duration); ok {
+		start = end.Add(-duration)
+	}
+
+	attributes := []attribute.KeyValue{semconv.DBSystemPostgreSQL}
+
+	if sql, ok := data["sql"].(string); ok {
+		attributes = append(attributes, semconv.DBStatementKey.String(sql))
+	}
+
+	if rowCount, ok := data["rowCount"].(int); ok {
+		attributes = append(attributes, rowCountKey.Int(rowCount))
+	}
+
+	_, span := otel.Tracer(instrumentationName).Start(ctx, "pgx."+msg, trace.WithTimestamp(start),
+		trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attributes...))
+
+	if err, ok := data["err"].(error); ok && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End(trace.WithTimestamp(end))
+}
@@ -0,0 +1,162 @@
+// Copyright (c) 2021 Red Hat, Inc.
+// Copyright Contributors to the Open Cluster Management project
+
+// Package tracing contains the OpenTelemetry tracing of the server. The W3C trace context of the incoming requests is
+// continued and propagated to the outgoing HTTP calls, the spans are exported by OTLP over HTTP. Without an exporter
+// endpoint, the tracer provider is the no-op provider of OpenTelemetry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "hub-of-hubs-nonk8s-api"
+	// the name of the tracer of the server, by convention the import path of the instrumentation.
+	instrumentationName = "github.com/stolostron/hub-of-hubs-nonk8s-api"
+	unmatchedRoute      = "<unmatched>"
+)
+
+// Setup sets the global propagator to the W3C trace context and baggage and, if endpoint (host:port of an OTLP/HTTP
+// collector) is not empty, the global tracer provider to a provider that exports the sampled spans to it. The
+// returned function flushes and stops the exporter.
+func Setup(ctx context.Context, endpoint string, insecure bool, samplingRatio float64) (func(context.Context) error,
+	error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{},
+		propagation.Baggage{}))
+
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+	if insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OTLP exporter: %w", err)
+	}
+
+	serviceResource, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the resource of the traces: %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(samplingRatio))),
+	)
+	otel.SetTracerProvider(tracerProvider)
+
+	return tracerProvider.Shutdown, nil
+}
+
+// Start starts a span of the server, a child of the span of ctx if any. The span must be ended, e.g. by End.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error, if any, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Middleware starts a server span for each request, continuing the trace context of the request headers. The span is
+// named by the route template (e.g. /managedclusters/:cluster) and set in the context of the request, so that the
+// handlers create child spans. The trace ID is added to the logger of the request.
+func Middleware() gin.HandlerFunc {
+	return func(ginCtx *gin.Context) {
+		request := ginCtx.Request
+		ctx := otel.GetTextMapPropagator().Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+
+		route := ginCtx.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		ctx, span := otel.Tracer(instrumentationName).Start(ctx, route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(serviceName, route, request)...))
+		defer span.End()
+
+		ginCtx.Request = request.WithContext(ctx)
+
+		if span.SpanContext().IsValid() {
+			logging.WithValues(ginCtx, "traceID", span.SpanContext().TraceID().String())
+		}
+
+		ginCtx.Next()
+
+		status := ginCtx.Writer.Status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+	}
+}
+
+// transport is an http.RoundTripper that traces the requests and propagates the trace context in their headers.
+type transport struct {
+	base *http.Transport
+}
+
+// NewTransport returns a transport that traces the requests of base.
+func NewTransport(base *http.Transport) http.RoundTripper {
+	return &transport{base: base}
+}
+
+// RoundTrip traces the request, see http.RoundTripper. The span ends when the response headers are received. The
+// requests outside of a trace, e.g. of the health checks, are not traced.
+func (transport *transport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !trace.SpanContextFromContext(request.Context()).IsValid() {
+		return transport.base.RoundTrip(request) //nolint:wrapcheck // the transport is transparent
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(request.Context(), "HTTP "+request.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(request)...))
+	defer span.End()
+
+	// a RoundTripper must not modify the request
+	request = request.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
+
+	response, err := transport.base.RoundTrip(request)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return nil, err //nolint:wrapcheck // the transport is transparent
+	}
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(response.StatusCode)...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(response.StatusCode))
+
+	return response, nil
+}
+
+// CloseIdleConnections closes the idle connections of base, see http.Client.CloseIdleConnections.
+func (transport *transport) CloseIdleConnections() {
+	transport.base.CloseIdleConnections()
+}
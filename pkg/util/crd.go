@@ -6,8 +6,9 @@ package util
 import (
 	"context"
 	"fmt"
+	"sync"
 
-	"github.com/gin-gonic/gin"
+	"github.com/stolostron/hub-of-hubs-nonk8s-api/pkg/logging"
 	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	"k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
@@ -15,6 +16,12 @@ import (
 	"k8s.io/client-go/rest"
 )
 
+var (
+	// the CRDs fetched so far, by name, so that each CRD is fetched once.
+	customResourceDefinitions      = map[string]*apiextensionsv1.CustomResourceDefinition{}
+	customResourceDefinitionsMutex sync.Mutex
+)
+
 // GetCustomResourceColumnDefinitions return the column definitions for CRD `name`, version `version`.
 func GetCustomResourceColumnDefinitions(name, version string) []apiextensionsv1.CustomResourceColumnDefinition {
 	// nolint:lll
@@ -23,24 +30,9 @@ func GetCustomResourceColumnDefinitions(name, version string) []apiextensionsv1.
 		{Name: "Age", Type: "date", JSONPath: ".metadata.creationTimestamp"},
 	}
 
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "unable to get inCluster config %s\n", err.Error())
-		return defaultColumns
-	}
-
-	theClientSet, err := clientset.NewForConfig(config)
-	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "unable to create new client set %s\n", err.Error())
-		return defaultColumns
-	}
-
-	// nolint:lll
-	// from https://github.com/kubernetes/apiextensions-apiserver/blob/76c7ff37eea5429706c9cfb4a0e9215e49d93930/test/integration/helpers.go#L84
-
-	crdv1, err := theClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), name, metav1.GetOptions{})
+	crdv1, err := getCustomResourceDefinition(name)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "failed to get CustomResourceDefinition for %s: %v", name, err)
+		logging.Default().Error(err, "Failed to get the CRD", "crd", name)
 		return defaultColumns
 	}
 
@@ -48,13 +40,13 @@ func GetCustomResourceColumnDefinitions(name, version string) []apiextensionsv1.
 	err = apiextensionsv1.Convert_v1_CustomResourceDefinition_To_apiextensions_CustomResourceDefinition(crdv1, &crd, nil)
 
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "failed to convert crd v1 to crd for %s: %v", name, err)
+		logging.Default().Error(err, "Failed to convert the CRD v1 to the internal CRD", "crd", name)
 		return defaultColumns
 	}
 
 	columns, err := apiextensions.GetColumnsForVersion(&crd, version)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter, "failed to get columns for version for %s CRD: %s %v", name, version, err)
+		logging.Default().Error(err, "Failed to get the columns of the CRD version", "crd", name, "version", version)
 		return defaultColumns
 	}
 
@@ -64,14 +56,65 @@ func GetCustomResourceColumnDefinitions(name, version string) []apiextensionsv1.
 
 	columnsv1, err := convertColumnsToColumnsV1(columns)
 	if err != nil {
-		fmt.Fprintf(gin.DefaultWriter,
-			"failed to convert columns (from apiextensions to apiextensions/v1) for %s CRD: %s %v", name, version, err)
+		logging.Default().Error(err, "Failed to convert the columns (from apiextensions to apiextensions/v1)",
+			"crd", name, "version", version)
 		return defaultColumns
 	}
 
 	return columnsv1
 }
 
+// GetCustomResourceValidationSchema returns the OpenAPI v3 schema of CRD `name`, version `version`, or nil if the
+// CRD or its schema is not available.
+func GetCustomResourceValidationSchema(name, version string) *apiextensionsv1.JSONSchemaProps {
+	crd, err := getCustomResourceDefinition(name)
+	if err != nil {
+		logging.Default().Error(err, "Failed to get the CRD", "crd", name)
+		return nil
+	}
+
+	for _, crdVersion := range crd.Spec.Versions {
+		if crdVersion.Name == version && crdVersion.Schema != nil {
+			return crdVersion.Schema.OpenAPIV3Schema.DeepCopy()
+		}
+	}
+
+	logging.Default().Info("No schema for the CRD version", "crd", name, "version", version)
+
+	return nil
+}
+
+func getCustomResourceDefinition(name string) (*apiextensionsv1.CustomResourceDefinition, error) {
+	customResourceDefinitionsMutex.Lock()
+	defer customResourceDefinitionsMutex.Unlock()
+
+	if crd, found := customResourceDefinitions[name]; found {
+		return crd, nil
+	}
+
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get inCluster config: %w", err)
+	}
+
+	theClientSet, err := clientset.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new client set: %w", err)
+	}
+
+	// nolint:lll
+	// from https://github.com/kubernetes/apiextensions-apiserver/blob/76c7ff37eea5429706c9cfb4a0e9215e49d93930/test/integration/helpers.go#L84
+
+	crd, err := theClientSet.ApiextensionsV1().CustomResourceDefinitions().Get(context.TODO(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CustomResourceDefinition for %s: %w", name, err)
+	}
+
+	customResourceDefinitions[name] = crd
+
+	return crd, nil
+}
+
 func convertColumnsToColumnsV1(columns []apiextensions.CustomResourceColumnDefinition) (
 	[]apiextensionsv1.CustomResourceColumnDefinition, error) {
 	columnsv1 := make([]apiextensionsv1.CustomResourceColumnDefinition, 0, len(columns))